@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/backup"
+	"github.com/lexfrei/goPaperMC/pkg/fleet"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+	"github.com/spf13/cobra"
+)
+
+// newBackupCmd creates the backup command.
+func newBackupCmd(a *app) *cobra.Command {
+	var reason string
+
+	backupCmd := &cobra.Command{
+		Use:   "backup DIR",
+		Short: "Back up the worlds of a server directory",
+		Long: `Archive the worlds of the server in DIR into a timestamped backup.
+
+The worlds are detected from level-name in server.properties: the main
+world folder and its _nether and _the_end folders. Each backup is a tar
+archive compressed with --archive-format (tar.zst or tar.gz) and starts with
+a manifest recording the worlds, the installed build and the file count.
+Backups are written to --backup-dir (default DIR/.papermc/world-backups).
+
+After each backup, the retention policy removes backups beyond the --keep
+newest and backups older than --max-age; the newest backup is always kept.
+
+Stop the server, or turn off saving with "papermc rcon DIR save-off", while
+a backup is made. "papermc update --backup" backs up the worlds before
+installing a new build, after stopping the server with --rcon; set
+backup_on_update in the config to always do so.
+
+Examples:
+  papermc backup ./server
+  papermc backup ./server --archive-format=tar.gz --keep=14 --max-age=720h
+  papermc backup list ./server
+  papermc backup restore ./server latest`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := args[0]
+
+			opts, err := a.backupOptions()
+			if err != nil {
+				return err
+			}
+			opts.Reason = reason
+
+			result, err := backup.Create(cmd.Context(), dir, opts)
+			if result != nil {
+				writeBackupResult(cmd.OutOrStdout(), result)
+			}
+			if err != nil {
+				return fail("backing up "+dir, err)
+			}
+
+			return nil
+		},
+	}
+
+	backupCmd.PersistentFlags().String("backup-dir", "", "Backup directory, relative to DIR (default .papermc/world-backups)")
+	_ = a.config.BindPFlag("backup_dir", backupCmd.PersistentFlags().Lookup("backup-dir"))
+
+	flags := backupCmd.Flags()
+	flags.StringVar(&reason, "reason", "", "Note recorded in the manifest")
+	flags.String("archive-format", string(backup.FormatZstd), "Archive format (tar.zst, tar.gz)")
+	flags.Int("keep", backup.DefaultKeep, "Number of newest backups to keep (0 means no limit)")
+	flags.Duration("max-age", 0, "Remove backups older than this, e.g. 720h (0 means no limit)")
+	_ = a.config.BindPFlag("backup_format", flags.Lookup("archive-format"))
+	_ = a.config.BindPFlag("backup_keep", flags.Lookup("keep"))
+	_ = a.config.BindPFlag("backup_max_age", flags.Lookup("max-age"))
+
+	_ = backupCmd.RegisterFlagCompletionFunc("archive-format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{string(backup.FormatZstd), string(backup.FormatGzip)}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	backupCmd.AddCommand(newBackupListCmd(a), newBackupRestoreCmd(a))
+
+	return backupCmd
+}
+
+// newBackupListCmd creates the backup list command.
+func newBackupListCmd(a *app) *cobra.Command {
+	var format string
+
+	backupListCmd := &cobra.Command{
+		Use:   "list DIR",
+		Short: "List the world backups of a server directory",
+		Long: `List the backups in the backup directory of DIR, newest first, with the
+worlds they contain and the build that was installed when they were made.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			backups, err := backup.List(backup.Dir(args[0], a.config.GetString("backup_dir")))
+			if err != nil {
+				return fail("listing backups", err)
+			}
+
+			if format == "json" {
+				if backups == nil {
+					backups = []backup.Backup{}
+				}
+				return writeJSON(cmd, backups)
+			}
+
+			out := cmd.OutOrStdout()
+			if len(backups) == 0 {
+				fmt.Fprintln(out, "No backups")
+				return nil
+			}
+
+			for _, b := range backups {
+				line := fmt.Sprintf("%s  %d files, %s", b.Name, b.Files, formatBytes(b.ArchiveSize))
+				if b.Install != nil {
+					line += fmt.Sprintf(", %s %s build %d", b.Install.Project, b.Install.Version, b.Install.Build)
+				}
+				if b.Reason != "" {
+					line += ", " + b.Reason
+				}
+				fmt.Fprintln(out, line)
+			}
+
+			return nil
+		},
+	}
+
+	backupListCmd.Flags().StringVar(&format, "format", "text", "Output format (text, json)")
+
+	return backupListCmd
+}
+
+// newBackupRestoreCmd creates the backup restore command.
+func newBackupRestoreCmd(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore DIR BACKUP",
+		Short: "Restore the worlds of a server directory from a backup",
+		Long: `Replace the worlds of the server in DIR with those of BACKUP: a file name
+from "papermc backup list", a path to an archive, or "latest".
+
+The archive is extracted and checked against its manifest before anything
+is replaced. The current world folders are moved to
+DIR/.papermc/replaced-worlds-TIMESTAMP rather than deleted; remove them once
+the restored worlds are fine. The server must be stopped.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, name := args[0], args[1]
+			backupDir := backup.Dir(dir, a.config.GetString("backup_dir"))
+
+			path := name
+			switch {
+			case name == "latest":
+				backups, err := backup.List(backupDir)
+				if err != nil {
+					return fail("listing backups", err)
+				}
+				if len(backups) == 0 {
+					return fail("no backups in "+backupDir, nil)
+				}
+				path = backups[0].Path
+			case !fileExists(name):
+				path = filepath.Join(backupDir, name)
+			}
+
+			result, err := backup.Restore(cmd.Context(), dir, path)
+			if err != nil {
+				return fail("restoring "+name, err)
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Restored %v from %s\n", result.Backup.Worlds, result.Backup.Name)
+			if result.Replaced != "" {
+				fmt.Fprintf(out, "Previous worlds moved to %s\n", result.Replaced)
+			}
+
+			return nil
+		},
+	}
+}
+
+// backupOptions returns the backup options set from flags or config.
+func (a *app) backupOptions() (backup.Options, error) {
+	format, err := backup.ParseFormat(a.config.GetString("backup_format"))
+	if err != nil {
+		return backup.Options{}, fail("parsing backup format", err)
+	}
+
+	return backup.Options{
+		Dir:    a.config.GetString("backup_dir"),
+		Format: format,
+		Retention: backup.Retention{
+			Keep:   a.config.GetInt("backup_keep"),
+			MaxAge: a.config.GetDuration("backup_max_age"),
+		},
+	}, nil
+}
+
+// backupHook returns the update hook backing up the worlds of dir before a
+// new build is installed, or nil if enabled is false and backup_on_update
+// is not set. The update is aborted if the backup fails; failing to prune
+// old backups only prints a warning.
+func (a *app) backupHook(dir string, enabled bool, stderr io.Writer) (func(ctx context.Context, from, to *serverdir.Install) error, error) {
+	if !enabled && !a.config.GetBool("backup_on_update") {
+		return nil, nil
+	}
+
+	opts, err := a.backupOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, from, to *serverdir.Install) error {
+		opts.Reason = fmt.Sprintf("before update from %s build %d to %s build %d", from.Version, from.Build, to.Version, to.Build)
+
+		result, err := backup.Create(ctx, dir, opts)
+		if result == nil {
+			return err
+		}
+
+		writeBackupResult(stderr, result)
+		if err != nil {
+			fmt.Fprintf(stderr, "Warning: %s\n", err)
+		}
+
+		return nil
+	}, nil
+}
+
+// requireStopped returns an update hook failing if the server in dir
+// accepts connections on its port, so its worlds are not archived while it
+// writes them.
+func requireStopped(dir string) func(ctx context.Context, from, to *serverdir.Install) error {
+	return func(ctx context.Context, from, to *serverdir.Install) error {
+		target, err := fleet.ParseTarget(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil // The server never ran, it writes server.properties on start
+		}
+		if err != nil {
+			return err
+		}
+
+		dialer := net.Dialer{Timeout: time.Second}
+		conn, err := dialer.DialContext(ctx, "tcp", target.Address)
+		if err != nil {
+			return nil
+		}
+		_ = conn.Close()
+
+		return errors.Newf("server at %s is running; stop it, or use --rcon to stop it, before its worlds are backed up", target.Address)
+	}
+}
+
+// chainHooks returns an update hook running hooks in order until one fails,
+// or nil if all hooks are nil.
+func chainHooks(hooks ...func(ctx context.Context, from, to *serverdir.Install) error) func(ctx context.Context, from, to *serverdir.Install) error {
+	var set []func(ctx context.Context, from, to *serverdir.Install) error
+	for _, hook := range hooks {
+		if hook != nil {
+			set = append(set, hook)
+		}
+	}
+
+	if len(set) == 0 {
+		return nil
+	}
+
+	return func(ctx context.Context, from, to *serverdir.Install) error {
+		for _, hook := range set {
+			if err := hook(ctx, from, to); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// writeBackupResult reports a new backup and the backups pruned after it.
+func writeBackupResult(w io.Writer, result *backup.Result) {
+	b := result.Backup
+	fmt.Fprintf(w, "Backed up %v to %s (%d files, %s)\n", b.Worlds, b.Path, b.Files, formatBytes(b.ArchiveSize))
+
+	for _, pruned := range result.Pruned {
+		fmt.Fprintf(w, "Removed old backup %s\n", pruned.Name)
+	}
+}
+
+// formatBytes renders a size with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+	dir := t.TempDir()
+	world := filepath.Join(dir, "world", "level.dat")
+	if err := os.MkdirAll(filepath.Dir(world), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(world, []byte("level"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The subcommands take --backup-dir too.
+	server := newAPIServer(t)
+	if _, _, err := executeServer(t, server, "backup", "--backup-dir=archives", "--reason=nightly", dir); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	out, _, err := executeServer(t, server, "backup", "list", "--backup-dir=archives", dir)
+	if err != nil || strings.Count(out, "\n") != 1 || !strings.Contains(out, "nightly") {
+		t.Errorf("Expected the backup in archives, got %q (%v)", out, err)
+	}
+
+	out, _, err = executeServer(t, server, "backup", "list", dir)
+	if err != nil || out != "No backups\n" {
+		t.Errorf("Expected no backups in the default directory, got %q (%v)", out, err)
+	}
+
+	if err := os.WriteFile(world, []byte("corrupted"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, _, err = executeServer(t, server, "backup", "restore", "--backup-dir=archives", dir, "latest")
+	if err != nil || !strings.HasPrefix(out, "Restored [world] from ") {
+		t.Fatalf("Unexpected restore output %q (%v)", out, err)
+	}
+	if data, err := os.ReadFile(world); err != nil || string(data) != "level" {
+		t.Errorf("Expected the world to be restored, got %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+	"github.com/spf13/cobra"
+)
+
+// newChangelogCmd creates the changelog command.
+func newChangelogCmd(a *app) *cobra.Command {
+	var (
+		format string
+		dir    string
+	)
+
+	changelogCmd := &cobra.Command{
+		Use:   "changelog PROJECT_ID VERSION [FROM..TO]",
+		Short: "Show the commits that went into a range of builds",
+		Long: `Aggregate the commits of a range of builds into a changelog.
+
+The range FROM..TO includes builds after FROM up to and including TO;
+either bound may be omitted. A single build number shows only that build.
+Without a range, all builds of the version are included.
+
+With --dir, the project, version and starting build are taken from the
+build installed in a server directory, showing everything between the
+installed build and the latest one.
+
+Examples:
+  papermc changelog paper 1.21.11 70..74 --format=markdown
+  papermc changelog --dir ./server`,
+		Args: cobra.RangeArgs(0, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := a.newClient()
+			ctx := cmd.Context()
+
+			var (
+				projectID, version string
+				from, to           int32
+				err                error
+			)
+
+			switch {
+			case dir != "" && len(args) == 0:
+				install, err := serverdir.Detect(ctx, client, dir, serverdir.DetectOptions{})
+				if err != nil {
+					return fail("detecting installed build", err)
+				}
+				projectID, version, from = install.Project, install.Version, install.Build
+			case dir == "" && len(args) >= 2:
+				projectID, version = args[0], args[1]
+				if len(args) == 3 {
+					from, to, err = parseBuildRange(args[2])
+					if err != nil {
+						return fail("parsing build range", err)
+					}
+				}
+			default:
+				return fail("specify either PROJECT_ID VERSION [FROM..TO] or --dir", nil)
+			}
+
+			changelog, err := client.GetChangelog(ctx, projectID, version, from, to)
+			if err != nil {
+				return fail("building changelog", err)
+			}
+
+			out := cmd.OutOrStdout()
+			switch format {
+			case "json":
+				err = json.NewEncoder(out).Encode(changelog)
+			case "markdown", "md":
+				err = writeChangelogMarkdown(out, changelog)
+			default:
+				err = writeChangelogText(out, changelog)
+			}
+			if err != nil {
+				return fail("writing changelog", err)
+			}
+
+			return nil
+		},
+	}
+
+	changelogCmd.Flags().StringVar(&format, "format", "text", "Output format (text, markdown, json)")
+	changelogCmd.Flags().StringVar(&dir, "dir", "", "Show changes since the build installed in this server directory")
+
+	return changelogCmd
+}
+
+// parseBuildRange parses "FROM..TO", "FROM..", "..TO" or a single build
+// number into an exclusive lower and inclusive upper bound.
+func parseBuildRange(s string) (int32, int32, error) {
+	parseBound := func(bound string) (int32, error) {
+		if bound == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseInt(bound, 10, 32)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid build number %q", bound)
+		}
+		return int32(n), nil
+	}
+
+	fromStr, toStr, isRange := strings.Cut(s, "..")
+	if !isRange {
+		build, err := parseBound(s)
+		if err != nil || build == 0 {
+			return 0, 0, errors.Newf("invalid build range %q", s)
+		}
+		return build - 1, build, nil
+	}
+
+	from, err := parseBound(fromStr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	to, err := parseBound(toStr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return from, to, nil
+}
+
+// firstLine returns the first line of a commit message.
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return strings.TrimSpace(line)
+}
+
+// shortCommit abbreviates a commit SHA.
+func shortCommit(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// writeChangelogText renders a changelog as plain text.
+func writeChangelogText(w io.Writer, changelog *api.Changelog) error {
+	for _, build := range changelog.Builds {
+		if _, err := fmt.Fprintf(w, "Build %d (%s, %s)\n", build.ID, build.Channel, build.Time.Format("2006-01-02")); err != nil {
+			return err
+		}
+		for _, commit := range build.Commits {
+			if _, err := fmt.Fprintf(w, "  %s %s\n", shortCommit(commit.SHA), firstLine(commit.Message)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeChangelogMarkdown renders a changelog as Markdown.
+func writeChangelogMarkdown(w io.Writer, changelog *api.Changelog) error {
+	if _, err := fmt.Fprintf(w, "## %s %s: builds %d..%d\n", changelog.Project, changelog.Version,
+		changelog.From, changelog.To); err != nil {
+		return err
+	}
+
+	for _, build := range changelog.Builds {
+		if _, err := fmt.Fprintf(w, "\n### Build %d (%s, %s)\n\n", build.ID, build.Channel,
+			build.Time.Format("2006-01-02")); err != nil {
+			return err
+		}
+		if len(build.Commits) == 0 {
+			if _, err := fmt.Fprintln(w, "_No new commits._"); err != nil {
+				return err
+			}
+		}
+		for _, commit := range build.Commits {
+			if _, err := fmt.Fprintf(w, "- `%s` %s\n", shortCommit(commit.SHA), firstLine(commit.Message)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -4,10 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 
-	"github.com/cockroachdb/errors"
-	"github.com/lexfrei/goPaperMC/pkg/api"
 	"github.com/spf13/cobra"
 )
 
@@ -18,18 +15,25 @@ type BuildInfo struct {
 	URL     string `json:"url"`
 }
 
-// ciCmd represents the ci command.
-var ciCmd = &cobra.Command{
-	Use:   "ci",
-	Short: "Commands specifically for CI environments",
-	Long:  `Commands designed to work well in Continuous Integration environments like GitHub Actions.`,
+// newCICmd creates the ci command.
+func newCICmd(a *app) *cobra.Command {
+	ciCmd := &cobra.Command{
+		Use:   "ci",
+		Short: "Commands specifically for CI environments",
+		Long:  `Commands designed to work well in Continuous Integration environments like GitHub Actions.`,
+	}
+
+	ciCmd.AddCommand(newCIMatrixCmd(a), newCIActionsCmd(a), newCILatestCmd(a))
+
+	return ciCmd
 }
 
-// ciMatrixCmd represents the ci matrix command.
-var ciMatrixCmd = &cobra.Command{
-	Use:   "matrix PROJECT_ID",
-	Short: "Generate a JSON matrix for CI builds",
-	Long: `Generate a JSON array of the latest builds for the last N versions of a project.
+// newCIMatrixCmd creates the ci matrix command.
+func newCIMatrixCmd(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "matrix PROJECT_ID",
+		Short: "Generate a JSON matrix for CI builds",
+		Long: `Generate a JSON array of the latest builds for the last N versions of a project.
 This is designed to be used in CI environments to generate a build matrix.
 
 Example:
@@ -37,75 +41,24 @@ Example:
 
 This will output a JSON array of objects with version, build, and URL information
 for the latest builds of the last 3 versions of the paper project.`,
-	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		projectID := args[0]
-		client := api.NewClient()
-
-		// Apply channel filter if set
-		if ch := GetChannel(); ch != "" {
-			client.WithChannel(api.Channel(ch))
-		}
-
-		ctx := context.Background()
-
-		// Get project info to get versions
-		projectInfo, err := client.GetProject(ctx, projectID)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error getting project info: %v\n", errors.UnwrapAll(err))
-			os.Exit(1)
-		}
-
-		versions := projectInfo.FlattenVersions()
-		limit := GetLimit()
-
-		// Build array of builds, iterating from newest to oldest
-		var buildInfos []BuildInfo
-		for i := len(versions) - 1; i >= 0; i-- {
-			if limit > 0 && len(buildInfos) >= limit {
-				break
-			}
-
-			version := versions[i]
-			build, err := client.GetLatestBuildV3(ctx, projectID, version)
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			buildInfos, err := a.latestBuilds(cmd.Context(), args[0])
 			if err != nil {
-				// Skip versions without matching builds
-				continue
+				return err
 			}
 
-			url := build.GetDownloadURL()
-			if url == "" {
-				continue
-			}
-
-			buildInfos = append(buildInfos, BuildInfo{
-				Version: version,
-				Build:   build.ID,
-				URL:     url,
-			})
-		}
-
-		// Reverse to get oldest-to-newest order
-		for i, j := 0, len(buildInfos)-1; i < j; i, j = i+1, j-1 {
-			buildInfos[i], buildInfos[j] = buildInfos[j], buildInfos[i]
-		}
-
-		// Output as JSON
-		jsonOutput, err := json.Marshal(buildInfos)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error generating JSON: %v\n", err)
-			os.Exit(1)
-		}
-
-		fmt.Println(string(jsonOutput))
-	},
+			return writeJSON(cmd, buildInfos)
+		},
+	}
 }
 
-// ciActionsCmd represents the ci github-actions command.
-var ciActionsCmd = &cobra.Command{
-	Use:   "github-actions PROJECT_ID",
-	Short: "Output GitHub Actions compatible JSON matrix",
-	Long: `Generate JSON specifically formatted for GitHub Actions matrix strategy.
+// newCIActionsCmd creates the ci github-actions command.
+func newCIActionsCmd(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "github-actions PROJECT_ID",
+		Short: "Output GitHub Actions compatible JSON matrix",
+		Long: `Generate JSON specifically formatted for GitHub Actions matrix strategy.
 
 Example:
   papermc ci github-actions paper --limit=3
@@ -114,113 +67,118 @@ This will output JSON that can be directly used in a GitHub Actions workflow:
 
   matrix=$(papermc ci github-actions paper --limit=3)
   echo "matrix=$matrix" >> $GITHUB_OUTPUT`,
-	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		projectID := args[0]
-		client := api.NewClient()
-
-		// Apply channel filter if set
-		if ch := GetChannel(); ch != "" {
-			client.WithChannel(api.Channel(ch))
-		}
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			buildInfos, err := a.latestBuilds(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
 
-		ctx := context.Background()
+			// Format in the way GitHub Actions expects
+			return writeJSON(cmd, map[string][]BuildInfo{
+				"include": buildInfos,
+			})
+		},
+	}
+}
 
-		// Get project info to get versions
-		projectInfo, err := client.GetProject(ctx, projectID)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error getting project info: %v\n", errors.UnwrapAll(err))
-			os.Exit(1)
-		}
+// newCILatestCmd creates the ci latest command.
+func newCILatestCmd(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "latest PROJECT_ID",
+		Short: "Get the latest version",
+		Long: `Get the latest version of a project.
 
-		versions := projectInfo.FlattenVersions()
-		limit := GetLimit()
+Example:
+  papermc ci latest paper
 
-		// Build array of builds, iterating from newest to oldest
-		var buildInfos []BuildInfo
-		for i := len(versions) - 1; i >= 0; i-- {
-			if limit > 0 && len(buildInfos) >= limit {
-				break
-			}
+This will output just the latest version string, which can be used in scripts:
 
-			version := versions[i]
-			build, err := client.GetLatestBuildV3(ctx, projectID, version)
-			if err != nil {
-				// Skip versions without matching builds
-				continue
+  latest_version=$(papermc ci latest paper)`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := a.newClient()
+
+			// Apply channel filter if set
+			if ch := a.channel(); ch != "" {
+				client.WithChannel(ch)
 			}
 
-			url := build.GetDownloadURL()
-			if url == "" {
-				continue
+			// Get latest version
+			version, err := client.GetLatestVersion(cmd.Context(), args[0])
+			if err != nil {
+				return fail("getting latest version", err)
 			}
 
-			buildInfos = append(buildInfos, BuildInfo{
-				Version: version,
-				Build:   build.ID,
-				URL:     url,
-			})
-		}
+			// Output just the version string
+			fmt.Fprintln(cmd.OutOrStdout(), version)
 
-		// Reverse to get oldest-to-newest order
-		for i, j := 0, len(buildInfos)-1; i < j; i, j = i+1, j-1 {
-			buildInfos[i], buildInfos[j] = buildInfos[j], buildInfos[i]
-		}
+			return nil
+		},
+	}
+}
 
-		// Format in the way GitHub Actions expects
-		matrixObj := map[string][]BuildInfo{
-			"include": buildInfos,
+// latestBuilds returns the latest build of the last N versions of a
+// project, oldest first, honoring the configured limit and channel.
+func (a *app) latestBuilds(ctx context.Context, projectID string) ([]BuildInfo, error) {
+	client := a.newClient()
+
+	// Apply channel filter if set
+	if ch := a.channel(); ch != "" {
+		client.WithChannel(ch)
+	}
+
+	// Get project info to get versions
+	projectInfo, err := client.GetProject(ctx, projectID)
+	if err != nil {
+		return nil, fail("getting project info", err)
+	}
+
+	versions := projectInfo.FlattenVersions()
+	limit := a.limit()
+
+	// Build array of builds, iterating from newest to oldest
+	var buildInfos []BuildInfo
+	for i := len(versions) - 1; i >= 0; i-- {
+		if limit > 0 && len(buildInfos) >= limit {
+			break
 		}
 
-		// Output as JSON
-		jsonOutput, err := json.Marshal(matrixObj)
+		version := versions[i]
+		build, err := client.GetLatestBuildV3(ctx, projectID, version)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error generating JSON: %v\n", err)
-			os.Exit(1)
+			// Skip versions without matching builds
+			continue
 		}
 
-		fmt.Println(string(jsonOutput))
-	},
-}
-
-var ciLatestCmd = &cobra.Command{
-	Use:   "latest PROJECT_ID",
-	Short: "Get the latest version",
-	Long: `Get the latest version of a project.
-
-Example:
-  papermc ci latest paper
-
-This will output just the latest version string, which can be used in scripts:
-
-  latest_version=$(papermc ci latest paper)`,
-	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		projectID := args[0]
-		client := api.NewClient()
-
-		// Apply channel filter if set
-		if ch := GetChannel(); ch != "" {
-			client.WithChannel(api.Channel(ch))
+		url := build.GetDownloadURL()
+		if url == "" {
+			continue
 		}
 
-		ctx := context.Background()
+		buildInfos = append(buildInfos, BuildInfo{
+			Version: version,
+			Build:   build.ID,
+			URL:     url,
+		})
+	}
 
-		// Get latest version
-		version, err := client.GetLatestVersion(ctx, projectID)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error getting latest version: %v\n", errors.UnwrapAll(err))
-			os.Exit(1)
-		}
+	// Reverse to get oldest-to-newest order
+	for i, j := 0, len(buildInfos)-1; i < j; i, j = i+1, j-1 {
+		buildInfos[i], buildInfos[j] = buildInfos[j], buildInfos[i]
+	}
 
-		// Output just the version string
-		fmt.Println(version)
-	},
+	return buildInfos, nil
 }
 
-func init() {
-	rootCmd.AddCommand(ciCmd)
-	ciCmd.AddCommand(ciMatrixCmd)
-	ciCmd.AddCommand(ciActionsCmd)
-	ciCmd.AddCommand(ciLatestCmd)
+// writeJSON writes v as a single line of JSON to the command's output.
+func writeJSON(cmd *cobra.Command, v any) error {
+	jsonOutput, err := json.Marshal(v)
+	if err != nil {
+		return fail("generating JSON", err)
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), string(jsonOutput))
+
+	return nil
 }
@@ -1,115 +1,108 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strconv"
 
 	"github.com/cockroachdb/errors"
-	"github.com/lexfrei/goPaperMC/pkg/api"
 	"github.com/spf13/cobra"
 )
 
-var destination string
+// newDownloadCmd creates the download command.
+func newDownloadCmd(a *app) *cobra.Command {
+	var destination string
 
-// downloadCmd represents the download command.
-var downloadCmd = &cobra.Command{
-	Use:   "download PROJECT_ID [VERSION] [BUILD] [DESTINATION]",
-	Short: "Download a build file",
-	Long: `Download a build file from PaperMC API.
+	downloadCmd := &cobra.Command{
+		Use:   "download PROJECT_ID [VERSION] [BUILD] [DESTINATION]",
+		Short: "Download a build file",
+		Long: `Download a build file from PaperMC API.
 If only PROJECT_ID is provided, the latest stable version and build will be downloaded.
 If PROJECT_ID and VERSION are provided, the latest build for that version will be downloaded.
 If PROJECT_ID, VERSION, and BUILD are provided, that specific build will be downloaded.
 If DESTINATION is provided, the file will be saved to that location.`,
-	Args: cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		projectID := args[0]
-
-		var version string
-		var buildNum int32
-		var destDir string
-		var err error
-
-		// Set default values
-		destDir = "."
-		if destination != "" {
-			destDir = destination
-		}
-
-		client := api.NewClient()
-		ctx := context.Background()
-
-		// Process arguments
-		switch len(args) {
-		case 1: // Only project_id
-			// Download the latest stable version
-			version, err = client.GetRecommendedVersion(ctx, projectID)
-			if err != nil {
-				fmt.Printf("Error finding recommended version: %v\n", errors.UnwrapAll(err))
-				os.Exit(1)
+		Args: cobra.RangeArgs(1, 4),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			projectID := args[0]
+
+			var version string
+			var buildNum int32
+			var err error
+
+			// Set default values
+			destDir := "."
+			if destination != "" {
+				destDir = destination
 			}
 
-			buildNum, err = client.GetLatestBuild(ctx, projectID, version)
-			if err != nil {
-				fmt.Printf("Error finding latest build: %v\n", errors.UnwrapAll(err))
-				os.Exit(1)
+			client := a.newClient()
+			ctx := cmd.Context()
+
+			// Process arguments
+			switch len(args) {
+			case 1: // Only project_id
+				// Download the latest stable version
+				version, err = client.GetRecommendedVersion(ctx, projectID)
+				if err != nil {
+					return fail("finding recommended version", err)
+				}
+
+				buildNum, err = client.GetLatestBuild(ctx, projectID, version)
+				if err != nil {
+					return fail("finding latest build", err)
+				}
+
+			case 2: // project_id and version
+				version = args[1]
+
+				buildNum, err = client.GetLatestBuild(ctx, projectID, version)
+				if err != nil {
+					return fail("finding latest build", err)
+				}
+
+			case 3, 4: // project_id, version, build, and optionally destination
+				version = args[1]
+
+				build, err := strconv.ParseInt(args[2], 10, 32)
+				if err != nil {
+					return fail("parsing build number", err)
+				}
+				buildNum = int32(build)
+
+				if len(args) >= 4 {
+					destDir = args[3]
+				}
 			}
 
-		case 2: // project_id and version
-			version = args[1]
-
-			buildNum, err = client.GetLatestBuild(ctx, projectID, version)
+			// Get download name
+			downloadName, err := client.GetDefaultDownloadName(ctx, projectID, version, buildNum)
 			if err != nil {
-				fmt.Printf("Error finding latest build: %v\n", errors.UnwrapAll(err))
-				os.Exit(1)
+				return fail("getting download name", err)
 			}
 
-		case 3, 4: // project_id, version, build, and optionally destination
-			version = args[1]
+			// Form the full path
+			destPath := filepath.Join(destDir, downloadName)
 
-			build, err := strconv.ParseInt(args[2], 10, 32)
+			// Download the file
+			result, err := client.DownloadFile(ctx, projectID, version, buildNum, destPath)
 			if err != nil {
-				fmt.Printf("Error parsing build number: %v\n", errors.UnwrapAll(err))
-				os.Exit(1)
+				return fail("downloading file", err)
 			}
-			buildNum = int32(build)
 
-			if len(args) >= 4 {
-				destDir = args[3]
+			fmt.Fprintf(cmd.OutOrStdout(), "Downloaded %s\n", result.Filename)
+
+			if !result.Valid {
+				return fail("verifying checksum", errors.Newf("expected %s, got %s",
+					result.ExpectedSHA256, result.ActualSHA256))
 			}
-		}
-
-		// Get download name
-		downloadName, err := client.GetDefaultDownloadName(ctx, projectID, version, buildNum)
-		if err != nil {
-			fmt.Printf("Error getting download name: %v\n", errors.UnwrapAll(err))
-			os.Exit(1)
-		}
-
-		// Form the full path
-		destPath := filepath.Join(destDir, downloadName)
-
-		// Download the file
-		result, err := client.DownloadFile(ctx, projectID, version, buildNum, destPath)
-		if err != nil {
-			fmt.Printf("Error downloading file: %v\n", errors.UnwrapAll(err))
-			os.Exit(1)
-		}
-
-		fmt.Printf("Downloaded %s\n", result.Filename)
-
-		if !result.Valid {
-			fmt.Printf("Checksum verification FAILED! Expected: %s, got: %s\n",
-				result.ExpectedSHA256, result.ActualSHA256)
-			os.Exit(1)
-		}
-	},
-}
 
-func init() {
-	rootCmd.AddCommand(downloadCmd)
+			a.warnJava(ctx, cmd.ErrOrStderr(), client, projectID, version)
+
+			return nil
+		},
+	}
 
 	downloadCmd.Flags().StringVarP(&destination, "destination", "d", "", "Destination directory for the downloaded file")
+
+	return downloadCmd
 }
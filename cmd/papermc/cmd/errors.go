@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"github.com/cockroachdb/errors"
+)
+
+// exitDrift is the exit code of "plan" and "fleet status" when managed files
+// or servers are out of date.
+const exitDrift = 2
+
+// ErrDrift is returned by "plan" when server directories differ from the manifest.
+var ErrDrift = &Error{Message: "servers differ from the manifest", Code: exitDrift}
+
+// ErrBehind is returned by "fleet status" when servers run an outdated build.
+var ErrBehind = &Error{Message: "servers are behind the latest build", Code: exitDrift}
+
+// Error is a command failure. Execute prints it to stderr and exits with
+// its Code.
+type Error struct {
+	Message string // What failed, e.g. "getting project info"
+	Err     error  // Cause, may be nil
+	Code    int    // Exit status (0 means 1)
+}
+
+// Error returns the message followed by the innermost cause.
+func (e *Error) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Message
+	case e.Message == "":
+		return errors.UnwrapAll(e.Err).Error()
+	default:
+		return e.Message + ": " + errors.UnwrapAll(e.Err).Error()
+	}
+}
+
+// Unwrap returns the cause.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// fail returns an Error describing what failed.
+func fail(message string, err error) error {
+	return &Error{Message: message, Err: err}
+}
+
+// ExitCode returns the exit status for an error returned by a command: 0
+// for nil, the Code of an Error and 1 otherwise.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var cmdErr *Error
+	if errors.As(err, &cmdErr) && cmdErr.Code != 0 {
+		return cmdErr.Code
+	}
+
+	return 1
+}
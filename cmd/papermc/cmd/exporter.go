@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/exporter"
+	"github.com/spf13/cobra"
+)
+
+// newExporterCmd creates the exporter command.
+func newExporterCmd(a *app) *cobra.Command {
+	var (
+		listen     string
+		interval   time.Duration
+		serverDirs []string
+	)
+
+	exporterCmd := &cobra.Command{
+		Use:   "exporter [PROJECT_ID[:VERSION]...]",
+		Short: "Serve Prometheus metrics about upstream builds",
+		Long: `Serve Prometheus metrics on /metrics: the latest build ID and time per
+project, version and channel, the support status of each version and
+request counters and latency of the API client.
+
+Each target is a project optionally followed by a version constraint, e.g.
+paper:1.21.x. Without a constraint, the newest version family is exported.
+Without targets, paper is exported.
+
+With --server-dir, the build installed in each directory and the number of
+builds it is behind are exported as well.
+
+Metrics are refreshed every --interval; scrapes never hit the API.
+
+Examples:
+  papermc exporter --listen=:9723
+  papermc exporter paper:1.21.x velocity --server-dir=/srv/lobby --server-dir=/srv/survival`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{"paper"}
+			}
+
+			// Responses are shared between targets and server directories within a refresh.
+			client := a.newClient().WithCache(api.NewMemoryCache(interval / 2))
+			stderr := cmd.ErrOrStderr()
+
+			e := exporter.New(client, parseTargets(args)...).
+				WithInterval(interval).
+				WithServerDirs(serverDirs...)
+			e.OnError = func(err error) {
+				fmt.Fprintf(stderr, "Error refreshing metrics: %v\n", errors.UnwrapAll(err))
+			}
+
+			ctx := cmd.Context()
+
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", e.Handler())
+
+			server := &http.Server{
+				Addr:              listen,
+				Handler:           mux,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+
+			go func() {
+				_ = e.Run(ctx)
+			}()
+
+			go func() {
+				<-ctx.Done()
+				_ = server.Shutdown(context.Background())
+			}()
+
+			fmt.Fprintf(stderr, "Serving metrics on %s/metrics\n", listen)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fail("serving metrics", err)
+			}
+
+			return nil
+		},
+	}
+
+	flags := exporterCmd.Flags()
+	flags.StringVar(&listen, "listen", ":9723", "Address to serve metrics on")
+	flags.DurationVar(&interval, "interval", exporter.DefaultInterval, "Time between two refreshes")
+	flags.StringArrayVar(&serverDirs, "server-dir", nil, "Server directory whose installed build is exported (repeatable)")
+
+	return exporterCmd
+}
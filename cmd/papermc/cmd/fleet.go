@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lexfrei/goPaperMC/pkg/fleet"
+	"github.com/spf13/cobra"
+)
+
+// newFleetCmd creates the fleet command.
+func newFleetCmd(a *app) *cobra.Command {
+	fleetCmd := &cobra.Command{
+		Use:   "fleet",
+		Short: "Check what running servers are deployed with",
+		Long:  `Commands for checking many running servers at once.`,
+	}
+
+	fleetCmd.AddCommand(newFleetStatusCmd(a))
+
+	return fleetCmd
+}
+
+// newFleetStatusCmd creates the fleet status command.
+func newFleetStatusCmd(a *app) *cobra.Command {
+	var (
+		opts   fleet.Options
+		format string
+	)
+
+	fleetStatusCmd := &cobra.Command{
+		Use:   "status DIR|HOST[:PORT]...",
+		Short: "Ping servers and flag those behind the latest build",
+		Long: `Ping every server and map the version it reports back to a published
+version, then compare it with the newest version and build of the project
+(honoring --channel).
+
+A server directory is pinged at the address in its server.properties, and
+its papermc.lock supplies the running build, which pings do not reveal; it
+also shows servers whose jar was updated but that have not been restarted.
+Servers reporting a bare version such as "1.21.11" are assumed to run
+--project.
+
+Lines starting with "!" are servers that are behind, lines starting with
+"?" are servers that could not be pinged or whose version is unknown. The
+command exits with status 2 when any server is behind.
+
+Example:
+  papermc fleet status ./lobby ./survival proxy.example.com:25577`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets := make([]fleet.Target, 0, len(args))
+			for _, arg := range args {
+				target, err := fleet.ParseTarget(arg)
+				if err != nil {
+					return fail("reading "+arg, err)
+				}
+				targets = append(targets, target)
+			}
+
+			opts.Channel = a.channel()
+
+			reports, err := fleet.Check(cmd.Context(), a.newClient(), targets, opts)
+			if err != nil {
+				return fail("checking servers", err)
+			}
+
+			if format == "json" {
+				if err := writeJSON(cmd, reports); err != nil {
+					return err
+				}
+			} else {
+				writeFleetReports(cmd.OutOrStdout(), reports)
+			}
+
+			for _, report := range reports {
+				if report.IsBehind() {
+					return ErrBehind
+				}
+			}
+
+			return nil
+		},
+	}
+
+	flags := fleetStatusCmd.Flags()
+	flags.StringVar(&opts.Project, "project", fleet.DefaultProject, "Project of servers reporting a bare version")
+	flags.IntVar(&opts.Concurrency, "concurrency", fleet.DefaultConcurrency, "Number of servers pinged in parallel")
+	flags.StringVar(&format, "format", "text", "Output format (text, json)")
+
+	return fleetStatusCmd
+}
+
+// writeFleetReports renders fleet reports, one line per server.
+func writeFleetReports(w io.Writer, reports []fleet.Report) {
+	var behind, unknown int
+
+	for _, r := range reports {
+		switch {
+		case !r.Online:
+			unknown++
+			fmt.Fprintf(w, "? %s (%s): offline: %s\n", r.Name, r.Address, r.Error)
+		case r.Version == "":
+			unknown++
+			fmt.Fprintf(w, "? %s (%s): %q: %s\n", r.Name, r.Address, r.Reported, r.Error)
+		default:
+			target := r.Project + " " + r.Version
+			if r.Build != 0 {
+				target += fmt.Sprintf(" build %d", r.Build)
+			}
+			target += fmt.Sprintf(", %d/%d players", r.Players, r.Max)
+
+			switch {
+			case r.IsBehind():
+				behind++
+				fmt.Fprintf(w, "! %s (%s): %s: %s\n", r.Name, r.Address, target, strings.Join(r.Behind, "; "))
+			case r.Error != "":
+				unknown++
+				fmt.Fprintf(w, "? %s (%s): %s: %s\n", r.Name, r.Address, target, r.Error)
+			default:
+				fmt.Fprintf(w, "  %s (%s): %s up to date\n", r.Name, r.Address, target)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "\nFleet: %d behind, %d unknown, %d up to date.\n", behind, unknown, len(reports)-behind-unknown)
+}
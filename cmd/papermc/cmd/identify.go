@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/spf13/cobra"
+)
+
+// newIdentifyCmd creates the identify command.
+func newIdentifyCmd(a *app) *cobra.Command {
+	var (
+		project string
+		family  string
+		format  string
+		noCache bool
+	)
+
+	identifyCmd := &cobra.Command{
+		Use:   "identify FILE|SHA256",
+		Short: "Identify a jar by its SHA256 checksum",
+		Long: `Find the published build a jar belongs to by searching the builds of a
+project for a download with the same SHA256 checksum.
+
+The argument is either a file or a hex-encoded SHA256 checksum. Build
+lists are cached for an hour in the user cache directory, so repeated
+lookups are fast; use --no-cache to always query the API.
+
+Example:
+  papermc identify ./server.jar --family=1.21`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := a.newCachingClient(noCache)
+			ctx := cmd.Context()
+			opts := api.IdentifyOptions{Family: family}
+
+			var (
+				id  *api.Identification
+				err error
+			)
+			if sum := args[0]; isSHA256(sum) && !fileExists(sum) {
+				id, err = client.Identify(ctx, project, strings.ToLower(sum), opts)
+			} else {
+				id, err = client.IdentifyFile(ctx, project, args[0], opts)
+			}
+
+			if errors.Is(err, api.ErrNoMatchingBuild) {
+				return fail(fmt.Sprintf("%s does not match any published %s build", args[0], project), nil)
+			}
+			if err != nil {
+				return fail("identifying "+args[0], err)
+			}
+
+			if format == "json" {
+				return writeJSON(cmd, id)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%s %s build %d (%s, %s)\n",
+				id.Project, id.Version, id.Build, id.Channel, id.DownloadKey)
+
+			return nil
+		},
+	}
+
+	flags := identifyCmd.Flags()
+	flags.StringVar(&project, "project", "paper", "Project whose builds are searched")
+	flags.StringVar(&family, "family", "", "Only search versions of this family, e.g. 1.21")
+	flags.StringVar(&format, "format", "text", "Output format (text, json)")
+	flags.BoolVar(&noCache, "no-cache", false, "Do not use cached build lists")
+
+	return identifyCmd
+}
+
+// isSHA256 reports whether s looks like a hex-encoded SHA256 checksum.
+func isSHA256(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+
+	return err == nil
+}
+
+// fileExists reports whether path names an existing file.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/api/apitest"
+)
+
+func TestIdentify(t *testing.T) {
+	server := newAPIServer(t)
+
+	jar := filepath.Join(t.TempDir(), "server.jar")
+	if err := os.WriteFile(jar, apitest.DefaultJar("paper", "1.21.11", 74), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, _, err := executeServer(t, server, "identify", "--no-cache", jar)
+	if err != nil || out != "paper 1.21.11 build 74 (STABLE, server:default)\n" {
+		t.Errorf("Unexpected output %q (%v)", out, err)
+	}
+
+	sum := sha256.Sum256(apitest.DefaultJar("paper", "1.21.11", 75))
+	out, _, err = executeServer(t, server, "identify", "--no-cache", "--format=json", hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("Identify failed: %v", err)
+	}
+	var id api.Identification
+	if err := json.Unmarshal([]byte(out), &id); err != nil || id.Build != 75 {
+		t.Errorf("Expected build 75 as JSON, got %q (%v)", out, err)
+	}
+
+	if _, _, err := executeServer(t, server, "identify", "--no-cache", strings.Repeat("0", 64)); err == nil {
+		t.Error("Expected an unknown checksum to fail")
+	}
+}
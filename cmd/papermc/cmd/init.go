@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/scaffold"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+	"github.com/spf13/cobra"
+)
+
+// newInitCmd creates the init command.
+func newInitCmd(a *app) *cobra.Command {
+	var (
+		opts    scaffold.Options
+		profile string
+		props   []string
+	)
+
+	initCmd := &cobra.Command{
+		Use:   "init DIR",
+		Short: "Set up a new server directory",
+		Long: `Set up a server in DIR: download the newest build matching --version
+(honoring --channel), record it in papermc.lock, write server.properties and
+bukkit.yml from a profile and generate start.sh. Velocity publishes its
+releases as -SNAPSHOT versions, so for it "latest" is the newest of those.
+
+The Minecraft EULA (https://aka.ms/MinecraftEULA) is only accepted in
+eula.txt with --accept-eula.
+
+--profile is a built-in profile (` + strings.Join(scaffold.ProfileNames(), ", ") + `) or a YAML file with
+server_properties and bukkit sections. Values may use {{.Name}} (the
+directory name), {{.Project}}, {{.Version}}, {{.Build}} and {{.Port}};
+--property entries are applied after the profile.
+
+Existing servers are never overwritten unless --force is given; with
+--force, existing configuration files are updated rather than replaced.
+
+Examples:
+  papermc init ./survival --version=1.21.x --accept-eula
+  papermc init ./lobby --profile=lobby --port=25566 --property=white-list=true
+  papermc init ./proxy --project=velocity`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := args[0]
+			opts.Channel = a.channel()
+
+			opts.Properties = make(map[string]string, len(props))
+			for _, prop := range props {
+				key, value, ok := strings.Cut(prop, "=")
+				if !ok || key == "" {
+					return fail(fmt.Sprintf("invalid property %q, expected key=value", prop), nil)
+				}
+				opts.Properties[key] = value
+			}
+
+			var err error
+			if opts.Profile, err = scaffold.LoadProfile(profile); err != nil {
+				return fail("loading profile", err)
+			}
+
+			client := a.newClient()
+			ctx := cmd.Context()
+
+			result, err := scaffold.Init(ctx, client, dir, opts)
+			if errors.Is(err, scaffold.ErrExists) {
+				return fail(dir+" already contains a server, use --force to overwrite it", nil)
+			}
+			if err != nil {
+				return fail("initializing "+dir, err)
+			}
+
+			a.warnJava(ctx, cmd.ErrOrStderr(), client, result.Install.Project, result.Install.Version)
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Installed %s %s build %d in %s\n",
+				result.Install.Project, result.Install.Version, result.Install.Build, dir)
+			for _, file := range result.Files {
+				fmt.Fprintf(out, "Wrote %s\n", filepath.Join(dir, file))
+			}
+
+			if !opts.AcceptEULA && !scaffold.IsProxy(result.Install.Project) {
+				fmt.Fprintln(out, "Accept the Minecraft EULA in eula.txt (or use --accept-eula) before starting the server")
+			}
+
+			return nil
+		},
+	}
+
+	flags := initCmd.Flags()
+	flags.StringVar(&opts.Project, "project", "paper", "Project to install")
+	flags.StringVar(&opts.Version, "version", "latest", "Version constraint, e.g. 1.21.11, 1.21.x or latest")
+	flags.StringVar(&opts.Jar, "jar", serverdir.DefaultJar, "Name of the server jar inside DIR")
+	flags.BoolVar(&opts.AcceptEULA, "accept-eula", false, "Accept the Minecraft EULA in eula.txt")
+	flags.StringVar(&profile, "profile", scaffold.DefaultProfile, "Built-in profile or profile file")
+	flags.StringArrayVar(&props, "property", nil, "server.properties entry applied after the profile (key=value, repeatable)")
+	flags.IntVar(&opts.Port, "port", 0, "Server port (default 25565)")
+	flags.StringVar(&opts.Java, "java", "", "Java executable used by the start script")
+	flags.StringVar(&opts.Heap, "heap", "", "Fixed heap size of the start script, e.g. 4G (default sized at launch)")
+	flags.BoolVarP(&opts.Force, "force", "f", false, "Overwrite an existing server")
+
+	_ = initCmd.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return scaffold.ProfileNames(), cobra.ShellCompDirectiveDefault
+	})
+
+	return initCmd
+}
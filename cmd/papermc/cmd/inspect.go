@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/lexfrei/goPaperMC/pkg/jarinfo"
+	"github.com/spf13/cobra"
+)
+
+// newInspectCmd creates the inspect command.
+func newInspectCmd(_ *app) *cobra.Command {
+	var (
+		format    string
+		libraries bool
+	)
+
+	inspectCmd := &cobra.Command{
+		Use:   "inspect FILE",
+		Short: "Show the metadata embedded in a server jar",
+		Long: `Read the metadata embedded in a server jar without network access: the
+manifest implementation version, the bundled Minecraft version, the build
+number and, for Paperclip jars, the bundled versions, libraries and patches.
+
+Example:
+  papermc inspect ./server.jar --format=json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := jarinfo.Inspect(args[0])
+			if err != nil {
+				return fail("inspecting "+args[0], err)
+			}
+
+			if format == "json" {
+				return writeJSON(cmd, report)
+			}
+
+			out := cmd.OutOrStdout()
+			printField := func(name, value string) {
+				if value != "" {
+					fmt.Fprintf(out, "%-18s %s\n", name+":", value)
+				}
+			}
+
+			printField("File", report.Path)
+			printField("SHA256", report.SHA256)
+			printField("Project", report.Project)
+			printField("Minecraft", report.MinecraftVersion)
+			if report.Build != 0 {
+				printField("Build", fmt.Sprint(report.Build))
+			}
+			printField("Commit", report.Commit)
+			printField("Implementation", report.ImplementationVersion)
+			printField("Main class", report.MainClass)
+			if report.Minecraft != nil {
+				printField("Protocol", fmt.Sprint(report.Minecraft.ProtocolVersion))
+				printField("Java", fmt.Sprint(report.Minecraft.JavaVersion))
+			}
+			printField("Paperclip", fmt.Sprint(report.Paperclip))
+			if report.Paperclip {
+				printField("Libraries", fmt.Sprint(len(report.Libraries)))
+				printField("Patches", fmt.Sprint(len(report.Patches)))
+			}
+
+			if libraries {
+				for _, lib := range report.Libraries {
+					fmt.Fprintf(out, "  %s\n", lib.ID)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	inspectCmd.Flags().StringVar(&format, "format", "text", "Output format (text, json)")
+	inspectCmd.Flags().BoolVar(&libraries, "libraries", false, "List bundled libraries")
+
+	return inspectCmd
+}
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/java"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+	"github.com/spf13/cobra"
+)
+
+// newJavaCmd creates the java command.
+func newJavaCmd(a *app) *cobra.Command {
+	javaCmd := &cobra.Command{
+		Use:   "java",
+		Short: "Manage the Java runtimes servers run on",
+		Long: `Commands for checking local Java runtimes against the Java version a
+server version requires, and for installing managed runtimes.`,
+	}
+
+	javaCmd.AddCommand(newJavaCheckCmd(a), newJavaInstallCmd(a))
+
+	return javaCmd
+}
+
+// newJavaCheckCmd creates the java check command.
+func newJavaCheckCmd(a *app) *cobra.Command {
+	var (
+		project string
+		format  string
+	)
+
+	javaCheckCmd := &cobra.Command{
+		Use:   "check [VERSION]",
+		Short: "Check which local Java runtimes can run a version",
+		Long: `Discover local Java runtimes on the PATH, in JAVA_HOME, in the usual
+Linux JVM directories and among the managed runtimes by reading their
+release files, and compare them with the minimum Java version the API
+lists for VERSION (default: the latest version, honoring --channel).
+
+The command fails when no runtime is recent enough.
+
+Example:
+  papermc java check 1.21.11`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := a.newClient()
+			ctx := cmd.Context()
+
+			var version string
+			if len(args) == 1 {
+				version = args[0]
+			} else {
+				if ch := a.channel(); ch != "" {
+					client.WithChannel(ch)
+				}
+
+				var err error
+				version, err = client.GetLatestVersion(ctx, project)
+				if err != nil {
+					return fail("getting latest version", err)
+				}
+			}
+
+			report, err := java.Check(ctx, client, project, version, a.javaOptions())
+			if err != nil {
+				return fail("checking Java runtimes", err)
+			}
+
+			if format == "json" {
+				if err := writeJSON(cmd, report); err != nil {
+					return err
+				}
+			} else {
+				writeJavaReport(cmd.OutOrStdout(), report)
+			}
+
+			if len(report.Usable) == 0 {
+				return fail(fmt.Sprintf("no Java %d+ runtime found for %s %s", report.Minimum, project, version), nil)
+			}
+
+			return nil
+		},
+	}
+
+	javaCheckCmd.Flags().StringVar(&project, "project", "paper", "Project whose Java requirement is checked")
+	javaCheckCmd.Flags().StringVar(&format, "format", "text", "Output format (text, json)")
+
+	return javaCheckCmd
+}
+
+// newJavaInstallCmd creates the java install command.
+func newJavaInstallCmd(a *app) *cobra.Command {
+	var (
+		project   string
+		dir       string
+		jar       string
+		major     int
+		imageType string
+	)
+
+	javaInstallCmd := &cobra.Command{
+		Use:   "install [VERSION]",
+		Short: "Install a Java runtime that can run a version",
+		Long: `Install the latest Eclipse Temurin runtime of the minimum Java version the
+API lists for VERSION (default: the latest version, honoring --channel) into
+the managed runtimes directory. The archive is verified against its
+published SHA256 checksum; a runtime already installed from the same
+release is reused.
+
+With --dir, the version is detected from the build installed in the server
+directory and the runtime is recorded in DIR/.papermc/java.json, so
+start-script uses it for that server.
+
+Runtimes are installed into --runtimes-dir (default
+$XDG_DATA_HOME/papermc/runtimes or ~/.local/share/papermc/runtimes).
+--adoptium-url points at a mirror or stand-in of the Adoptium API.
+
+Examples:
+  papermc java install 1.21.11
+  papermc java install --dir=./server
+  papermc java install --major=25 --image-type=jdk`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if dir != "" {
+				if len(args) == 1 {
+					return fail("VERSION and --dir are mutually exclusive", nil)
+				}
+
+				abs, err := filepath.Abs(dir)
+				if err != nil {
+					return fail("resolving directory", err)
+				}
+				dir = abs
+			}
+
+			if major == 0 {
+				client := a.newClient()
+
+				var version string
+				switch {
+				case dir != "":
+					install, err := serverdir.Detect(ctx, client, dir, serverdir.DetectOptions{Jar: jar, Project: project})
+					if err != nil {
+						return fail("detecting installed build", err)
+					}
+					project, version = install.Project, install.Version
+				case len(args) == 1:
+					version = args[0]
+				default:
+					if ch := a.channel(); ch != "" {
+						client.WithChannel(ch)
+					}
+
+					var err error
+					version, err = client.GetLatestVersion(ctx, project)
+					if err != nil {
+						return fail("getting latest version", err)
+					}
+				}
+
+				info, err := client.GetVersion(ctx, project, version)
+				if err != nil {
+					return fail("getting version info", err)
+				}
+
+				major = info.Version.Java.Version.Minimum
+				if major == 0 {
+					return fail(fmt.Sprintf("the API lists no Java version for %s %s, use --major", project, version), nil)
+				}
+			}
+
+			runtimesDir := a.runtimesDir()
+			if runtimesDir == "" {
+				return fail("no runtimes directory available, use --runtimes-dir", nil)
+			}
+
+			installer := java.NewInstaller(runtimesDir).
+				WithImageType(imageType).
+				WithBaseURL(a.config.GetString("adoptium_url"))
+
+			rt, err := installer.Install(ctx, major)
+			if err != nil {
+				return fail(fmt.Sprintf("installing Java %d", major), err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Installed Java %s in %s\n", rt.Version, rt.Home)
+
+			if dir != "" {
+				if err := java.Pin(dir, rt); err != nil {
+					return fail("recording runtime", err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Server in %s now uses %s\n", dir, rt.Executable)
+			}
+
+			return nil
+		},
+	}
+
+	flags := javaInstallCmd.Flags()
+	flags.StringVar(&project, "project", "paper", "Project whose Java requirement is installed")
+	flags.StringVar(&dir, "dir", "", "Server directory to detect the version from and record the runtime in")
+	flags.StringVar(&jar, "jar", serverdir.DefaultJar, "Name of the server jar inside --dir")
+	flags.IntVar(&major, "major", 0, "Java major version to install instead of the version's minimum")
+	flags.StringVar(&imageType, "image-type", java.DefaultImageType, "Kind of runtime to install (jre, jdk)")
+	flags.String("runtimes-dir", "", "Directory managed runtimes are installed into")
+	flags.String("adoptium-url", java.DefaultAdoptiumURL, "Base URL of the Adoptium-compatible API")
+	_ = a.config.BindPFlag("runtimes_dir", flags.Lookup("runtimes-dir"))
+	_ = a.config.BindPFlag("adoptium_url", flags.Lookup("adoptium-url"))
+
+	return javaInstallCmd
+}
+
+// javaOptions searches the default locations and the managed runtimes.
+func (a *app) javaOptions() java.Options {
+	opts := java.DefaultOptions()
+	if dir := a.runtimesDir(); dir != "" {
+		opts.Dirs = append(slices.Clone(opts.Dirs), dir)
+	}
+
+	return opts
+}
+
+// writeJavaReport renders a Java check report as text.
+func writeJavaReport(w io.Writer, report *java.Report) {
+	fmt.Fprintf(w, "%s %s requires Java %d or newer\n", report.Project, report.Version, report.Minimum)
+
+	if len(report.Runtimes) == 0 {
+		fmt.Fprintln(w, "No Java runtimes found")
+		return
+	}
+
+	for _, rt := range report.Runtimes {
+		status := "ok"
+		if rt.Major < report.Minimum {
+			status = "too old"
+		}
+
+		fmt.Fprintf(w, "  %-8s Java %-3d %s (%s, %s)\n", status, rt.Major, rt.Home, rt.Version, rt.Source)
+	}
+}
+
+// warnJava warns on stderr when no local runtime can run a version. Failures
+// to check are only logged, as the warning is advisory.
+func (a *app) warnJava(ctx context.Context, stderr io.Writer, client api.API, project, version string) {
+	report, err := java.Check(ctx, client, project, version, a.javaOptions())
+	if err != nil {
+		a.logger.Debug("checking Java runtimes failed", "error", err.Error())
+		return
+	}
+
+	if report.Minimum > 0 && len(report.Usable) == 0 {
+		fmt.Fprintf(stderr, "Warning: %s %s requires Java %d or newer, but no such runtime was found (see \"papermc java check\")\n",
+			project, version, report.Minimum)
+	}
+}
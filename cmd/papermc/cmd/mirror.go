@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/mirror"
+	"github.com/spf13/cobra"
+)
+
+// newMirrorCmd creates the mirror command.
+func newMirrorCmd(a *app) *cobra.Command {
+	mirrorCmd := &cobra.Command{
+		Use:   "mirror",
+		Short: "Maintain a static mirror of the PaperMC API",
+		Long:  `Commands for replicating projects, versions and builds into a directory for air-gapped environments.`,
+	}
+
+	mirrorCmd.AddCommand(newMirrorSyncCmd(a))
+
+	return mirrorCmd
+}
+
+// newMirrorSyncCmd creates the mirror sync command.
+func newMirrorSyncCmd(a *app) *cobra.Command {
+	var opts mirror.Options
+
+	mirrorSyncCmd := &cobra.Command{
+		Use:   "sync DIR [PROJECT_ID[:VERSION]...]",
+		Short: "Replicate metadata and jars into a directory",
+		Long: `Replicate metadata and jars of the selected projects and versions into DIR,
+laid out like the API's URL structure. Each response is stored as
+index.json in a directory named after its endpoint, and jars are stored
+under v1/objects/SHA256/NAME.
+
+Each target is a project optionally followed by a version constraint, e.g.
+paper:1.21.x. Without a constraint, the newest version family is mirrored.
+Without targets, paper is mirrored.
+
+Syncs are incremental: builds already in the mirror are kept and only new
+jars are downloaded. Every download is verified against its checksum.
+
+Use the mirror with --base-url=file:///path/to/DIR, or serve DIR with any
+static web server using index.json as index file.
+
+Examples:
+  papermc mirror sync /srv/mirror paper:1.21.x velocity --builds=3
+  papermc --base-url=file:///srv/mirror download paper 1.21.11`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := args[0]
+
+			targetArgs := args[1:]
+			if len(targetArgs) == 0 {
+				targetArgs = []string{"paper"}
+			}
+
+			opts.Targets = parseTargets(targetArgs)
+			opts.Channel = a.channel()
+			opts.PublicURL = strings.TrimSuffix(opts.PublicURL, "/")
+
+			result, err := mirror.Sync(cmd.Context(), a.newClient(), dir, opts)
+			if err != nil {
+				return fail("syncing mirror", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Mirrored %d versions and %d builds: %d jars downloaded (%d bytes), %d up to date\n",
+				result.Versions, result.Builds, result.Downloaded, result.Bytes, result.Skipped)
+
+			return nil
+		},
+	}
+
+	flags := mirrorSyncCmd.Flags()
+	flags.IntVar(&opts.Builds, "builds", 1, "Number of latest builds to mirror per version (0 means all)")
+	flags.StringArrayVar(&opts.Artifacts, "artifact", []string{api.DefaultDownloadKey}, "Download key to mirror (repeatable)")
+	flags.StringVar(&opts.PublicURL, "public-url", "", "Base URL written into download URLs (default relative URLs)")
+	flags.BoolVar(&opts.Verify, "verify", false, "Verify checksums of jars already in the mirror")
+
+	return mirrorSyncCmd
+}
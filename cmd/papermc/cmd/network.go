@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/scaffold"
+	"github.com/spf13/cobra"
+)
+
+// newNetworkCmd creates the network command.
+func newNetworkCmd(a *app) *cobra.Command {
+	networkCmd := &cobra.Command{
+		Use:   "network",
+		Short: "Manage Velocity networks with Paper backends",
+		Long:  `Commands for setting up a Velocity proxy with Paper backend servers.`,
+	}
+
+	networkCmd.AddCommand(newNetworkInitCmd(a))
+
+	return networkCmd
+}
+
+// newNetworkInitCmd creates the network init command.
+func newNetworkInitCmd(a *app) *cobra.Command {
+	var (
+		opts    scaffold.NetworkOptions
+		count   int
+		profile string
+	)
+
+	networkInitCmd := &cobra.Command{
+		Use:   "init DIR",
+		Short: "Set up a Velocity proxy with Paper backends",
+		Long: `Set up a local network in DIR: a Velocity proxy in DIR/proxy and a Paper
+backend in DIR/NAME for each backend, ready to start.
+
+The proxy binds to --port and forwards players with modern forwarding,
+using a forwarding secret generated into proxy/forwarding.secret and
+written to each backend's config/paper-global.yml. Backends listen on
+127.0.0.1 from --backend-port upwards with online mode disabled, and are
+listed in velocity.toml; players join the first one.
+
+Backends are named with --names, or backend-1 to backend-N with
+--backends=N. They run the newest Paper version matching --version
+(honoring --channel) and are configured with --profile like "papermc init".
+Whether the Velocity version supports it is not checked; recent Velocity
+builds support every Minecraft release since 1.7.2.
+
+Without --heap, the proxy and the backends split the share of the memory
+limit a single server would use as heap evenly; set HEAP or HEAP_PERCENT
+when starting a server to change it.
+
+Examples:
+  papermc network init ./network --names=lobby,survival --accept-eula
+  papermc network init ./network --backends=3 --version=1.21.x`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := args[0]
+			opts.Channel = a.channel()
+
+			if len(opts.Backends) == 0 {
+				if count < 1 {
+					return fail("--backends must be at least 1", nil)
+				}
+				for i := 1; i <= count; i++ {
+					opts.Backends = append(opts.Backends, "backend-"+strconv.Itoa(i))
+				}
+			}
+
+			var err error
+			if opts.Profile, err = scaffold.LoadProfile(profile); err != nil {
+				return fail("loading profile", err)
+			}
+
+			client := a.newClient()
+			ctx := cmd.Context()
+
+			result, err := scaffold.InitNetwork(ctx, client, dir, opts)
+			if errors.Is(err, scaffold.ErrExists) {
+				return fail(err.Error()+", use --force to overwrite the network", nil)
+			}
+			if err != nil {
+				return fail("initializing network in "+dir, err)
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Proxy: velocity %s build %d in %s (port %d)\n", result.Proxy.Install.Version,
+				result.Proxy.Install.Build, filepath.Join(dir, scaffold.ProxyDir), opts.Port)
+			for _, backend := range result.Backends {
+				fmt.Fprintf(out, "Backend %s: paper %s build %d in %s (port %d)\n", backend.Name, backend.Install.Version,
+					backend.Install.Build, filepath.Join(dir, backend.Name), backend.Port)
+			}
+
+			if len(result.Backends) > 0 {
+				a.warnJava(ctx, cmd.ErrOrStderr(), client, "paper", result.Backends[0].Install.Version)
+			}
+
+			if !opts.AcceptEULA {
+				fmt.Fprintln(out, "Accept the Minecraft EULA in each backend's eula.txt (or use --accept-eula) before starting them")
+			}
+
+			return nil
+		},
+	}
+
+	flags := networkInitCmd.Flags()
+	flags.StringVar(&opts.ProxyVersion, "proxy-version", "latest", "Velocity version constraint")
+	flags.StringVar(&opts.Version, "version", "latest", "Paper version constraint of the backends, e.g. 1.21.x")
+	flags.StringSliceVar(&opts.Backends, "names", nil, "Backend names; the first is the server players join")
+	flags.IntVar(&count, "backends", 2, "Number of backends when --names is not given")
+	flags.IntVar(&opts.Port, "port", scaffold.DefaultPort, "Port the proxy binds to")
+	flags.IntVar(&opts.BackendPort, "backend-port", scaffold.DefaultBackendPort, "Port of the first backend")
+	flags.BoolVar(&opts.AcceptEULA, "accept-eula", false, "Accept the Minecraft EULA for the backends")
+	flags.StringVar(&profile, "profile", scaffold.DefaultProfile, "Built-in profile or profile file for the backends")
+	flags.StringVar(&opts.Heap, "heap", "", "Fixed heap size of each start script, e.g. 2G (default: a share of the memory limit)")
+	flags.BoolVarP(&opts.Force, "force", "f", false, "Overwrite existing servers")
+
+	networkInitCmd.MarkFlagsMutuallyExclusive("names", "backends")
+
+	return networkInitCmd
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/notify"
+	"github.com/spf13/cobra"
+)
+
+// webhookFlags holds the values of the webhook flags of a command.
+type webhookFlags struct {
+	webhooks []string
+	template string
+}
+
+// newNotifyCmd creates the notify command.
+func newNotifyCmd(a *app) *cobra.Command {
+	notifyCmd := &cobra.Command{
+		Use:   "notify PROJECT_ID VERSION [BUILD]",
+		Short: "Send a build notification to webhooks",
+		Long: `Post the details of a build (version, build ID, channel, commit messages
+and download URL) to one or more webhooks. Without BUILD, the latest build
+is used, honoring --channel.
+
+Webhooks are given as URLs with --webhook, optionally prefixed with a format
+(discord=, slack= or json=), or listed under "webhooks" in the config file.
+Discord and Slack webhook URLs are detected automatically. The summary line
+can be customized with a Go template, e.g.
+--template='{{.Project}} {{.Version}} #{{.Build}} is out'.
+
+Example:
+  papermc notify paper 1.21.11 --channel=stable --webhook=https://discord.com/api/webhooks/...`,
+		Args: cobra.RangeArgs(2, 3),
+	}
+
+	hooks := addWebhookFlags(notifyCmd)
+
+	notifyCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		projectID, version := args[0], args[1]
+
+		notifier, err := a.newNotifier(hooks)
+		if err != nil {
+			return fail("configuring webhooks", err)
+		}
+		if len(notifier.Webhooks) == 0 {
+			return fail("no webhooks configured, use --webhook", nil)
+		}
+
+		client := a.newClient()
+		if ch := a.channel(); ch != "" {
+			client.WithChannel(ch)
+		}
+
+		ctx := cmd.Context()
+
+		var build *api.BuildV3Response
+		if len(args) == 3 {
+			buildNum, err := strconv.ParseInt(args[2], 10, 32)
+			if err != nil {
+				return fail("parsing build number", err)
+			}
+			build, err = client.GetBuild(ctx, projectID, version, int32(buildNum))
+			if err != nil {
+				return fail("getting build", err)
+			}
+		} else {
+			build, err = client.GetLatestBuildV3(ctx, projectID, version)
+			if err != nil {
+				return fail("getting latest build", err)
+			}
+		}
+
+		if err := notifier.Notify(ctx, notify.MessageFromBuild(projectID, version, build)); err != nil {
+			return fail("sending notification", err)
+		}
+
+		return nil
+	}
+
+	return notifyCmd
+}
+
+// newNotifier creates a notifier for the webhooks given on the command line
+// and in the config file.
+func (a *app) newNotifier(hooks *webhookFlags) (*notify.Notifier, error) {
+	tmpl, err := notify.ParseTemplate(hooks.template)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := append(a.config.GetStringSlice("webhooks"), hooks.webhooks...)
+	webhooks := make([]notify.Webhook, 0, len(urls))
+	for _, u := range urls {
+		webhook, err := notify.ParseWebhook(u)
+		if err != nil {
+			return nil, err
+		}
+		webhook.Template = tmpl
+		webhooks = append(webhooks, webhook)
+	}
+
+	return notify.NewNotifier(webhooks...), nil
+}
+
+// notifyEvent forwards a build event to the webhooks if it is in channel
+// (any channel if empty). Failures are reported to stderr.
+func notifyEvent(ctx context.Context, stderr io.Writer, notifier *notify.Notifier, channel api.Channel, event api.Event) {
+	msg, ok := notify.MessageFromEvent(event)
+	if !ok {
+		return
+	}
+
+	if channel != "" && !strings.EqualFold(string(channel), msg.Channel) {
+		return
+	}
+
+	if err := notifier.Notify(ctx, msg); err != nil {
+		fmt.Fprintf(stderr, "Error sending notification: %v\n", errors.UnwrapAll(err))
+	}
+}
+
+// addWebhookFlags registers the webhook flags on a command.
+func addWebhookFlags(c *cobra.Command) *webhookFlags {
+	hooks := &webhookFlags{}
+	c.Flags().StringArrayVar(&hooks.webhooks, "webhook", nil, "Webhook URL, optionally prefixed with discord=, slack= or json= (repeatable)")
+	c.Flags().StringVar(&hooks.template, "template", "", "Go template for the summary line (default \""+notify.DefaultTemplate+"\")")
+
+	return hooks
+}
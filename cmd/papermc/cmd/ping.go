@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/lexfrei/goPaperMC/pkg/ping"
+	"github.com/spf13/cobra"
+)
+
+// pingResult is the output of the ping command.
+type pingResult struct {
+	Address   string       `json:"address"`
+	Version   string       `json:"version"`
+	Protocol  int          `json:"protocol"`
+	Players   ping.Players `json:"players"`
+	MOTD      string       `json:"motd"`
+	LatencyMS int64        `json:"latency_ms,omitempty"`
+}
+
+// newPingCmd creates the ping command.
+func newPingCmd(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "ping HOST[:PORT]",
+		Short: "Show the version and players a running server reports",
+		Long: `Request the status of a running server with a Server List Ping, the
+request Minecraft clients send for the server list, and print the version
+name, protocol number, player counts and MOTD it reports as JSON.
+
+The port defaults to 25565.
+
+Example:
+  papermc ping mc.example.com`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			status, err := ping.Ping(cmd.Context(), args[0])
+			if err != nil {
+				return fail("pinging "+args[0], err)
+			}
+
+			return writeJSON(cmd, pingResult{
+				Address:   args[0],
+				Version:   status.Version.Name,
+				Protocol:  status.Version.Protocol,
+				Players:   status.Players,
+				MOTD:      status.MOTD(),
+				LatencyMS: status.Latency.Milliseconds(),
+			})
+		},
+	}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/lexfrei/goPaperMC/pkg/manifest"
+	"github.com/spf13/cobra"
+)
+
+// newPlanCmd creates the plan command.
+func newPlanCmd(a *app) *cobra.Command {
+	var manifestFile string
+
+	planCmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show which server jars a manifest would download or replace",
+		Long: `Resolve the desired build for every server directory listed in a manifest
+and compare it with the files on disk.
+
+Lines starting with "+" are files that will be created, lines starting
+with "~" are files that will be replaced. The command exits with status 2
+when any file differs from the manifest, so it can be used to detect drift.
+
+Example manifest:
+
+  servers:
+    - name: lobby
+      dir: servers/lobby
+      project: paper
+      version: 1.21.x
+      channel: stable
+    - name: proxy
+      dir: servers/proxy
+      project: velocity
+      file: velocity.jar`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := manifest.Load(manifestFile)
+			if err != nil {
+				return fail("loading manifest", err)
+			}
+
+			plan, err := manifest.Compute(cmd.Context(), a.newClient(), m)
+			if err != nil {
+				return fail("computing plan", err)
+			}
+
+			if err := plan.Write(cmd.OutOrStdout()); err != nil {
+				return fail("", err)
+			}
+
+			if plan.HasDrift() {
+				return ErrDrift
+			}
+
+			return nil
+		},
+	}
+
+	planCmd.Flags().StringVarP(&manifestFile, "file", "f", "servers.yaml", "Path to the server manifest")
+
+	return planCmd
+}
+
+// newApplyCmd creates the apply command.
+func newApplyCmd(a *app) *cobra.Command {
+	var manifestFile string
+
+	applyCmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Download and replace server jars to match a manifest",
+		Long: `Compute the same plan as "papermc plan" and execute it.
+
+Every distinct artifact is downloaded only once, verified against its
+SHA256 checksum and atomically moved into place; servers sharing an
+artifact receive a copy of the verified file.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := manifest.Load(manifestFile)
+			if err != nil {
+				return fail("loading manifest", err)
+			}
+
+			client := a.newClient()
+			ctx := cmd.Context()
+			out := cmd.OutOrStdout()
+
+			plan, err := manifest.Compute(ctx, client, m)
+			if err != nil {
+				return fail("computing plan", err)
+			}
+
+			if err := plan.Write(out); err != nil {
+				return fail("", err)
+			}
+
+			if !plan.HasDrift() {
+				return nil
+			}
+
+			fmt.Fprintln(out)
+
+			results, err := manifest.Apply(ctx, client, plan)
+			for _, result := range results {
+				if result.Source != "" {
+					fmt.Fprintf(out, "Copied %s from %s\n", result.Change.Path, result.Source)
+				} else {
+					fmt.Fprintf(out, "Downloaded %s\n", result.Change.Path)
+				}
+			}
+			if err != nil {
+				return fail("applying plan", err)
+			}
+
+			return nil
+		},
+	}
+
+	applyCmd.Flags().StringVarP(&manifestFile, "file", "f", "servers.yaml", "Path to the server manifest")
+
+	return applyCmd
+}
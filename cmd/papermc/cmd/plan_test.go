@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api/apitest"
+)
+
+func TestPlanAndApply(t *testing.T) {
+	server := newAPIServer(t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "servers.yaml")
+	m := `servers:
+  - name: lobby
+    dir: lobby
+    project: paper
+    version: 1.21.x
+  - name: survival
+    dir: survival
+    project: paper
+    version: 1.21.x
+  - name: proxy
+    dir: proxy
+    project: velocity
+    file: velocity.jar
+`
+	if err := os.WriteFile(file, []byte(m), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, _, err := executeServer(t, server, "plan", "-f", file)
+	if !errors.Is(err, ErrDrift) {
+		t.Fatalf("Expected drift before apply, got %v\n%s", err, out)
+	}
+	for _, want := range []string{"+ lobby:", "(paper 1.21.11 build 75, STABLE)", "+ proxy:", "Plan: 3 to create, 0 to replace, 0 unchanged."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected the plan to contain %q, got\n%s", want, out)
+		}
+	}
+
+	out, _, err = executeServer(t, server, "apply", "-f", file)
+	if err != nil {
+		t.Fatalf("Apply failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Copied ") {
+		t.Errorf("Expected the shared jar to be downloaded once, got\n%s", out)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "survival", "server.jar"))
+	if err != nil || !bytes.Equal(data, apitest.DefaultJar("paper", "1.21.11", 75)) {
+		t.Errorf("Expected survival to get build 75 (%v)", err)
+	}
+
+	out, _, err = executeServer(t, server, "plan", "-f", file)
+	if err != nil || !strings.Contains(out, "Plan: 0 to create, 0 to replace, 3 unchanged.") {
+		t.Errorf("Expected no drift after apply, got %v\n%s", err, out)
+	}
+}
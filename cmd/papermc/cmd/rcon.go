@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/rcon"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+	"github.com/spf13/cobra"
+)
+
+// newRCONCmd creates the rcon command.
+func newRCONCmd(a *app) *cobra.Command {
+	rconCmd := &cobra.Command{
+		Use:   "rcon DIR|HOST[:PORT] COMMAND...",
+		Short: "Run a console command on a running server",
+		Long: `Run a console command on a running server over RCON and print its output.
+
+If the first argument is a server directory, the address and password are
+read from its server.properties (enable-rcon, rcon.port, rcon.password and
+server-ip). Otherwise it is the server's host, with port 25575 by default,
+and the password is taken from --password or rcon_password in the config
+(PAPERMC_RCON_PASSWORD).
+
+Examples:
+  papermc rcon ./server list
+  papermc rcon mc.example.com:25575 say Hello --password=secret`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := a.rconConfig(args[0])
+			if err != nil {
+				return fail("reading RCON settings", err)
+			}
+
+			ctx := cmd.Context()
+
+			client, err := rcon.Dial(ctx, cfg.Address, cfg.Password)
+			if err != nil {
+				return fail("connecting to RCON", err)
+			}
+			defer func() { _ = client.Close() }()
+
+			out, err := client.Command(ctx, strings.Join(args[1:], " "))
+			if err != nil {
+				return fail("running command", err)
+			}
+
+			if out != "" {
+				fmt.Fprintln(cmd.OutOrStdout(), strings.TrimRight(out, "\n"))
+			}
+
+			return nil
+		},
+	}
+
+	rconCmd.Flags().String("password", "", "RCON password when connecting to a host")
+	_ = a.config.BindPFlag("rcon_password", rconCmd.Flags().Lookup("password"))
+
+	return rconCmd
+}
+
+// rconConfig returns the RCON settings of a server directory or host.
+func (a *app) rconConfig(target string) (*rcon.Config, error) {
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		return rcon.ServerConfig(target)
+	}
+
+	addr := target
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		addr = net.JoinHostPort(target, strconv.Itoa(rcon.DefaultPort))
+	}
+
+	password := a.config.GetString("rcon_password")
+	if password == "" {
+		return nil, errors.New("no RCON password, use --password")
+	}
+
+	return &rcon.Config{Address: addr, Password: password}, nil
+}
+
+// rconFlags configure the RCON sequence run before an update.
+type rconFlags struct {
+	enabled bool
+	seq     rcon.Sequence
+}
+
+// addRCONFlags adds the flags of the pre-update RCON sequence.
+func addRCONFlags(c *cobra.Command) *rconFlags {
+	f := &rconFlags{}
+	flags := c.Flags()
+	flags.BoolVar(&f.enabled, "rcon", false, "Stop the running server over RCON before replacing the jar")
+	flags.DurationVar(&f.seq.Countdown, "rcon-countdown", rcon.DefaultCountdown, "How long players are warned before the server stops")
+	flags.StringVar(&f.seq.Message, "rcon-message", rcon.DefaultMessage, "Countdown broadcast; %s is the time left")
+	flags.StringArrayVar(&f.seq.Commands, "rcon-command", rcon.DefaultCommands, "Command run after the countdown (repeatable)")
+
+	return f
+}
+
+// beforeInstall returns the update hook stopping the server in dir, or nil
+// if the sequence is disabled. A server that is not running is updated
+// without stopping it.
+func (f *rconFlags) beforeInstall(dir string, stderr io.Writer) func(ctx context.Context, from, to *serverdir.Install) error {
+	if !f.enabled {
+		return nil
+	}
+
+	return func(ctx context.Context, from, to *serverdir.Install) error {
+		cfg, err := rcon.ServerConfig(dir)
+		if err != nil {
+			return err
+		}
+
+		client, err := rcon.Dial(ctx, cfg.Address, cfg.Password)
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			fmt.Fprintf(stderr, "Server at %s is not running, updating without stopping it\n", cfg.Address)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer func() { _ = client.Close() }()
+
+		fmt.Fprintf(stderr, "Stopping server at %s for the update to %s build %d (countdown %s)\n",
+			cfg.Address, to.Version, to.Build, f.seq.Countdown)
+
+		return f.seq.Run(ctx, client)
+	}
+}
@@ -1,90 +1,289 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/lexfrei/goPaperMC/pkg/api"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var (
+// Deps are the dependencies of a command tree. Zero values are replaced
+// with the process defaults, so Deps{} gives the regular CLI.
+type Deps struct {
+	// NewClient creates the API clients used by commands. The default
+	// creates a client for the configured base URL.
+	NewClient func() *api.Client
+
+	// Logger receives diagnostic records. The default writes to Stderr at
+	// the level and in the format selected by --verbose, --debug and
+	// --log-format.
+	Logger *slog.Logger
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// app holds the state shared by the commands of one command tree.
+type app struct {
+	deps    Deps
+	config  *viper.Viper
 	cfgFile string
-	limit   int
-	channel string
-)
+	logger  *slog.Logger
+}
+
+// NewRootCommand creates the papermc command tree. Each call returns an
+// independent tree with its own configuration, so it can be embedded in
+// other programs or executed repeatedly in tests.
+func NewRootCommand(deps Deps) *cobra.Command {
+	a := &app{deps: deps, config: viper.New()}
 
-// rootCmd represents the base command when called without any subcommands
-var rootCmd = &cobra.Command{
-	Use:   "papermc",
-	Short: "PaperMC CLI - Command line interface for PaperMC API",
-	Long: `A command line tool to interact with the PaperMC API. 
+	root := &cobra.Command{
+		Use:   "papermc",
+		Short: "PaperMC CLI - Command line interface for PaperMC API",
+		Long: `A command line tool to interact with the PaperMC API.
 It allows you to list projects, versions, and builds, as well as
 download or get download URLs for PaperMC artifacts.`,
-	SilenceUsage: true,
-}
-
-// Execute adds all child commands to the root command and sets flags appropriately.
-// This is called by main.main(). It only needs to happen once to the rootCmd.
-func Execute() {
-	err := rootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return a.initConfig(cmd)
+		},
 	}
-}
 
-func init() {
-	cobra.OnInitialize(initConfig)
+	if deps.Stdin != nil {
+		root.SetIn(deps.Stdin)
+	}
+	if deps.Stdout != nil {
+		root.SetOut(deps.Stdout)
+	}
+	if deps.Stderr != nil {
+		root.SetErr(deps.Stderr)
+	}
 
 	// Global flags
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.papermc.yaml)")
-	rootCmd.PersistentFlags().IntVar(&limit, "limit", 0, "limit the number of items to show (0 means no limit)")
-	rootCmd.PersistentFlags().StringVar(&channel, "channel", "", "filter by channel (alpha, beta, stable, recommended)")
+	flags := root.PersistentFlags()
+	flags.StringVar(&a.cfgFile, "config", "", "config file (default is $HOME/.papermc.yaml)")
+	flags.Int("limit", 0, "limit the number of items to show (0 means no limit)")
+	flags.String("channel", "", "filter by channel (alpha, beta, stable, recommended)")
+	flags.String("base-url", api.DefaultBaseURL, "base URL of the API, a proxy or a file:// mirror")
+	flags.BoolP("verbose", "v", false, "log progress to stderr")
+	flags.Bool("debug", false, "log every API request to stderr")
+	flags.String("log-format", "text", "format of log records (text, json)")
 
-	// Bind flags to viper
-	_ = viper.BindPFlag("limit", rootCmd.PersistentFlags().Lookup("limit"))
-	_ = viper.BindPFlag("channel", rootCmd.PersistentFlags().Lookup("channel"))
+	// Bind flags to the configuration
+	_ = a.config.BindPFlag("limit", flags.Lookup("limit"))
+	_ = a.config.BindPFlag("channel", flags.Lookup("channel"))
+	_ = a.config.BindPFlag("base_url", flags.Lookup("base-url"))
+	_ = a.config.BindPFlag("verbose", flags.Lookup("verbose"))
+	_ = a.config.BindPFlag("debug", flags.Lookup("debug"))
+	_ = a.config.BindPFlag("log_format", flags.Lookup("log-format"))
 
 	// Register channel flag completion
-	_ = rootCmd.RegisterFlagCompletionFunc("channel", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	_ = root.RegisterFlagCompletionFunc("channel", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return []string{"alpha", "beta", "stable", "recommended"}, cobra.ShellCompDirectiveNoFileComp
 	})
+	_ = root.RegisterFlagCompletionFunc("log-format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	root.AddCommand(
+		newListCmd(a),
+		newURLCmd(a),
+		newDownloadCmd(a),
+		newCICmd(a),
+		newPlanCmd(a),
+		newApplyCmd(a),
+		newInitCmd(a),
+		newNetworkCmd(a),
+		newUpdateCmd(a),
+		newRollbackCmd(a),
+		newBackupCmd(a),
+		newStartScriptCmd(a),
+		newRunCmd(a),
+		newRCONCmd(a),
+		newPingCmd(a),
+		newFleetCmd(a),
+		newJavaCmd(a),
+		newIdentifyCmd(a),
+		newInspectCmd(a),
+		newChangelogCmd(a),
+		newSearchCommitsCmd(a),
+		newWatchCmd(a),
+		newNotifyCmd(a),
+		newExporterCmd(a),
+		newServeCmd(a),
+		newMirrorCmd(a),
+		newCompletionCmd(a),
+		newVersionCmd(a),
+	)
+
+	return root
+}
+
+// Execute runs the papermc command tree and exits with its status. This is
+// called by main.main().
+func Execute() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	root := NewRootCommand(Deps{})
+	err := root.ExecuteContext(ctx)
+	stop()
+
+	if err != nil {
+		if msg := err.Error(); msg != "" {
+			fmt.Fprintf(root.ErrOrStderr(), "Error: %s\n", msg)
+		}
+		os.Exit(ExitCode(err))
+	}
 }
 
 // initConfig reads in config file and ENV variables if set.
-func initConfig() {
-	if cfgFile != "" {
+func (a *app) initConfig(cmd *cobra.Command) error {
+	if a.cfgFile != "" {
 		// Use config file from the flag.
-		viper.SetConfigFile(cfgFile)
+		a.config.SetConfigFile(a.cfgFile)
 	} else {
 		// Find home directory.
 		home, err := os.UserHomeDir()
-		cobra.CheckErr(err)
+		if err != nil {
+			return fail("finding home directory", err)
+		}
 
 		// Search config in home directory with name ".papermc" (without extension).
-		viper.AddConfigPath(home)
-		viper.AddConfigPath(".")
-		viper.SetConfigType("yaml")
-		viper.SetConfigName(".papermc")
+		a.config.AddConfigPath(home)
+		a.config.AddConfigPath(".")
+		a.config.SetConfigType("yaml")
+		a.config.SetConfigName(".papermc")
 	}
 
-	viper.SetEnvPrefix("PAPERMC")
-	viper.AutomaticEnv() // read in environment variables that match
+	a.config.SetEnvPrefix("PAPERMC")
+	a.config.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		if viper.GetBool("verbose") {
-			fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
-		}
+	readErr := a.config.ReadInConfig()
+
+	logger, err := a.newLogger(cmd.ErrOrStderr())
+	if err != nil {
+		return err
+	}
+	a.logger = logger
+
+	if readErr == nil {
+		a.logger.Info("using config file", "path", a.config.ConfigFileUsed())
+	}
+
+	return nil
+}
+
+// newLogger returns the injected logger or creates one writing to w at the
+// level and in the format selected by the configuration. Without --verbose
+// or --debug only warnings are logged.
+func (a *app) newLogger(w io.Writer) (*slog.Logger, error) {
+	if a.deps.Logger != nil {
+		return a.deps.Logger, nil
+	}
+
+	level := slog.LevelWarn
+	switch {
+	case a.config.GetBool("debug"):
+		level = slog.LevelDebug
+	case a.config.GetBool("verbose"):
+		level = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	switch format := a.config.GetString("log_format"); format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fail(fmt.Sprintf("unknown log format %q, expected text or json", format), nil)
+	}
+}
+
+// limit returns the limit set from flags or config.
+func (a *app) limit() int {
+	return a.config.GetInt("limit")
+}
+
+// channel returns the channel filter set from flags or config.
+func (a *app) channel() api.Channel {
+	return api.Channel(a.config.GetString("channel"))
+}
+
+// newClient returns an API client using the configured base URL and
+// logger.
+func (a *app) newClient() *api.Client {
+	var client *api.Client
+	if a.deps.NewClient != nil {
+		client = a.deps.NewClient()
+	} else {
+		client = api.NewClient().WithBaseURL(a.config.GetString("base_url"))
+	}
+
+	if client.Logger == nil {
+		client.WithLogger(a.logger)
+	}
+
+	return client
+}
+
+// metadataCacheTTL is how long cached metadata is trusted by commands that
+// scan many builds.
+const metadataCacheTTL = time.Hour
+
+// newCachingClient returns an API client that caches metadata responses in
+// the user cache directory, unless noCache is set.
+func (a *app) newCachingClient(noCache bool) *api.Client {
+	client := a.newClient()
+	if dir := a.cacheDir(); dir != "" && !noCache {
+		client.WithCache(api.NewFileCache(dir, metadataCacheTTL))
 	}
+
+	return client
 }
 
-// GetLimit returns the limit set from flags or config.
-func GetLimit() int {
-	return viper.GetInt("limit")
+// cacheDir returns the directory used to cache API metadata, or an empty
+// string if no suitable directory is available.
+func (a *app) cacheDir() string {
+	if dir := a.config.GetString("cache_dir"); dir != "" {
+		return dir
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "papermc")
 }
 
-// GetChannel returns the channel filter set from flags or config.
-func GetChannel() string {
-	return viper.GetString("channel")
+// runtimesDir returns the directory Java runtimes are installed into, or an
+// empty string if no suitable directory is available.
+func (a *app) runtimesDir() string {
+	if dir := a.config.GetString("runtimes_dir"); dir != "" {
+		return dir
+	}
+
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "papermc", "runtimes")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".local", "share", "papermc", "runtimes")
 }
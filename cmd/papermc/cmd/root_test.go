@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/api/apitest"
+)
+
+// execute runs a command tree whose clients use transport and returns what
+// it wrote to stdout.
+func execute(t *testing.T, transport http.RoundTripper, args ...string) (string, error) {
+	t.Helper()
+
+	stdout, _, err := executeWithStderr(t, transport, args...)
+
+	return stdout, err
+}
+
+// executeWithStderr is like execute but also returns what the command tree
+// wrote to stderr.
+func executeWithStderr(t *testing.T, transport http.RoundTripper, args ...string) (string, string, error) {
+	t.Helper()
+
+	return run(t, func() *api.Client {
+		client := api.NewClient()
+		client.HTTPClient.Transport = transport
+		return client
+	}, args...)
+}
+
+// executeServer runs a command tree whose clients use server and returns
+// what it wrote to stdout and stderr.
+func executeServer(t *testing.T, server *apitest.Server, args ...string) (string, string, error) {
+	t.Helper()
+
+	return run(t, server.Client, args...)
+}
+
+// run runs a command tree whose clients are created by newClient.
+func run(t *testing.T, newClient func() *api.Client, args ...string) (string, string, error) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	root := NewRootCommand(Deps{
+		NewClient: newClient,
+		Stdout:    &stdout,
+		Stderr:    &stderr,
+	})
+	root.SetArgs(args)
+
+	err := root.ExecuteContext(context.Background())
+
+	return stdout.String(), stderr.String(), err
+}
+
+// newAPIServer serves two builds of paper 1.21.11, and velocity 3.1.1 and
+// 3.4.0-SNAPSHOT, its newest release.
+func newAPIServer(t *testing.T) *apitest.Server {
+	t.Helper()
+
+	return apitest.NewServer(t, apitest.NewBuilder().
+		Project("paper").
+		Version("1.21", "1.21.11").
+		Build(74, api.ChannelStable).
+		Build(75, api.ChannelStable).
+		Project("velocity").
+		Version("3.0.0", "3.1.1").
+		Build(102, api.ChannelStable).
+		Version("3.0.0", "3.4.0-SNAPSHOT").
+		Build(500, api.ChannelStable))
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/java"
+	"github.com/lexfrei/goPaperMC/pkg/launch"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+	"github.com/lexfrei/goPaperMC/pkg/supervisor"
+	"github.com/spf13/cobra"
+)
+
+// newRunCmd creates the run command.
+func newRunCmd(a *app) *cobra.Command {
+	var (
+		jar         string
+		javaPath    string
+		heap        string
+		jvmFlags    []string
+		policy      string
+		update      bool
+		withBackup  bool
+		updateOpts  serverdir.UpdateOptions
+		maxRestarts int
+		stopCommand string
+		stopTimeout time.Duration
+	)
+
+	runCmd := &cobra.Command{
+		Use:   "run DIR",
+		Short: "Run the server in a directory and restart it when it crashes",
+		Long: `Run the server jar in DIR in the foreground, forwarding the console to
+the terminal, and restart it with backoff when it exits with an error.
+
+The server runs on the Java executable given with --java, or the runtime
+recorded by "papermc java install --dir", or $JAVA_HOME/bin/java, with the
+JVM flags the API recommends for the installed version.
+
+On SIGTERM or Ctrl-C, --stop-command is sent to the console so the worlds
+are saved; the server is killed if it has not stopped within
+--stop-timeout. With --restart=on-failure (the default), a server stopped
+from the console is not restarted.
+
+With --update, a newer build is installed (like "papermc update") before
+every restart; with --backup, or backup_on_update set in the config, the
+worlds are backed up before a new build is installed.
+
+Examples:
+  papermc run ./server --heap=4G
+  papermc run /srv/lobby --update --restart=always --max-restarts=5`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := filepath.Abs(args[0])
+			if err != nil {
+				return fail("resolving directory", err)
+			}
+
+			restart, err := supervisor.ParsePolicy(policy)
+			if err != nil {
+				return fail("parsing --restart", err)
+			}
+
+			if _, err := os.Stat(filepath.Join(dir, jar)); err != nil {
+				return fail("finding server jar", err)
+			}
+
+			command := func(ctx context.Context) (*supervisor.Command, error) {
+				opts := a.launchOptions(ctx, dir, jar)
+				opts.Heap = heap
+				opts.Flags = append(opts.Flags, jvmFlags...)
+
+				opts.Java = javaPath
+				if opts.Java == "" {
+					pinned, err := java.Pinned(dir)
+					switch {
+					case err == nil:
+						opts.Java = pinned.Executable
+					case !errors.Is(err, os.ErrNotExist):
+						return nil, err
+					}
+				}
+
+				path, args := launch.CommandLine(opts)
+
+				return &supervisor.Command{Path: path, Args: args}, nil
+			}
+
+			s := supervisor.New(dir, command)
+			s.Stdin = cmd.InOrStdin()
+			s.Stdout = cmd.OutOrStdout()
+			s.Stderr = cmd.ErrOrStderr()
+			s.StopCommand = stopCommand
+			s.StopTimeout = stopTimeout
+			s.Policy = restart
+			s.MaxRestarts = maxRestarts
+			s.Logger = a.logger
+
+			if update {
+				updateOpts.Jar = jar
+				updateOpts.Channel = a.channel()
+				if updateOpts.BeforeInstall, err = a.backupHook(dir, withBackup, cmd.ErrOrStderr()); err != nil {
+					return err
+				}
+
+				s.BeforeRestart = func(ctx context.Context) error {
+					result, err := serverdir.Update(ctx, a.newClient(), dir, updateOpts)
+					if err != nil {
+						return err
+					}
+
+					if result.Updated {
+						fmt.Fprintf(cmd.ErrOrStderr(), "Updated %s %s build %d -> %s build %d\n", result.Current.Project,
+							result.Previous.Version, result.Previous.Build, result.Current.Version, result.Current.Build)
+					}
+
+					return nil
+				}
+			}
+
+			if err := s.Run(cmd.Context()); err != nil {
+				return fail("running server in "+dir, err)
+			}
+
+			return nil
+		},
+	}
+
+	flags := runCmd.Flags()
+	flags.StringVar(&jar, "jar", serverdir.DefaultJar, "Name of the server jar inside DIR")
+	flags.StringVar(&javaPath, "java", "", "Java executable (default the recorded runtime, $JAVA_HOME/bin/java or java)")
+	flags.StringVar(&heap, "heap", "", "Heap size, e.g. 4G (default chosen by the JVM)")
+	flags.StringArrayVar(&jvmFlags, "jvm-flag", nil, "Additional JVM flag (repeatable)")
+	flags.StringVar(&stopCommand, "stop-command", supervisor.DefaultStopCommand, "Console command stopping the server")
+	flags.DurationVar(&stopTimeout, "stop-timeout", supervisor.DefaultStopTimeout, "Time to wait for the server to stop before killing it")
+	flags.StringVar(&policy, "restart", string(supervisor.RestartOnFailure), "When to restart the server (on-failure, always, never)")
+	flags.IntVar(&maxRestarts, "max-restarts", 0, "Consecutive restarts before giving up (0 means no limit)")
+	flags.BoolVar(&update, "update", false, "Install a newer build before every restart")
+	flags.StringVar(&updateOpts.Version, "version", "", "Version constraint for --update (default: installed version)")
+	flags.BoolVar(&withBackup, "backup", false, "Back up the worlds before --update installs a new build")
+
+	_ = runCmd.RegisterFlagCompletionFunc("restart", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"on-failure", "always", "never"}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	return runCmd
+}
+
+// launchOptions returns the launch options recommended for the build
+// recorded in a server directory. Without a lock file or when the API
+// cannot be reached, the jar runs without recommended flags.
+func (a *app) launchOptions(ctx context.Context, dir, jar string) launch.Options {
+	opts := launch.Options{Args: []string{"--nogui"}}
+
+	lock, err := serverdir.ReadLock(dir)
+	if err != nil {
+		a.logger.Warn("no recorded build, running without recommended JVM flags", "error", err.Error())
+	} else if meta, err := a.newCachingClient(false).GetVersion(ctx, lock.Project, lock.Version); err != nil {
+		a.logger.Warn("getting recommended JVM flags failed", "error", err.Error())
+		if lock.Project == "velocity" {
+			opts.Args = nil
+		}
+	} else {
+		opts = launch.ForVersion(lock.Project, meta.Version)
+	}
+
+	opts.Jar = jar
+
+	return opts
+}
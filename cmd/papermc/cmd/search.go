@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/spf13/cobra"
+)
+
+// newSearchCommitsCmd creates the search-commits command.
+func newSearchCommitsCmd(a *app) *cobra.Command {
+	var (
+		opts       api.CommitSearchOptions
+		since      string
+		until      string
+		format     string
+		ignoreCase bool
+		noCache    bool
+	)
+
+	searchCommitsCmd := &cobra.Command{
+		Use:   "search-commits PROJECT_ID QUERY",
+		Short: "Find the builds containing commits that match a query",
+		Long: `Search the commit messages of all builds of a project for a regular
+expression and report, for each matching commit, the earliest build that
+contains it.
+
+Build lists are scanned in parallel and cached for an hour in the user
+cache directory.
+
+Examples:
+  papermc search-commits paper 'dupe' --family=1.21
+  papermc search-commits paper '(?i)fix.*hopper' --since=2025-01-01 --format=json`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			projectID, query := args[0], args[1]
+			if ignoreCase {
+				query = "(?i)" + query
+			}
+
+			var err error
+			if opts.Pattern, err = regexp.Compile(query); err != nil {
+				return fail("parsing query", err)
+			}
+			if opts.Since, err = parseDate(since); err != nil {
+				return fail("parsing --since", err)
+			}
+			if opts.Until, err = parseDate(until); err != nil {
+				return fail("parsing --until", err)
+			}
+
+			matches, err := a.newCachingClient(noCache).SearchCommits(cmd.Context(), projectID, opts)
+			if err != nil {
+				return fail("searching commits", err)
+			}
+
+			if format == "json" {
+				return writeJSON(cmd, matches)
+			}
+
+			for _, match := range matches {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %s %s build %d (%s) %s\n", shortCommit(match.Commit.SHA),
+					match.Commit.Time.Format("2006-01-02"), match.Version, match.Build, match.Channel,
+					firstLine(match.Commit.Message))
+			}
+
+			return nil
+		},
+	}
+
+	flags := searchCommitsCmd.Flags()
+	flags.StringVar(&opts.Family, "family", "", "Only search versions of this family, e.g. 1.21")
+	flags.StringVar(&opts.Version, "version", "", "Only search versions matching this constraint, e.g. 1.21.x")
+	flags.StringVar(&since, "since", "", "Only report commits made on or after this date")
+	flags.StringVar(&until, "until", "", "Only report commits made before this date")
+	flags.StringVar(&format, "format", "text", "Output format (text, json)")
+	flags.BoolVarP(&ignoreCase, "ignore-case", "i", false, "Match case-insensitively")
+	flags.IntVar(&opts.Concurrency, "concurrency", api.DefaultSearchConcurrency, "Number of versions scanned in parallel")
+	flags.BoolVar(&noCache, "no-cache", false, "Do not use cached build lists")
+
+	return searchCommitsCmd
+}
+
+// parseDate parses a date (2006-01-02) or an RFC 3339 timestamp. An empty
+// string yields the zero time.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.Newf("invalid date %q, expected YYYY-MM-DD or RFC 3339", s)
+	}
+
+	return t, nil
+}
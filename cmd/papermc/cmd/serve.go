@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/proxy"
+	"github.com/spf13/cobra"
+)
+
+// newServeCmd creates the serve command.
+func newServeCmd(a *app) *cobra.Command {
+	var (
+		listen    string
+		upstream  string
+		publicURL string
+		jarDir    string
+		ttl       time.Duration
+		trust     bool
+	)
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run a caching proxy for the PaperMC API",
+		Long: `Serve the same /v3/... endpoints as the PaperMC API, forwarding misses
+upstream. Metadata is cached in memory for --ttl; jars are downloaded once,
+verified and kept in --jar-dir under their SHA256 checksum. Download URLs in
+responses are rewritten to point at the proxy.
+
+Download URLs point at the address a request was received on. Use
+--public-url when clients reach the proxy through another address, e.g.
+behind a load balancer or a container port mapping, or --trust-forwarded
+to take it from the Host and X-Forwarded-Proto headers set by a reverse
+proxy. Only trust them when the reverse proxy overwrites them.
+
+The proxy also serves /healthz and cache statistics as JSON on /stats.
+
+Examples:
+  papermc serve --listen=:8080
+  papermc serve --public-url=https://papermc.build.internal --jar-dir=/var/cache/papermc`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := jarDir
+			if dir == "" {
+				cache := a.cacheDir()
+				if cache == "" {
+					return fail("no cache directory available, use --jar-dir", nil)
+				}
+				dir = filepath.Join(cache, "jars")
+			}
+
+			server := &http.Server{
+				Addr:              listen,
+				Handler:           proxy.New(upstream, dir, ttl).WithPublicURL(publicURL).WithTrustForwarded(trust),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+
+			ctx := cmd.Context()
+			go func() {
+				<-ctx.Done()
+				_ = server.Shutdown(context.Background())
+			}()
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "Proxying %s on %s\n", upstream, listen)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fail("serving", err)
+			}
+
+			return nil
+		},
+	}
+
+	flags := serveCmd.Flags()
+	flags.StringVar(&listen, "listen", ":8080", "Address to listen on")
+	flags.StringVar(&upstream, "upstream", api.DefaultBaseURL, "Base URL of the upstream API")
+	flags.StringVar(&publicURL, "public-url", "", "Base URL of the proxy used in download URLs (default: the address requests are received on)")
+	flags.BoolVar(&trust, "trust-forwarded", false, "Derive download URLs from the Host and X-Forwarded-Proto headers")
+	flags.StringVar(&jarDir, "jar-dir", "", "Directory to cache jars in (default in the user cache directory)")
+	flags.DurationVar(&ttl, "ttl", proxy.DefaultMetadataTTL, "Time metadata responses are cached")
+
+	return serveCmd
+}
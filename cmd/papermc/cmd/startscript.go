@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/internal/atomicfile"
+	"github.com/lexfrei/goPaperMC/pkg/java"
+	"github.com/lexfrei/goPaperMC/pkg/launch"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+	"github.com/spf13/cobra"
+)
+
+// newStartScriptCmd creates the start-script command.
+func newStartScriptCmd(a *app) *cobra.Command {
+	var (
+		opts    launch.Options
+		systemd bool
+		user    string
+		force   bool
+	)
+
+	startScriptCmd := &cobra.Command{
+		Use:   "start-script DIR",
+		Short: "Generate a start script for the server in a directory",
+		Long: `Generate start.sh for the build installed in a server directory, using
+the JVM flags the API recommends for its version.
+
+The script refuses to launch when the Java version is older than the
+version's minimum. Without --heap, the heap is sized at launch to
+--heap-percent of the cgroup memory limit, or of the host memory if there
+is no limit, so the same script works in containers and systemd services.
+Without --java, the runtime recorded by "papermc java install --dir" is
+used if there is one.
+JAVA, HEAP and HEAP_PERCENT in the environment override the generated
+values.
+
+With --systemd, a unit file named after the directory is written next to
+the script; link it into /etc/systemd/system to install it.
+
+Examples:
+  papermc start-script ./server --heap=4G
+  papermc start-script /srv/lobby --systemd --user=minecraft`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := filepath.Abs(args[0])
+			if err != nil {
+				return fail("resolving directory", err)
+			}
+
+			client := a.newClient()
+			ctx := cmd.Context()
+
+			install, err := serverdir.Detect(ctx, client, dir, serverdir.DetectOptions{Jar: opts.Jar, Project: opts.Project})
+			if err != nil {
+				return fail("detecting installed build", err)
+			}
+
+			version, err := client.GetVersion(ctx, install.Project, install.Version)
+			if err != nil {
+				return fail("getting version info", err)
+			}
+
+			generated := launch.ForVersion(install.Project, version.Version)
+			generated.Jar = install.File
+			generated.Java = opts.Java
+			if generated.Java == "" {
+				pinned, err := java.Pinned(dir)
+				switch {
+				case err == nil:
+					generated.Java = pinned.Executable
+				case !errors.Is(err, os.ErrNotExist):
+					return fail("reading recorded Java runtime", err)
+				}
+			}
+			generated.Heap = opts.Heap
+			generated.HeapPercent = opts.HeapPercent
+
+			script, err := launch.Script(generated)
+			if err != nil {
+				return fail("generating start script", err)
+			}
+
+			files := map[string][]byte{filepath.Join(dir, launch.ScriptFile): script}
+			if systemd {
+				unit, err := launch.SystemdUnit(launch.Unit{
+					Description: fmt.Sprintf("%s %s server in %s", install.Project, install.Version, dir),
+					Dir:         dir,
+					User:        user,
+				})
+				if err != nil {
+					return fail("generating systemd unit", err)
+				}
+				files[filepath.Join(dir, filepath.Base(dir)+".service")] = unit
+			}
+
+			if !force {
+				for path := range files {
+					if fileExists(path) {
+						return fail(path+" already exists, use --force to overwrite it", nil)
+					}
+				}
+			}
+
+			for path, data := range files {
+				perm := os.FileMode(0o644)
+				if filepath.Base(path) == launch.ScriptFile {
+					perm = 0o755
+				}
+
+				if err := atomicfile.WriteFile(path, data, perm); err != nil {
+					return fail("writing "+path, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s\n", path)
+			}
+
+			return nil
+		},
+	}
+
+	flags := startScriptCmd.Flags()
+	flags.StringVar(&opts.Jar, "jar", serverdir.DefaultJar, "Name of the server jar inside DIR")
+	flags.StringVar(&opts.Project, "project", "", "Project to search if the installed jar is not recorded (default paper)")
+	flags.StringVar(&opts.Java, "java", "", "Java executable (default the recorded runtime, $JAVA_HOME/bin/java or java at launch)")
+	flags.StringVar(&opts.Heap, "heap", "", "Fixed heap size, e.g. 4G (default sized at launch)")
+	flags.IntVar(&opts.HeapPercent, "heap-percent", launch.DefaultHeapPercent, "Share of the memory limit used as heap when sized at launch")
+	flags.BoolVar(&systemd, "systemd", false, "Also write a systemd unit running the script")
+	flags.StringVar(&user, "user", "", "User the systemd service runs as")
+	flags.BoolVarP(&force, "force", "f", false, "Overwrite existing files")
+
+	return startScriptCmd
+}
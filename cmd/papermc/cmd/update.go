@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+	"github.com/spf13/cobra"
+)
+
+// newUpdateCmd creates the update command.
+func newUpdateCmd(a *app) *cobra.Command {
+	var (
+		opts       serverdir.UpdateOptions
+		withBackup bool
+	)
+
+	updateCmd := &cobra.Command{
+		Use:   "update DIR",
+		Short: "Update the server jar in a directory to the newest build",
+		Long: `Detect the build installed in a server directory, download the newest
+build allowed by the version and channel policy and replace the jar in place.
+
+The installed build is read from the directory's papermc.lock file or, if
+there is none, looked up by the jar's SHA256 checksum. The replaced jar is
+kept in .papermc/backups so "papermc rollback" can restore it.
+
+By default only newer builds of the installed version are considered; use
+--version to move to another version (for example --version=1.21.x) and
+--channel to restrict the channel.
+
+With --rcon, a running server is stopped over RCON once the new build is
+downloaded: players are warned with a countdown, then the --rcon-command
+commands (save-all flush and stop by default) are run and the jar is only
+replaced after the server has gone down. RCON settings are read from the
+directory's server.properties.
+
+With --backup, or backup_on_update set in the config, the worlds are backed
+up like "papermc backup" does, after the server has been stopped and before
+the jar is replaced; the update is aborted if the backup fails, or if the
+server is still running without --rcon.`,
+		Args: cobra.ExactArgs(1),
+	}
+
+	stop := addRCONFlags(updateCmd)
+
+	updateCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		dir := args[0]
+		opts.Channel = a.channel()
+		backupHook, err := a.backupHook(dir, withBackup, cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+		if backupHook != nil && !stop.enabled {
+			backupHook = chainHooks(requireStopped(dir), backupHook)
+		}
+		opts.BeforeInstall = chainHooks(stop.beforeInstall(dir, cmd.ErrOrStderr()), backupHook)
+
+		client := a.newClient()
+		ctx := cmd.Context()
+
+		result, err := serverdir.Update(ctx, client, dir, opts)
+		if err != nil {
+			return fail("updating "+dir, err)
+		}
+
+		a.warnJava(ctx, cmd.ErrOrStderr(), client, result.Current.Project, result.Current.Version)
+
+		out := cmd.OutOrStdout()
+		if !result.Updated {
+			fmt.Fprintf(out, "%s %s build %d is up to date\n",
+				result.Current.Project, result.Current.Version, result.Current.Build)
+			return nil
+		}
+
+		fmt.Fprintf(out, "Updated %s %s build %d -> %s build %d\n", result.Current.Project,
+			result.Previous.Version, result.Previous.Build, result.Current.Version, result.Current.Build)
+
+		return nil
+	}
+
+	flags := updateCmd.Flags()
+	flags.StringVar(&opts.Jar, "jar", serverdir.DefaultJar, "Name of the server jar inside DIR")
+	flags.StringVar(&opts.Project, "project", "", "Project to search if the installed jar is not recorded (default paper)")
+	flags.StringVar(&opts.Version, "version", "", "Target version constraint, e.g. 1.21.11, 1.21.x or latest (default: installed version)")
+	flags.StringVar(&opts.Artifact, "artifact", api.DefaultDownloadKey, "Download key of the artifact to install")
+	flags.IntVar(&opts.Keep, "keep", serverdir.DefaultKeep, "Number of backups to keep")
+	flags.BoolVar(&withBackup, "backup", false, "Back up the worlds before replacing the jar")
+
+	return updateCmd
+}
+
+// newRollbackCmd creates the rollback command.
+func newRollbackCmd(_ *app) *cobra.Command {
+	var jar string
+
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback DIR",
+		Short: "Restore the previous server jar from backup",
+		Long: `Restore the most recent jar backed up by "papermc update" in a server
+directory. The jar being rolled back from is removed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := args[0]
+
+			result, err := serverdir.Rollback(dir, jar)
+			if err != nil {
+				return fail("rolling back "+dir, err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Rolled back %s %s build %d -> %s build %d\n", result.To.Project,
+				result.From.Version, result.From.Build, result.To.Version, result.To.Build)
+
+			return nil
+		},
+	}
+
+	rollbackCmd.Flags().StringVar(&jar, "jar", serverdir.DefaultJar, "Name of the server jar inside DIR")
+
+	return rollbackCmd
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/goPaperMC/pkg/api/apitest"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+)
+
+func TestUpdateAndRollback(t *testing.T) {
+	server := newAPIServer(t)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, serverdir.DefaultJar), apitest.DefaultJar("paper", "1.21.11", 74), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, _, err := executeServer(t, server, "update", dir)
+	if err != nil || out != "Updated paper 1.21.11 build 74 -> 1.21.11 build 75\n" {
+		t.Fatalf("Unexpected update output %q (%v)", out, err)
+	}
+
+	out, _, err = executeServer(t, server, "update", dir)
+	if err != nil || out != "paper 1.21.11 build 75 is up to date\n" {
+		t.Errorf("Unexpected output for an up to date server %q (%v)", out, err)
+	}
+
+	out, _, err = executeServer(t, server, "rollback", dir)
+	if err != nil || out != "Rolled back paper 1.21.11 build 75 -> 1.21.11 build 74\n" {
+		t.Fatalf("Unexpected rollback output %q (%v)", out, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, serverdir.DefaultJar))
+	if err != nil || !bytes.Equal(data, apitest.DefaultJar("paper", "1.21.11", 74)) {
+		t.Errorf("Expected build 74 to be restored (%v)", err)
+	}
+
+	if _, _, err := executeServer(t, server, "rollback", dir); err == nil {
+		t.Error("Expected rollback without backups to fail")
+	}
+}
+
+func TestUpdate_Backup(t *testing.T) {
+	server := newAPIServer(t)
+
+	// A server listening on its port is running.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		serverdir.DefaultJar: apitest.DefaultJar("paper", "1.21.11", 74),
+		"server.properties":  []byte("server-port=" + strconv.Itoa(listener.Addr().(*net.TCPAddr).Port) + "\n"),
+		"world/level.dat":    []byte("level"),
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, _, err := executeServer(t, server, "update", "--backup", dir); err == nil ||
+		!strings.Contains(err.Error(), "is running") {
+		t.Fatalf("Expected the update of a running server to be refused, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, serverdir.DefaultJar)); !bytes.Equal(data, files[serverdir.DefaultJar]) {
+		t.Error("Expected the jar to be left in place")
+	}
+
+	_ = listener.Close()
+	out, stderr, err := executeServer(t, server, "update", "--backup", dir)
+	if err != nil || !strings.Contains(stderr, "Backed up [world]") {
+		t.Fatalf("Expected the update of a stopped server to back it up, got %v\n%s%s", err, out, stderr)
+	}
+
+	out, _, err = executeServer(t, server, "backup", "list", dir)
+	if err != nil || !strings.Contains(out, "before update from 1.21.11 build 74 to 1.21.11 build 75") {
+		t.Errorf("Expected the backup to be listed, got %q (%v)", out, err)
+	}
+
+}
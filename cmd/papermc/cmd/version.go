@@ -13,18 +13,17 @@ var (
 	BuildDate = "unknown"
 )
 
-// versionCmd represents the version command
-var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Print the version information",
-	Long:  `Print the version, commit, and build date information for the PaperMC CLI.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("PaperMC CLI version %s\n", Version)
-		fmt.Printf("Commit: %s\n", Commit)
-		fmt.Printf("Built: %s\n", BuildDate)
-	},
-}
-
-func init() {
-	rootCmd.AddCommand(versionCmd)
+// newVersionCmd creates the version command.
+func newVersionCmd(_ *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the version information",
+		Long:  `Print the version, commit, and build date information for the PaperMC CLI.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "PaperMC CLI version %s\n", Version)
+			fmt.Fprintf(out, "Commit: %s\n", Commit)
+			fmt.Fprintf(out, "Built: %s\n", BuildDate)
+		},
+	}
 }
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/notify"
+	"github.com/spf13/cobra"
+)
+
+// newWatchCmd creates the watch command.
+func newWatchCmd(a *app) *cobra.Command {
+	var (
+		interval  time.Duration
+		stateFile string
+		once      bool
+		handler   watchHandler
+	)
+
+	watchCmd := &cobra.Command{
+		Use:   "watch PROJECT_ID[:VERSION]...",
+		Short: "Watch for new versions, builds and channel promotions",
+		Long: `Poll the API periodically and print every change as a JSON object on its
+own line (NDJSON). Event types are new_version, new_build, channel_changed
+and support_changed.
+
+Each target is a project optionally followed by a version constraint, e.g.
+paper:1.21.x. Without a constraint, the newest version family is watched.
+
+State is kept in a state file so changes that happen while the watcher is
+not running are reported on the next start; the first run only records the
+current state.
+
+With --exec, a shell command is run for every event with the event data in
+environment variables: PAPERMC_EVENT (the JSON object), PAPERMC_EVENT_TYPE,
+PAPERMC_PROJECT, PAPERMC_VERSION, PAPERMC_BUILD, PAPERMC_CHANNEL,
+PAPERMC_PREVIOUS_CHANNEL, PAPERMC_SUPPORT, PAPERMC_PREVIOUS_SUPPORT and
+PAPERMC_DOWNLOAD_URL. Its output goes to stderr.
+
+With --webhook, new builds and channel promotions are also posted to chat
+webhooks (see "papermc notify"); --channel limits notifications to builds
+in that channel.
+
+Examples:
+  papermc watch paper:1.21.x velocity --interval=10m --exec='./deploy.sh'
+  papermc watch paper:1.21.x --channel=stable --webhook=https://discord.com/api/webhooks/...`,
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	hooks := addWebhookFlags(watchCmd)
+
+	watchCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		targets := parseTargets(args)
+
+		statePath := stateFile
+		if statePath == "" {
+			statePath = filepath.Join(a.cacheDir(), "watch-state.json")
+		}
+
+		notifier, err := a.newNotifier(hooks)
+		if err != nil {
+			return fail("configuring webhooks", err)
+		}
+
+		handler.out = cmd.OutOrStdout()
+		handler.stderr = cmd.ErrOrStderr()
+		handler.notifier = notifier
+		handler.channel = a.channel()
+
+		watcher := api.NewWatcher(a.newClient(), targets...).
+			WithInterval(interval).
+			WithStateFile(statePath)
+		watcher.OnError = func(err error) {
+			fmt.Fprintf(handler.stderr, "Error polling: %v\n", errors.UnwrapAll(err))
+		}
+
+		ctx := cmd.Context()
+
+		if once {
+			events, commit, err := watcher.Poll(ctx)
+			for _, event := range events {
+				handler.handle(ctx, event)
+			}
+			if commitErr := commit(); err == nil {
+				err = commitErr
+			}
+			if err != nil {
+				return fail("polling", err)
+			}
+			return nil
+		}
+
+		events := make(chan api.Event)
+		go func() {
+			_ = watcher.Run(ctx, events)
+			close(events)
+		}()
+
+		for event := range events {
+			handler.handle(ctx, event)
+		}
+
+		return nil
+	}
+
+	flags := watchCmd.Flags()
+	flags.DurationVar(&interval, "interval", api.DefaultWatchInterval, "Time between two polls")
+	flags.StringVar(&stateFile, "state-file", "", "File keeping state between runs (default in the user cache directory)")
+	flags.StringVar(&handler.exec, "exec", "", "Shell command to run for every event")
+	flags.BoolVar(&once, "once", false, "Poll once and exit")
+
+	return watchCmd
+}
+
+// parseTargets parses PROJECT_ID[:VERSION] arguments.
+func parseTargets(args []string) []api.WatchTarget {
+	targets := make([]api.WatchTarget, 0, len(args))
+	for _, arg := range args {
+		project, version, _ := strings.Cut(arg, ":")
+		targets = append(targets, api.WatchTarget{Project: project, Version: version})
+	}
+
+	return targets
+}
+
+// watchHandler processes the events reported by the watch command.
+type watchHandler struct {
+	out      io.Writer
+	stderr   io.Writer
+	exec     string
+	notifier *notify.Notifier
+	channel  api.Channel
+}
+
+// handle prints an event as NDJSON, runs the --exec command and sends
+// webhook notifications.
+func (h *watchHandler) handle(ctx context.Context, event api.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		fmt.Fprintf(h.stderr, "Error generating JSON: %v\n", err)
+		return
+	}
+
+	fmt.Fprintln(h.out, string(data))
+
+	if h.exec != "" {
+		if err := runEventCommand(ctx, h.stderr, h.exec, event, data); err != nil {
+			fmt.Fprintf(h.stderr, "Error running command for %s event: %v\n", event.Type, err)
+		}
+	}
+
+	if len(h.notifier.Webhooks) > 0 {
+		notifyEvent(ctx, h.stderr, h.notifier, h.channel, event)
+	}
+}
+
+// runEventCommand runs a shell command with the event in its environment,
+// sending its output to w.
+func runEventCommand(ctx context.Context, w io.Writer, command string, event api.Event, data []byte) error {
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	env := map[string]string{
+		"PAPERMC_EVENT":            string(data),
+		"PAPERMC_EVENT_TYPE":       string(event.Type),
+		"PAPERMC_PROJECT":          event.Project,
+		"PAPERMC_VERSION":          event.Version,
+		"PAPERMC_CHANNEL":          event.Channel,
+		"PAPERMC_PREVIOUS_CHANNEL": event.PreviousChannel,
+		"PAPERMC_SUPPORT":          event.Support,
+		"PAPERMC_PREVIOUS_SUPPORT": event.PreviousSupport,
+	}
+	if event.Build != 0 {
+		env["PAPERMC_BUILD"] = strconv.Itoa(int(event.Build))
+	}
+	if event.BuildInfo != nil {
+		env["PAPERMC_DOWNLOAD_URL"] = event.BuildInfo.GetDownloadURL()
+	}
+
+	c.Env = os.Environ()
+	for key, value := range env {
+		c.Env = append(c.Env, key+"="+value)
+	}
+	c.Stdout = w
+	c.Stderr = w
+
+	return c.Run()
+}
@@ -0,0 +1,42 @@
+// Package atomicfile writes files atomically, so a reader never sees a
+// partially written file and a failed write leaves the old file in place.
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+)
+
+// WriteFile writes data to a temporary file next to path and renames it to
+// path with the given permissions. The parent directory is created if
+// needed.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.Wrapf(err, "failed to create directory for %s", path)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary file")
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close %s", path)
+	}
+
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return errors.Wrapf(err, "failed to set permissions of %s", path)
+	}
+
+	return errors.Wrapf(os.Rename(tmpPath, path), "failed to move %s into place", path)
+}
@@ -0,0 +1,41 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state", "file.json")
+
+	for _, content := range []string{"first", "second"} {
+		if err := WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != content {
+			t.Errorf("Expected %q, got %q (%v)", content, data, err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected mode 0600, got %v (%v)", info.Mode(), err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary file to be left, got %v", entries)
+	}
+
+	// A failed write leaves the existing file alone.
+	if err := WriteFile(filepath.Join(path, "child"), []byte("x"), 0o644); err == nil {
+		t.Error("Expected writing below a file to fail")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "second" {
+		t.Errorf("Expected the existing file to be kept, got %q", data)
+	}
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"context"
+	"io"
+)
+
+// API is the set of PaperMC API operations provided by Client. Code that
+// depends on API instead of *Client can substitute a fake in tests, such
+// as a Client pointed at an apitest.Server or a hand-written stub.
+type API interface {
+	GetProjects(ctx context.Context) (*ProjectsV3Response, error)
+	GetProject(ctx context.Context, projectID string) (*ProjectV3Response, error)
+	GetVersions(ctx context.Context, projectID string) ([]VersionV3Response, error)
+	GetVersion(ctx context.Context, projectID, version string) (*VersionV3Response, error)
+	GetBuilds(ctx context.Context, projectID, version string, channels ...Channel) ([]BuildV3Response, error)
+	GetBuild(ctx context.Context, projectID, version string, build int32) (*BuildV3Response, error)
+	GetLatestBuildV3(ctx context.Context, projectID, version string) (*BuildV3Response, error)
+	GetLatestBuildForChannel(ctx context.Context, projectID, version string, channel Channel) (*BuildV3Response, error)
+	GetLatestVersion(ctx context.Context, projectID string) (string, error)
+	GetLatestBuild(ctx context.Context, projectID, version string) (int32, error)
+	ResolveVersion(ctx context.Context, projectID, constraint string, channel Channel) (string, error)
+	Identify(ctx context.Context, projectID, sum string, opts IdentifyOptions) (*Identification, error)
+	DownloadBuild(ctx context.Context, downloadURL string) (io.ReadCloser, error)
+	DownloadFile(ctx context.Context, projectID, version string, build int32, destPath string) (*DownloadResult, error)
+	DownloadTo(ctx context.Context, download DownloadV3, destPath string) (*DownloadResult, error)
+}
+
+var _ API = (*Client)(nil)
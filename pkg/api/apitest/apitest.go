@@ -0,0 +1,378 @@
+// Package apitest provides an in-memory fake of the fill API for tests.
+//
+// A Server serves the fixtures of a Builder on the same /v3/... endpoints
+// as the real API, serves jars under /v1/objects/SHA256/NAME with real
+// checksums, records requests and can inject faults:
+//
+//	server := apitest.NewServer(t, apitest.NewBuilder().
+//		Project("paper").
+//		Version("1.21", "1.21.11").
+//		Build(74, api.ChannelStable))
+//
+//	client := server.Client()
+//	build, err := client.GetLatestBuildV3(ctx, "paper", "1.21.11")
+//	server.AssertRequests(t, "/v3/projects/paper/versions/1.21.11/builds/latest", 1)
+package apitest
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+// ObjectsPath is the path prefix under which jars are served.
+const ObjectsPath = "/v1/objects/"
+
+// Request is a request received by a Server.
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Time   time.Time
+}
+
+// Fault alters the responses to matching requests.
+type Fault struct {
+	Path     string        // Path prefix of affected requests (empty means all)
+	Times    int           // Number of requests affected (0 means all)
+	Latency  time.Duration // Delay before responding
+	Status   int           // Respond with this status and an error body instead (0 means no change)
+	Truncate bool          // Send only half of the body while announcing its full length
+}
+
+// Server is a fake fill API server.
+type Server struct {
+	*httptest.Server
+
+	projects []*project
+
+	mu       sync.Mutex
+	requests []Request
+	faults   []*Fault
+	objects  map[string][]byte // Jar content by checksum
+}
+
+// NewServer starts a server serving the fixtures of b. It is closed when
+// the test finishes.
+func NewServer(t testing.TB, b *Builder) *Server {
+	t.Helper()
+
+	s := &Server{
+		projects: b.projects,
+		objects:  make(map[string][]byte),
+	}
+
+	for _, p := range s.projects {
+		for _, v := range p.details {
+			for _, bl := range v.builds {
+				for key, download := range bl.info.Downloads {
+					s.objects[download.Checksums.SHA256] = bl.content[key]
+				}
+			}
+		}
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Client returns an API client using the server.
+func (s *Server) Client() *api.Client {
+	return api.NewClient().WithBaseURL(s.URL)
+}
+
+// Inject adds a fault. Faults are applied in the order they were added;
+// the first matching fault with requests left wins.
+func (s *Server) Inject(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &fault)
+}
+
+// ClearFaults removes all faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.requests)
+}
+
+// RequestCount returns the number of requests received for a path.
+func (s *Server) RequestCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, r := range s.requests {
+		if r.Path == path {
+			count++
+		}
+	}
+
+	return count
+}
+
+// ResetRequests forgets the requests received so far.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+}
+
+// AssertRequests fails the test unless the server received want requests for path.
+func (s *Server) AssertRequests(t testing.TB, path string, want int) {
+	t.Helper()
+
+	if got := s.RequestCount(path); got != want {
+		t.Errorf("Expected %d requests for %s, got %d", want, path, got)
+	}
+}
+
+// handle records a request, applies faults and serves it.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	fault := s.record(r)
+
+	if fault != nil && fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if fault != nil && fault.Status != 0 {
+		writeError(w, fault.Status, http.StatusText(fault.Status))
+		return
+	}
+
+	status, contentType, body := s.respond(r)
+
+	w.Header().Set("Content-Type", contentType)
+	if fault != nil && fault.Truncate {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		body = body[:len(body)/2]
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// record stores a request and returns the fault applying to it, if any.
+func (s *Server) record(r *http.Request) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Time:   time.Now(),
+	})
+
+	for _, fault := range s.faults {
+		if !strings.HasPrefix(r.URL.Path, fault.Path) {
+			continue
+		}
+		if fault.Times < 0 {
+			continue // Used up
+		}
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				fault.Times = -1
+			}
+		}
+		return fault
+	}
+
+	return nil
+}
+
+// respond returns the response to a request.
+func (s *Server) respond(r *http.Request) (int, string, []byte) {
+	if rest, ok := strings.CutPrefix(r.URL.Path, ObjectsPath); ok {
+		sum, _, _ := strings.Cut(rest, "/")
+		content, ok := s.objects[sum]
+		if !ok {
+			return errorResponse(http.StatusNotFound, "object not found")
+		}
+		return http.StatusOK, "application/java-archive", content
+	}
+
+	resp, ok := s.resolve(r)
+	if !ok {
+		return errorResponse(http.StatusNotFound, "not found")
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return errorResponse(http.StatusInternalServerError, err.Error())
+	}
+
+	return http.StatusOK, "application/json", data
+}
+
+// resolve maps an API path to its response.
+func (s *Server) resolve(r *http.Request) (any, bool) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "v3" || parts[1] != "projects" {
+		return nil, false
+	}
+
+	if len(parts) == 2 {
+		resp := api.ProjectsV3Response{Projects: []api.ProjectV3Info{}}
+		for _, p := range s.projects {
+			resp.Projects = append(resp.Projects, api.ProjectV3Info{Project: p.meta, Versions: p.versionMap()})
+		}
+		return resp, true
+	}
+
+	p := s.project(parts[2])
+	if p == nil {
+		return nil, false
+	}
+
+	switch {
+	case len(parts) == 3:
+		return api.ProjectV3Response{Project: p.meta, Versions: p.versionMap()}, true
+	case len(parts) == 4 && parts[3] == "versions":
+		resp := []api.VersionV3Response{}
+		for _, id := range p.sortedVersions() {
+			resp = append(resp, s.versionResponse(p.details[id]))
+		}
+		return resp, true
+	case parts[3] != "versions":
+		return nil, false
+	}
+
+	v, ok := p.details[parts[4]]
+	if !ok {
+		return nil, false
+	}
+
+	switch {
+	case len(parts) == 5:
+		return s.versionResponse(v), true
+	case parts[5] != "builds" || len(parts) > 7:
+		return nil, false
+	case len(parts) == 6:
+		channels := r.URL.Query()["channel"]
+		resp := []api.BuildV3Response{}
+		for _, bl := range v.sortedBuilds() {
+			if len(channels) == 0 || slices.Contains(channels, bl.info.Channel) {
+				resp = append(resp, s.buildResponse(bl))
+			}
+		}
+		return resp, true
+	}
+
+	builds := v.sortedBuilds()
+	if parts[6] == "latest" {
+		if len(builds) == 0 {
+			return nil, false
+		}
+		return s.buildResponse(builds[len(builds)-1]), true
+	}
+
+	id, err := strconv.ParseInt(parts[6], 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	for _, bl := range builds {
+		if bl.info.ID == int32(id) {
+			return s.buildResponse(bl), true
+		}
+	}
+
+	return nil, false
+}
+
+func (s *Server) project(id string) *project {
+	for _, p := range s.projects {
+		if p.meta.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *Server) versionResponse(v *version) api.VersionV3Response {
+	resp := api.VersionV3Response{Version: v.meta}
+	for _, bl := range v.sortedBuilds() {
+		resp.Builds = append(resp.Builds, bl.info.ID)
+	}
+	return resp
+}
+
+// buildResponse returns a build with download URLs pointing at the server.
+func (s *Server) buildResponse(bl *build) api.BuildV3Response {
+	resp := bl.info
+	resp.Downloads = make(map[string]api.DownloadV3, len(bl.info.Downloads))
+	for key, download := range bl.info.Downloads {
+		download.URL = s.URL + ObjectsPath + download.Checksums.SHA256 + "/" + download.Name
+		resp.Downloads[key] = download
+	}
+	return resp
+}
+
+// versionMap returns the versions by family, newest first.
+func (p *project) versionMap() map[string][]string {
+	versions := make(map[string][]string, len(p.versions))
+	for family, ids := range p.versions {
+		sorted := slices.Clone(ids)
+		slices.SortFunc(sorted, func(a, b string) int { return api.CompareVersions(b, a) })
+		versions[family] = sorted
+	}
+	return versions
+}
+
+// sortedVersions returns all version IDs, oldest first.
+func (p *project) sortedVersions() []string {
+	var ids []string
+	for _, family := range p.families {
+		ids = append(ids, p.versions[family]...)
+	}
+	slices.SortFunc(ids, api.CompareVersions)
+	return ids
+}
+
+// sortedBuilds returns the builds, oldest first.
+func (v *version) sortedBuilds() []*build {
+	builds := slices.Clone(v.builds)
+	slices.SortFunc(builds, func(a, b *build) int { return cmp.Compare(a.info.ID, b.info.ID) })
+	return builds
+}
+
+func errorResponse(status int, message string) (int, string, []byte) {
+	var buf bytes.Buffer
+	_ = json.NewEncoder(&buf).Encode(map[string]string{"error": message})
+	return status, "application/json", buf.Bytes()
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	status, contentType, body := errorResponse(status, message)
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,248 @@
+package apitest_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/api/apitest"
+)
+
+func newServer(t *testing.T) *apitest.Server {
+	t.Helper()
+
+	return apitest.NewServer(t, apitest.NewBuilder().
+		Project("paper").
+		Version("1.20", "1.20.6").Java(17).Support("UNSUPPORTED", nil).
+		Build(151, api.ChannelStable).
+		Version("1.21", "1.21.10").
+		Build(5, api.ChannelStable).
+		Version("1.21", "1.21.11").Java(21, "-XX:+UseG1GC").
+		Build(74, api.ChannelStable).Commit("Fix item dupe").
+		Build(75, api.ChannelBeta).Download("server:mojmap", "paper-mojmap-1.21.11-75.jar", []byte("mojmap")).
+		Project("velocity").
+		Version("3.4.0", "3.4.0-SNAPSHOT").
+		Build(500, api.ChannelStable))
+}
+
+func TestServer(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	ctx := context.Background()
+
+	var _ api.API = client
+
+	projects, err := client.GetProjects(ctx)
+	if err != nil {
+		t.Fatalf("GetProjects failed: %v", err)
+	}
+	if len(projects.Projects) != 2 || projects.Projects[0].Project.Name != "Paper" {
+		t.Errorf("Unexpected projects: %+v", projects.Projects)
+	}
+
+	latestVersion, err := client.GetLatestVersion(ctx, "paper")
+	if err != nil {
+		t.Fatalf("GetLatestVersion failed: %v", err)
+	}
+	if latestVersion != "1.21.11" {
+		t.Errorf("Expected latest version 1.21.11, got %s", latestVersion)
+	}
+
+	version, err := client.GetVersion(ctx, "paper", "1.21.11")
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if version.Version.Java.Version.Minimum != 21 || len(version.Builds) != 2 {
+		t.Errorf("Unexpected version: %+v", version)
+	}
+
+	stable, err := client.GetLatestBuildForChannel(ctx, "paper", "1.21.11", api.ChannelStable)
+	if err != nil {
+		t.Fatalf("GetLatestBuildForChannel failed: %v", err)
+	}
+	if stable.ID != 74 || len(stable.Commits) != 1 {
+		t.Errorf("Expected stable build 74 with a commit, got %+v", stable)
+	}
+	server.AssertRequests(t, "/v3/projects/paper/versions/1.21.11/builds", 1)
+
+	latest, err := client.GetLatestBuildV3(ctx, "paper", "1.21.11")
+	if err != nil {
+		t.Fatalf("GetLatestBuildV3 failed: %v", err)
+	}
+	if latest.ID != 75 || len(latest.Downloads) != 2 {
+		t.Errorf("Expected latest build 75 with 2 downloads, got %+v", latest)
+	}
+
+	dest := filepath.Join(t.TempDir(), "server.jar")
+	if _, err := client.DownloadFile(ctx, "paper", "1.21.11", 74, dest); err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("Failed to read jar: %v", err)
+	}
+	if string(data) != string(apitest.DefaultJar("paper", "1.21.11", 74)) {
+		t.Errorf("Unexpected jar content: %q", data)
+	}
+
+	if _, err := client.GetBuild(ctx, "paper", "1.21.11", 99); err == nil {
+		t.Error("Expected unknown build to fail")
+	}
+	if len(server.Requests()) != 8 {
+		t.Errorf("Expected 8 recorded requests, got %d", len(server.Requests()))
+	}
+}
+
+func TestServer_Faults(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	ctx := context.Background()
+
+	server.Inject(apitest.Fault{Path: "/v3/projects/paper", Status: http.StatusServiceUnavailable, Times: 1})
+	if _, err := client.GetProject(ctx, "paper"); err == nil {
+		t.Error("Expected injected status to fail the request")
+	}
+	if _, err := client.GetProject(ctx, "paper"); err != nil {
+		t.Errorf("Expected fault to be used up, got %v", err)
+	}
+
+	server.Inject(apitest.Fault{Path: "/v3/projects/velocity", Truncate: true})
+	if _, err := client.GetProject(ctx, "velocity"); err == nil {
+		t.Error("Expected truncated body to fail the request")
+	}
+
+	server.ClearFaults()
+	server.Inject(apitest.Fault{Latency: 200 * time.Millisecond})
+	start := time.Now()
+	if _, err := client.GetProjects(ctx); err != nil {
+		t.Fatalf("GetProjects failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected latency of at least 200ms, got %v", elapsed)
+	}
+
+	server.ClearFaults()
+	server.ResetRequests()
+	resp, err := http.Get(server.URL + apitest.ObjectsPath + "unknown/x.jar")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown object, got %d", resp.StatusCode)
+	}
+	server.AssertRequests(t, apitest.ObjectsPath+"unknown/x.jar", 1)
+}
+
+func TestResolveVersion_Faults(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	ctx := context.Background()
+	builds := "/v3/projects/paper/versions/1.21.11/builds"
+
+	// A server error must not resolve to an older version.
+	server.Inject(apitest.Fault{Path: builds, Status: http.StatusServiceUnavailable, Times: 1})
+	if version, err := client.ResolveVersion(ctx, "paper", "1.21.x", api.ChannelStable); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Expected the 503 to be returned, got %q (%v)", version, err)
+	}
+
+	// A version without builds is skipped.
+	server.Inject(apitest.Fault{Path: builds, Status: http.StatusNotFound, Times: 1})
+	if version, err := client.ResolveVersion(ctx, "paper", "1.21.x", api.ChannelStable); err != nil || version != "1.21.10" {
+		t.Errorf("Expected 1.21.10, got %q (%v)", version, err)
+	}
+
+	if version, err := client.ResolveVersion(ctx, "paper", "1.21.x", api.ChannelStable); err != nil || version != "1.21.11" {
+		t.Errorf("Expected 1.21.11, got %q (%v)", version, err)
+	}
+}
+
+func TestResolveVersion_SnapshotReleases(t *testing.T) {
+	// Velocity's version list: old releases, then only -SNAPSHOT versions.
+	b := apitest.NewBuilder().Project("velocity")
+	for i, version := range []string{"1.1.9", "3.1.0", "3.1.1", "3.1.1-SNAPSHOT", "3.1.2-SNAPSHOT", "3.3.0-SNAPSHOT", "3.4.0-SNAPSHOT"} {
+		b.Version(version[:1]+".0.0", version).Build(int32(100+i), api.ChannelStable)
+	}
+	b.Project("paper").
+		Version("1.21", "1.21.11").Build(74, api.ChannelStable).
+		Version("1.21", "1.21.12-rc1").Build(1, api.ChannelBeta).
+		Project("waterfall").
+		Version("1.21", "1.21-SNAPSHOT").Build(600, api.ChannelStable)
+	client := apitest.NewServer(t, b).Client()
+	ctx := context.Background()
+
+	tests := []struct {
+		project, constraint, want string
+	}{
+		{"velocity", "latest", "3.4.0-SNAPSHOT"},
+		{"velocity", "", "3.4.0-SNAPSHOT"},
+		{"velocity", "3.1.x", "3.1.2-SNAPSHOT"},
+		{"velocity", "3.1.1", "3.1.1"},
+		{"waterfall", "latest", "1.21-SNAPSHOT"},
+		{"paper", "latest", "1.21.11"},
+	}
+	for _, tt := range tests {
+		if version, err := client.ResolveVersion(ctx, tt.project, tt.constraint, ""); err != nil || version != tt.want {
+			t.Errorf("ResolveVersion(%s, %q) = %q (%v), want %q", tt.project, tt.constraint, version, err, tt.want)
+		}
+	}
+
+	for project, want := range map[string]string{"velocity": "3.4.0-SNAPSHOT", "paper": "1.21.11"} {
+		if version, err := client.GetRecommendedVersion(ctx, project); err != nil || version != want {
+			t.Errorf("GetRecommendedVersion(%s) = %q (%v), want %q", project, version, err, want)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	server := newServer(t)
+	golden := filepath.Join(t.TempDir(), "paper.json")
+	ctx := context.Background()
+
+	recorder := apitest.NewRecorder(golden, nil)
+	client := server.Client()
+	client.HTTPClient.Transport = recorder
+
+	recorded, err := client.GetBuilds(ctx, "paper", "1.21.11", api.ChannelStable)
+	if err != nil {
+		t.Fatalf("GetBuilds failed: %v", err)
+	}
+	if _, err := client.GetProject(ctx, "unknown"); err == nil {
+		t.Fatal("Expected unknown project to fail")
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Replay works without the server and with any base URL.
+	server.Close()
+
+	replayer, err := apitest.NewReplayer(golden)
+	if err != nil {
+		t.Fatalf("NewReplayer failed: %v", err)
+	}
+	client = api.NewClient()
+	client.HTTPClient.Transport = replayer
+
+	replayed, err := client.GetBuilds(ctx, "paper", "1.21.11", api.ChannelStable)
+	if err != nil {
+		t.Fatalf("Replayed GetBuilds failed: %v", err)
+	}
+	if len(replayed) != len(recorded) || replayed[0].ID != recorded[0].ID || !replayed[0].Time.Equal(recorded[0].Time) {
+		t.Errorf("Expected replayed builds %+v, got %+v", recorded, replayed)
+	}
+
+	if _, err := client.GetProject(ctx, "unknown"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected recorded 404 to be replayed, got %v", err)
+	}
+	if _, err := client.GetProject(ctx, "velocity"); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("Expected unrecorded request to fail, got %v", err)
+	}
+}
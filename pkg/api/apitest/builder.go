@@ -0,0 +1,192 @@
+package apitest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+// DefaultBuildTime is the creation time of builds added without Time.
+var DefaultBuildTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// DefaultJar returns the content of the default download added by
+// Builder.Build for a build.
+func DefaultJar(project, version string, build int32) []byte {
+	return fmt.Appendf(nil, "%s %s build %d", project, version, build)
+}
+
+type project struct {
+	meta     api.ProjectMeta
+	families []string            // Families in insertion order
+	versions map[string][]string // Versions by family
+	details  map[string]*version // Versions by ID
+}
+
+type version struct {
+	meta   api.VersionMeta
+	builds []*build
+}
+
+type build struct {
+	info    api.BuildV3Response
+	content map[string][]byte // Download content by key
+}
+
+// Builder builds the fixtures served by a Server. Methods apply to the
+// project, version or build added last, so fixtures read top-down:
+//
+//	b := apitest.NewBuilder().
+//		Project("paper").
+//		Version("1.21", "1.21.11").Java(21).
+//		Build(74, api.ChannelStable).Commit("Fix dupe").
+//		Build(75, api.ChannelBeta)
+type Builder struct {
+	projects []*project
+
+	project *project
+	version *version
+	build   *build
+}
+
+// NewBuilder creates an empty fixture builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Project adds a project. Its name is the ID with an upper-case first letter.
+func (b *Builder) Project(id string) *Builder {
+	b.project = &project{
+		meta:     api.ProjectMeta{ID: id, Name: strings.ToUpper(id[:1]) + id[1:]},
+		versions: make(map[string][]string),
+		details:  make(map[string]*version),
+	}
+	b.projects = append(b.projects, b.project)
+	b.version = nil
+	b.build = nil
+
+	return b
+}
+
+// Name sets the display name of the current project.
+func (b *Builder) Name(name string) *Builder {
+	b.mustProject().meta.Name = name
+	return b
+}
+
+// Version adds a supported version in a family to the current project.
+func (b *Builder) Version(family, id string) *Builder {
+	p := b.mustProject()
+
+	if _, ok := p.versions[family]; !ok {
+		p.families = append(p.families, family)
+	}
+	p.versions[family] = append(p.versions[family], id)
+
+	b.version = &version{meta: api.VersionMeta{ID: id, Support: api.SupportInfo{Status: "SUPPORTED"}}}
+	p.details[id] = b.version
+	b.build = nil
+
+	return b
+}
+
+// Java sets the minimum Java version and recommended flags of the current version.
+func (b *Builder) Java(minimum int, flags ...string) *Builder {
+	v := b.mustVersion()
+	v.meta.Java = api.JavaInfo{
+		Version: api.JavaVersion{Minimum: minimum},
+		Flags:   api.JavaFlags{Recommended: flags},
+	}
+
+	return b
+}
+
+// Support sets the support status and optional end of support of the current version.
+func (b *Builder) Support(status string, end *time.Time) *Builder {
+	b.mustVersion().meta.Support = api.SupportInfo{Status: status, End: end}
+	return b
+}
+
+// Build adds a build in a channel to the current version. The build gets
+// a server:default download with DefaultJar as content; use Download to
+// add or replace downloads.
+func (b *Builder) Build(id int32, channel api.Channel) *Builder {
+	v := b.mustVersion()
+
+	b.build = &build{
+		info: api.BuildV3Response{
+			ID:        id,
+			Time:      DefaultBuildTime.Add(time.Duration(id) * time.Hour),
+			Channel:   strings.ToUpper(string(channel)),
+			Downloads: make(map[string]api.DownloadV3),
+		},
+		content: make(map[string][]byte),
+	}
+	v.builds = append(v.builds, b.build)
+
+	name := fmt.Sprintf("%s-%s-%d.jar", b.project.meta.ID, v.meta.ID, id)
+	b.Download(api.DefaultDownloadKey, name, DefaultJar(b.project.meta.ID, v.meta.ID, id))
+
+	return b
+}
+
+// Time sets the creation time of the current build.
+func (b *Builder) Time(t time.Time) *Builder {
+	b.mustBuild().info.Time = t
+	return b
+}
+
+// Commit adds a commit to the current build. Its SHA is derived from the
+// message and its time from the build time.
+func (b *Builder) Commit(message string) *Builder {
+	bl := b.mustBuild()
+
+	sum := sha256.Sum256([]byte(message))
+	bl.info.Commits = append(bl.info.Commits, api.CommitV3{
+		SHA:     hex.EncodeToString(sum[:20]),
+		Time:    bl.info.Time,
+		Message: message,
+	})
+
+	return b
+}
+
+// Download adds or replaces a download of the current build. Its checksum
+// and size are computed from content.
+func (b *Builder) Download(key, name string, content []byte) *Builder {
+	bl := b.mustBuild()
+
+	sum := sha256.Sum256(content)
+	bl.info.Downloads[key] = api.DownloadV3{
+		Name:      name,
+		Checksums: api.ChecksumsV3{SHA256: hex.EncodeToString(sum[:])},
+		Size:      int64(len(content)),
+	}
+	bl.content[key] = content
+
+	return b
+}
+
+func (b *Builder) mustProject() *project {
+	if b.project == nil {
+		panic("apitest: Project must be called first")
+	}
+	return b.project
+}
+
+func (b *Builder) mustVersion() *version {
+	if b.version == nil {
+		panic("apitest: Version must be called first")
+	}
+	return b.version
+}
+
+func (b *Builder) mustBuild() *build {
+	if b.build == nil {
+		panic("apitest: Build must be called first")
+	}
+	return b.build
+}
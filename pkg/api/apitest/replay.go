@@ -0,0 +1,228 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+// RecordEnv is the environment variable that switches Replay to recording
+// real API responses, e.g. PAPERMC_RECORD=1 go test ./...
+const RecordEnv = "PAPERMC_RECORD"
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"` // Path and query, without scheme and host
+	Status      int             `json:"status"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`     // JSON bodies, kept readable
+	RawBody     []byte          `json:"raw_body,omitempty"` // Other bodies
+}
+
+// Transport is an http.RoundTripper that records responses of another
+// transport into a golden file, or replays them from it. Requests are
+// matched by method, path and query; the host is ignored so recordings
+// work with any base URL.
+type Transport struct {
+	path      string
+	next      http.RoundTripper
+	recording bool
+
+	mu           sync.Mutex
+	interactions map[string]Interaction
+}
+
+// NewRecorder creates a transport recording the responses of next. Call
+// Save to write them to the golden file at path.
+func NewRecorder(path string, next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Transport{
+		path:         path,
+		next:         next,
+		recording:    true,
+		interactions: make(map[string]Interaction),
+	}
+}
+
+// NewReplayer creates a transport replaying the golden file at path.
+func NewReplayer(path string) (*Transport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read golden file")
+	}
+
+	var interactions []Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse golden file %s", path)
+	}
+
+	t := &Transport{path: path, interactions: make(map[string]Interaction, len(interactions))}
+	for _, interaction := range interactions {
+		t.interactions[interaction.Method+" "+interaction.URL] = interaction
+	}
+
+	return t, nil
+}
+
+// Replay returns a transport replaying the golden file at path. When
+// RecordEnv is set, it records real responses instead and writes them to
+// path when the test finishes successfully.
+func Replay(t testing.TB, path string) *Transport {
+	t.Helper()
+
+	if os.Getenv(RecordEnv) != "" {
+		recorder := NewRecorder(path, nil)
+		t.Cleanup(func() {
+			if t.Failed() {
+				return
+			}
+			if err := recorder.Save(); err != nil {
+				t.Errorf("Failed to save recording: %v", err)
+			}
+		})
+		return recorder
+	}
+
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatalf("Failed to load recording (run with %s=1 to record it): %v", RecordEnv, err)
+	}
+
+	return replayer
+}
+
+// ReplayClient returns a client using Replay with the given golden file.
+func ReplayClient(t testing.TB, path string) *api.Client {
+	t.Helper()
+
+	client := api.NewClient()
+	client.HTTPClient.Transport = Replay(t, path)
+
+	return client
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + req.URL.RequestURI()
+
+	if !t.recording {
+		t.mu.Lock()
+		interaction, ok := t.interactions[key]
+		t.mu.Unlock()
+
+		if !ok {
+			return nil, errors.Newf("no recorded response for %s in %s", key, t.path)
+		}
+
+		return interaction.response(req), nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response")
+	}
+
+	interaction := Interaction{
+		Method:      req.Method,
+		URL:         req.URL.RequestURI(),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if json.Valid(body) {
+		interaction.Body = body
+	} else {
+		interaction.RawBody = body
+	}
+
+	t.mu.Lock()
+	if _, ok := t.interactions[key]; !ok {
+		t.interactions[key] = interaction
+	}
+	t.mu.Unlock()
+
+	return interaction.response(req), nil
+}
+
+// Save writes the recorded interactions to the golden file, sorted by URL.
+// Interactions already in the file are kept unless recorded again, so
+// several tests can share a golden file; delete it to record from scratch.
+func (t *Transport) Save() error {
+	merged := make(map[string]Interaction)
+	if existing, err := NewReplayer(t.path); err == nil {
+		merged = existing.interactions
+	}
+
+	t.mu.Lock()
+	for key, interaction := range t.interactions {
+		merged[key] = interaction
+	}
+	t.mu.Unlock()
+
+	interactions := make([]Interaction, 0, len(merged))
+	for _, interaction := range merged {
+		interactions = append(interactions, interaction)
+	}
+
+	sort.Slice(interactions, func(i, j int) bool {
+		return interactions[i].Method+" "+interactions[i].URL < interactions[j].Method+" "+interactions[j].URL
+	})
+
+	data, err := json.MarshalIndent(interactions, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode recording")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return errors.Wrap(err, "failed to create golden file directory")
+	}
+
+	if err := os.WriteFile(t.path, append(data, '\n'), 0o644); err != nil {
+		return errors.Wrap(err, "failed to write golden file")
+	}
+
+	return nil
+}
+
+// response builds the HTTP response of an interaction.
+func (i Interaction) response(req *http.Request) *http.Response {
+	body := []byte(i.Body)
+	if i.RawBody != nil {
+		body = i.RawBody
+	}
+
+	header := make(http.Header)
+	if i.ContentType != "" {
+		header.Set("Content-Type", i.ContentType)
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(i.Status),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lexfrei/goPaperMC/internal/atomicfile"
+)
+
+// Cache stores raw API metadata responses keyed by request URL.
+// Downloads are never cached.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, data []byte)
+}
+
+// WithCache enables caching of metadata responses.
+func (c *Client) WithCache(cache Cache) *Client {
+	c.Cache = cache
+	return c
+}
+
+// MemoryCache is an in-memory Cache whose entries expire after a fixed TTL.
+type MemoryCache struct {
+	ttl        time.Duration
+	maxEntries int
+	mu         sync.Mutex
+	entries    map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryCache creates an in-memory cache. A TTL of zero keeps entries forever.
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		ttl:     ttl,
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+// WithMaxEntries bounds the number of entries. Once the cache is full, expired
+// entries are dropped first, then the entry that was stored first.
+func (m *MemoryCache) WithMaxEntries(n int) *MemoryCache {
+	m.maxEntries = n
+	return m
+}
+
+// Get returns the cached data for key if present and not expired.
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if m.ttl > 0 && time.Now().After(entry.expires) {
+		delete(m.entries, key)
+		return nil, false
+	}
+
+	return entry.data, true
+}
+
+// Set stores data for key.
+func (m *MemoryCache) Set(key string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := m.entries[key]; !ok && m.maxEntries > 0 && len(m.entries) >= m.maxEntries {
+		m.evict(now)
+	}
+
+	m.entries[key] = memoryEntry{data: data, expires: now.Add(m.ttl)}
+}
+
+// evict drops the expired entries, or the oldest entry if none has expired.
+func (m *MemoryCache) evict(now time.Time) {
+	var (
+		oldest  string
+		expires time.Time
+	)
+	for key, entry := range m.entries {
+		if m.ttl > 0 && now.After(entry.expires) {
+			delete(m.entries, key)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(expires) {
+			oldest, expires = key, entry.expires
+		}
+	}
+
+	if len(m.entries) >= m.maxEntries {
+		delete(m.entries, oldest)
+	}
+}
+
+// FileCache is a Cache persisted as one file per entry in a directory, so
+// it can be shared between runs. Entries expire after a fixed TTL based on
+// their modification time.
+type FileCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewFileCache creates a cache stored in dir. A TTL of zero keeps entries forever.
+func NewFileCache(dir string, ttl time.Duration) *FileCache {
+	return &FileCache{dir: dir, ttl: ttl}
+}
+
+// Get returns the cached data for key if present and not expired.
+func (f *FileCache) Get(key string) ([]byte, bool) {
+	path := f.path(key)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+
+	if f.ttl > 0 && time.Since(info.ModTime()) > f.ttl {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+// Set stores data for key. Write errors are ignored: a failing cache only
+// costs another request.
+func (f *FileCache) Set(key string, data []byte) {
+	_ = atomicfile.WriteFile(f.path(key), data, 0o600)
+}
+
+// path returns the file holding the entry for key.
+func (f *FileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Changelog is the list of commits that went into a range of builds.
+type Changelog struct {
+	Project string           `json:"project"`
+	Version string           `json:"version"`
+	From    int32            `json:"from"` // Exclusive lower bound (0 means from the first build)
+	To      int32            `json:"to"`   // Inclusive upper bound
+	Builds  []ChangelogBuild `json:"builds"`
+}
+
+// ChangelogBuild lists the commits first included in a build.
+type ChangelogBuild struct {
+	ID      int32      `json:"id"`
+	Time    time.Time  `json:"time"`
+	Channel string     `json:"channel"`
+	Commits []CommitV3 `json:"commits"`
+}
+
+// CommitCount returns the total number of commits in the changelog.
+func (cl *Changelog) CommitCount() int {
+	var n int
+	for _, build := range cl.Builds {
+		n += len(build.Commits)
+	}
+
+	return n
+}
+
+// GetChangelog aggregates the commits of all builds of a version with an ID
+// greater than from and not greater than to, newest build first. A to of
+// zero means up to the latest build. Commits listed by several builds are
+// reported only once, under the earliest build that contains them. Builds
+// of every channel are included, since a build only lists the commits made
+// since the previous build.
+func (c *Client) GetChangelog(ctx context.Context, projectID, version string, from, to int32) (_ *Changelog, err error) {
+	ctx, span := c.startOperation(ctx, "GetChangelog", AttrProject.String(projectID), AttrVersion.String(version))
+	defer func() { c.endOperation(span, err) }()
+
+	if to != 0 && to <= from {
+		return nil, errors.Newf("invalid build range %d..%d", from, to)
+	}
+
+	builds, err := c.GetBuilds(ctx, projectID, version)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get builds")
+	}
+
+	// Walk oldest to newest so duplicates are attributed to the earliest build.
+	sort.Slice(builds, func(i, j int) bool { return builds[i].ID < builds[j].ID })
+
+	changelog := &Changelog{Project: projectID, Version: version, From: from, To: to}
+	seen := make(map[string]bool)
+
+	for _, build := range builds {
+		if build.ID <= from || (to != 0 && build.ID > to) {
+			continue
+		}
+
+		entry := ChangelogBuild{ID: build.ID, Time: build.Time, Channel: build.Channel}
+		for _, commit := range build.Commits {
+			if seen[commit.SHA] {
+				continue
+			}
+			seen[commit.SHA] = true
+			entry.Commits = append(entry.Commits, commit)
+		}
+
+		changelog.Builds = append(changelog.Builds, entry)
+		if build.ID > changelog.To {
+			changelog.To = build.ID
+		}
+	}
+
+	for i, j := 0, len(changelog.Builds)-1; i < j; i, j = i+1, j-1 {
+		changelog.Builds[i], changelog.Builds[j] = changelog.Builds[j], changelog.Builds[i]
+	}
+
+	return changelog, nil
+}
@@ -1,15 +1,18 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -19,6 +22,9 @@ const (
 	DefaultTimeout = 30 * time.Second
 )
 
+// ErrNotFound matches errors of requests the API answered with 404 Not Found.
+var ErrNotFound = errors.New("not found")
+
 // channelToAPI maps lowercase channel names to API format (uppercase).
 var channelToAPI = map[Channel]string{
 	ChannelAlpha:       "ALPHA",
@@ -31,8 +37,13 @@ var channelToAPI = map[Channel]string{
 type Client struct {
 	BaseURL    string
 	HTTPClient *http.Client
-	Limit      int     // Limit the number of items to return (0 means no limit)
-	Channel    Channel // Filter builds by channel (empty means no filter)
+	Limit      int          // Limit the number of items to return (0 means no limit)
+	Channel    Channel      // Filter builds by channel (empty means no filter)
+	Cache      Cache        // Cache for metadata responses (nil means no caching)
+	Logger     *slog.Logger // Logger for request records at debug level (nil means no logging)
+
+	tracer  trace.Tracer   // Tracer for operation and request spans (nil means no tracing)
+	metrics *clientMetrics // Request instruments (nil means no metrics)
 }
 
 // NewClient creates a new instance of the PaperMC API client.
@@ -71,7 +82,10 @@ func (c *Client) WithChannel(channel Channel) *Client {
 }
 
 // GetProjects returns a list of all available projects.
-func (c *Client) GetProjects(ctx context.Context) (*ProjectsV3Response, error) {
+func (c *Client) GetProjects(ctx context.Context) (_ *ProjectsV3Response, err error) {
+	ctx, span := c.startOperation(ctx, "GetProjects")
+	defer func() { c.endOperation(span, err) }()
+
 	url := fmt.Sprintf("%s/v3/projects", c.BaseURL)
 
 	resp, err := c.makeRequest(ctx, url)
@@ -95,7 +109,10 @@ func (c *Client) GetProjects(ctx context.Context) (*ProjectsV3Response, error) {
 }
 
 // GetProject returns information about a specific project.
-func (c *Client) GetProject(ctx context.Context, projectID string) (*ProjectV3Response, error) {
+func (c *Client) GetProject(ctx context.Context, projectID string) (_ *ProjectV3Response, err error) {
+	ctx, span := c.startOperation(ctx, "GetProject", AttrProject.String(projectID))
+	defer func() { c.endOperation(span, err) }()
+
 	url := fmt.Sprintf("%s/v3/projects/%s", c.BaseURL, projectID)
 
 	resp, err := c.makeRequest(ctx, url)
@@ -113,7 +130,10 @@ func (c *Client) GetProject(ctx context.Context, projectID string) (*ProjectV3Re
 }
 
 // GetVersions returns a list of all versions for a project with full metadata.
-func (c *Client) GetVersions(ctx context.Context, projectID string) ([]VersionV3Response, error) {
+func (c *Client) GetVersions(ctx context.Context, projectID string) (_ []VersionV3Response, err error) {
+	ctx, span := c.startOperation(ctx, "GetVersions", AttrProject.String(projectID))
+	defer func() { c.endOperation(span, err) }()
+
 	url := fmt.Sprintf("%s/v3/projects/%s/versions", c.BaseURL, projectID)
 
 	resp, err := c.makeRequest(ctx, url)
@@ -137,7 +157,10 @@ func (c *Client) GetVersions(ctx context.Context, projectID string) ([]VersionV3
 }
 
 // GetVersion returns information about a project version.
-func (c *Client) GetVersion(ctx context.Context, projectID, version string) (*VersionV3Response, error) {
+func (c *Client) GetVersion(ctx context.Context, projectID, version string) (_ *VersionV3Response, err error) {
+	ctx, span := c.startOperation(ctx, "GetVersion", AttrProject.String(projectID), AttrVersion.String(version))
+	defer func() { c.endOperation(span, err) }()
+
 	url := fmt.Sprintf("%s/v3/projects/%s/versions/%s", c.BaseURL, projectID, version)
 
 	resp, err := c.makeRequest(ctx, url)
@@ -162,7 +185,10 @@ func (c *Client) GetVersion(ctx context.Context, projectID, version string) (*Ve
 
 // GetBuilds returns a list of available builds for a project version.
 // Optionally filter by channels (ALPHA, BETA, STABLE, RECOMMENDED).
-func (c *Client) GetBuilds(ctx context.Context, projectID, version string, channels ...Channel) ([]BuildV3Response, error) {
+func (c *Client) GetBuilds(ctx context.Context, projectID, version string, channels ...Channel) (_ []BuildV3Response, err error) {
+	ctx, span := c.startOperation(ctx, "GetBuilds", append(channelAttrs(channels), AttrProject.String(projectID), AttrVersion.String(version))...)
+	defer func() { c.endOperation(span, err) }()
+
 	url := fmt.Sprintf("%s/v3/projects/%s/versions/%s/builds", c.BaseURL, projectID, version)
 
 	// Add channel filter if specified
@@ -189,6 +215,12 @@ func (c *Client) GetBuilds(ctx context.Context, projectID, version string, chann
 		return nil, errors.Wrap(err, "failed to decode builds response")
 	}
 
+	// Static mirrors ignore the channel query, so filter here as well
+	builds = filterChannels(builds, channels)
+	for i := range builds {
+		c.resolveDownloads(&builds[i])
+	}
+
 	// Apply limit to builds if set
 	if c.Limit > 0 && len(builds) > c.Limit {
 		start := len(builds) - c.Limit
@@ -198,17 +230,50 @@ func (c *Client) GetBuilds(ctx context.Context, projectID, version string, chann
 	return builds, nil
 }
 
+// filterChannels keeps the builds in one of the given channels. No channels
+// keeps all builds.
+func filterChannels(builds []BuildV3Response, channels []Channel) []BuildV3Response {
+	wanted := make(map[string]bool, len(channels))
+	for _, ch := range channels {
+		if apiCh, ok := channelToAPI[ch]; ok {
+			wanted[apiCh] = true
+		}
+	}
+	if len(wanted) == 0 {
+		return builds
+	}
+
+	filtered := builds[:0]
+	for _, build := range builds {
+		if wanted[build.Channel] {
+			filtered = append(filtered, build)
+		}
+	}
+
+	return filtered
+}
+
 // GetLatestBuildV3 returns the latest build for the specified version using v3 API.
 // If channel filter is set, uses /builds endpoint with filter and returns the last one.
 func (c *Client) GetLatestBuildV3(ctx context.Context, projectID, version string) (*BuildV3Response, error) {
+	return c.GetLatestBuildForChannel(ctx, projectID, version, c.Channel)
+}
+
+// GetLatestBuildForChannel returns the latest build of a version in the given
+// channel, ignoring the client-wide channel filter. An empty channel means the
+// latest build regardless of channel.
+func (c *Client) GetLatestBuildForChannel(ctx context.Context, projectID, version string, channel Channel) (_ *BuildV3Response, err error) {
+	ctx, span := c.startOperation(ctx, "GetLatestBuildForChannel", AttrProject.String(projectID), AttrVersion.String(version), AttrChannel.String(string(channel)))
+	defer func() { c.endOperation(span, err) }()
+
 	// If channel filter is set, use /builds endpoint (channel not supported on /builds/latest)
-	if c.Channel != "" {
-		builds, err := c.GetBuilds(ctx, projectID, version, c.Channel)
+	if channel != "" {
+		builds, err := c.GetBuilds(ctx, projectID, version, channel)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to get builds with channel filter")
 		}
 		if len(builds) == 0 {
-			return nil, errors.Newf("no builds found for channel %s", c.Channel)
+			return nil, errors.Newf("no builds found for channel %s", channel)
 		}
 		// Find build with highest ID (API doesn't guarantee order)
 		latest := &builds[0]
@@ -232,12 +297,16 @@ func (c *Client) GetLatestBuildV3(ctx context.Context, projectID, version string
 	if err := json.NewDecoder(resp.Body).Decode(&buildResp); err != nil {
 		return nil, errors.Wrap(err, "failed to decode build response")
 	}
+	c.resolveDownloads(&buildResp)
 
 	return &buildResp, nil
 }
 
 // GetBuild returns information about a specific build.
-func (c *Client) GetBuild(ctx context.Context, projectID, version string, build int32) (*BuildV3Response, error) {
+func (c *Client) GetBuild(ctx context.Context, projectID, version string, build int32) (_ *BuildV3Response, err error) {
+	ctx, span := c.startOperation(ctx, "GetBuild", AttrProject.String(projectID), AttrVersion.String(version), AttrBuild.Int64(int64(build)))
+	defer func() { c.endOperation(span, err) }()
+
 	url := fmt.Sprintf("%s/v3/projects/%s/versions/%s/builds/%d", c.BaseURL, projectID, version, build)
 
 	resp, err := c.makeRequest(ctx, url)
@@ -250,13 +319,17 @@ func (c *Client) GetBuild(ctx context.Context, projectID, version string, build
 	if err := json.NewDecoder(resp.Body).Decode(&buildResp); err != nil {
 		return nil, errors.Wrap(err, "failed to decode build response")
 	}
+	c.resolveDownloads(&buildResp)
 
 	return &buildResp, nil
 }
 
 // DownloadBuild downloads the specified file from a build.
-func (c *Client) DownloadBuild(ctx context.Context, downloadURL string) (io.ReadCloser, error) {
-	resp, err := c.makeRequest(ctx, downloadURL)
+func (c *Client) DownloadBuild(ctx context.Context, downloadURL string) (_ io.ReadCloser, err error) {
+	ctx, span := c.startOperation(ctx, "DownloadBuild")
+	defer func() { c.endOperation(span, err) }()
+
+	resp, err := c.doRequest(ctx, c.resolveURL(downloadURL))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to download build")
 	}
@@ -264,8 +337,83 @@ func (c *Client) DownloadBuild(ctx context.Context, downloadURL string) (io.Read
 	return resp.Body, nil
 }
 
-// makeRequest performs an HTTP request to the API.
+// makeRequest performs a metadata request to the API, serving it from the
+// cache if one is configured.
 func (c *Client) makeRequest(ctx context.Context, url string) (*http.Response, error) {
+	if c.Cache == nil {
+		return c.doRequest(ctx, url)
+	}
+
+	if data, ok := c.Cache.Get(url); ok {
+		start := time.Now()
+		ctx, span := c.startRequest(ctx, url)
+		c.finishRequest(ctx, span, request{url: url, status: http.StatusOK, start: start,
+			bytes: int64(len(data)), cacheHit: true})
+		return cachedResponse(data), nil
+	}
+
+	resp, err := c.doRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response")
+	}
+
+	c.Cache.Set(url, data)
+
+	return cachedResponse(data), nil
+}
+
+// cachedResponse wraps cached data in a successful HTTP response.
+func cachedResponse(data []byte) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	}
+}
+
+// doRequest performs an HTTP request to the API. The request is logged and
+// its span ended when the body of the returned response is closed.
+func (c *Client) doRequest(ctx context.Context, url string) (*http.Response, error) {
+	start := time.Now()
+	ctx, span := c.startRequest(ctx, url)
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	if isFileURL(url) {
+		resp, err = doFileRequest(url)
+	} else {
+		resp, err = c.sendRequest(ctx, url)
+	}
+	if err != nil {
+		r := request{url: url, start: start, err: err}
+		if resp != nil {
+			r.status = resp.StatusCode
+		}
+		c.finishRequest(ctx, span, r)
+		return nil, err
+	}
+
+	status := resp.StatusCode
+	resp.Body = &trackedBody{
+		ReadCloser: resp.Body,
+		finish: func(bytes int64) {
+			c.finishRequest(ctx, span, request{url: url, status: status, start: start, bytes: bytes})
+		},
+	}
+
+	return resp, nil
+}
+
+// sendRequest performs a GET request over HTTP. Responses other than 200 OK
+// are returned with their body closed along with an error.
+func (c *Client) sendRequest(ctx context.Context, url string) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create request")
@@ -279,7 +427,11 @@ func (c *Client) makeRequest(ctx context.Context, url string) (*http.Response, e
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		_ = resp.Body.Close()
-		return nil, errors.Newf("API returned non-OK status: %d, body: %s", resp.StatusCode, body)
+		err := errors.Newf("API returned non-OK status: %d, body: %s", resp.StatusCode, body)
+		if resp.StatusCode == http.StatusNotFound {
+			err = errors.Mark(err, ErrNotFound)
+		}
+		return resp, err
 	}
 
 	return resp, nil
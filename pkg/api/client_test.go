@@ -2,10 +2,27 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestGetProjectsV3(t *testing.T) {
@@ -491,3 +508,685 @@ func TestGetLatestVersion_ChannelFilter_NoMatchingVersion(t *testing.T) {
 		t.Fatal("Expected error when no version has a build in the requested channel")
 	}
 }
+
+func TestMatchVersion(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"1.21.4", "1.21.4", true},
+		{"1.21.4", "1.21.5", false},
+		{"1.21.x", "1.21", true},
+		{"1.21.x", "1.21.11", true},
+		{"1.21.*", "1.21.11", true},
+		{"1.21.x", "1.21.11-rc3", false},
+		{"1.21.x", "1.2.1", false},
+		{"1.2.x", "1.21.1", false},
+		{"latest", "26.2", true},
+		{"", "1.21.11-pre1", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchVersion(tt.constraint, tt.version); got != tt.want {
+			t.Errorf("MatchVersion(%q, %q) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+}
+
+func newIdentifyServer(t *testing.T, requests map[string]int) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+
+		var resp any
+		switch r.URL.Path {
+		case "/v3/projects/paper":
+			resp = ProjectV3Response{
+				Project: ProjectMeta{ID: "paper", Name: "Paper"},
+				Versions: map[string][]string{
+					"1.21": {"1.21.11", "1.21.10"},
+					"1.20": {"1.20.6"},
+				},
+			}
+		case "/v3/projects/paper/versions/1.21.11/builds":
+			resp = []BuildV3Response{{
+				ID:      74,
+				Channel: "STABLE",
+				Downloads: map[string]DownloadV3{
+					"server:default": {Name: "paper-1.21.11-74.jar", Checksums: ChecksumsV3{SHA256: "aaa"}},
+				},
+			}}
+		case "/v3/projects/paper/versions/1.21.10/builds":
+			resp = []BuildV3Response{{
+				ID:      5,
+				Channel: "BETA",
+				Downloads: map[string]DownloadV3{
+					"server:default":    {Name: "paper-1.21.10-5.jar", Checksums: ChecksumsV3{SHA256: "bbb"}},
+					"server:mojmap-dev": {Name: "paper-mojmap-1.21.10-5.jar", Checksums: ChecksumsV3{SHA256: "ccc"}},
+				},
+			}}
+		case "/v3/projects/paper/versions/1.20.6/builds":
+			resp = []BuildV3Response{{
+				ID:      151,
+				Channel: "STABLE",
+				Downloads: map[string]DownloadV3{
+					"server:default": {Name: "paper-1.20.6-151.jar", Checksums: ChecksumsV3{SHA256: "ddd"}},
+				},
+			}}
+		default:
+			t.Errorf("Unexpected request path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Fatalf("Failed to encode response: %v", err)
+		}
+	}))
+}
+
+func TestIdentify(t *testing.T) {
+	requests := make(map[string]int)
+	server := newIdentifyServer(t, requests)
+	defer server.Close()
+
+	client := NewClient().WithBaseURL(server.URL)
+	ctx := context.Background()
+
+	id, err := client.Identify(ctx, "paper", "ccc", IdentifyOptions{})
+	if err != nil {
+		t.Fatalf("Identify failed: %v", err)
+	}
+	if id.Version != "1.21.10" || id.Build != 5 || id.DownloadKey != "server:mojmap-dev" || id.Channel != "BETA" {
+		t.Errorf("Unexpected identification: %+v", id)
+	}
+
+	// Narrowing to the 1.20 family must not fetch any 1.21 builds.
+	clear(requests)
+	id, err = client.Identify(ctx, "paper", "ddd", IdentifyOptions{Family: "1.20"})
+	if err != nil {
+		t.Fatalf("Identify with family failed: %v", err)
+	}
+	if id.Version != "1.20.6" || id.Build != 151 {
+		t.Errorf("Unexpected identification: %+v", id)
+	}
+	if requests["/v3/projects/paper/versions/1.21.11/builds"] != 0 {
+		t.Error("Expected family filter to skip 1.21 versions")
+	}
+
+	if _, err := client.Identify(ctx, "paper", "ddd", IdentifyOptions{Family: "1.21"}); !errors.Is(err, ErrNoMatchingBuild) {
+		t.Errorf("Expected ErrNoMatchingBuild, got %v", err)
+	}
+
+	if _, err := client.Identify(ctx, "paper", "ddd", IdentifyOptions{Family: "1.19"}); err == nil {
+		t.Error("Expected an error for an unknown family")
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	requests := make(map[string]int)
+	server := newIdentifyServer(t, requests)
+	defer server.Close()
+
+	for name, cache := range map[string]Cache{
+		"memory": NewMemoryCache(time.Minute),
+		"file":   NewFileCache(t.TempDir(), time.Minute),
+	} {
+		clear(requests)
+		client := NewClient().WithBaseURL(server.URL).WithCache(cache)
+
+		for range 2 {
+			if _, err := client.Identify(context.Background(), "paper", "ddd", IdentifyOptions{}); err != nil {
+				t.Fatalf("%s: Identify failed: %v", name, err)
+			}
+		}
+
+		for path, count := range requests {
+			if count != 1 {
+				t.Errorf("%s: expected %s to be requested once, got %d", name, path, count)
+			}
+		}
+	}
+}
+
+func TestMemoryCache_Expiry(t *testing.T) {
+	cache := NewMemoryCache(time.Nanosecond)
+	cache.Set("key", []byte("value"))
+	time.Sleep(time.Millisecond)
+
+	if _, ok := cache.Get("key"); ok {
+		t.Error("Expected entry to expire")
+	}
+}
+
+func TestMemoryCache_MaxEntries(t *testing.T) {
+	cache := NewMemoryCache(time.Minute).WithMaxEntries(2)
+	for _, key := range []string{"a", "b", "b", "c"} {
+		cache.Set(key, []byte(key))
+	}
+
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Expected the oldest entry to be evicted")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("Expected %s to be cached", key)
+		}
+	}
+}
+
+func TestClientLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3/projects/missing" {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"project":{"id":"paper","name":"Paper"},"versions":{"1.21":["1.21.11"]}}`))
+	}))
+	defer server.Close()
+
+	var buf strings.Builder
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := NewClient().WithBaseURL(server.URL).WithCache(NewMemoryCache(time.Minute)).WithLogger(logger)
+
+	ctx := context.Background()
+	for range 2 {
+		if _, err := client.GetProject(ctx, "paper"); err != nil {
+			t.Fatalf("GetProject failed: %v", err)
+		}
+	}
+	if _, err := client.GetProject(ctx, "missing"); err == nil {
+		t.Fatal("Expected an error for a missing project")
+	}
+
+	type record struct {
+		Level    string `json:"level"`
+		Msg      string `json:"msg"`
+		URL      string `json:"url"`
+		Status   int    `json:"status"`
+		Bytes    int    `json:"bytes"`
+		CacheHit bool   `json:"cache_hit"`
+		Retries  *int   `json:"retries"`
+		Duration *int64 `json:"duration"`
+	}
+
+	var records []record
+	for line := range strings.Lines(buf.String()) {
+		var r record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("Failed to parse log record %q: %v", line, err)
+		}
+		records = append(records, r)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 log records, got %d:\n%s", len(records), buf.String())
+	}
+
+	for i, want := range []record{
+		{Msg: "request", Status: 200, CacheHit: false},
+		{Msg: "request", Status: 200, CacheHit: true},
+		{Msg: "request failed", Status: 404, CacheHit: false},
+	} {
+		got := records[i]
+		if got.Level != "DEBUG" || got.Msg != want.Msg || got.Status != want.Status || got.CacheHit != want.CacheHit {
+			t.Errorf("Record %d: expected %s (status %d, cache hit %t) at DEBUG, got %+v",
+				i, want.Msg, want.Status, want.CacheHit, got)
+		}
+		if !strings.HasPrefix(got.URL, server.URL+"/v3/projects/") {
+			t.Errorf("Record %d: unexpected URL %q", i, got.URL)
+		}
+		if got.Duration == nil {
+			t.Errorf("Record %d: expected a duration", i)
+		}
+		if got.Retries == nil || *got.Retries != 0 {
+			t.Errorf("Record %d: expected 0 retries, got %v", i, got.Retries)
+		}
+	}
+
+	if records[0].Bytes == 0 || records[0].Bytes != records[1].Bytes {
+		t.Errorf("Expected the same non-zero size for fetched and cached responses, got %d and %d",
+			records[0].Bytes, records[1].Bytes)
+	}
+}
+
+func TestClientTelemetry(t *testing.T) {
+	jar := []byte("jar contents")
+	sum := sha256.Sum256(jar)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/projects/paper/versions/1.21.11/builds/74":
+			_ = json.NewEncoder(w).Encode(BuildV3Response{
+				ID:      74,
+				Channel: "STABLE",
+				Downloads: map[string]DownloadV3{
+					"server:default": {
+						Name:      "paper-1.21.11-74.jar",
+						URL:       server.URL + "/paper-1.21.11-74.jar",
+						Checksums: ChecksumsV3{SHA256: hex.EncodeToString(sum[:])},
+					},
+				},
+			})
+		case "/paper-1.21.11-74.jar":
+			_, _ = w.Write(jar)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	client := NewClient().WithBaseURL(server.URL).
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))).
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	ctx := context.Background()
+	if _, err := client.DownloadFile(ctx, "paper", "1.21.11", 74, filepath.Join(t.TempDir(), "server.jar")); err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if _, err := client.GetProject(ctx, "missing"); err == nil {
+		t.Fatal("Expected an error for a missing project")
+	}
+
+	byName := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, span := range spans.Ended() {
+		byName[span.Name()] = append(byName[span.Name()], span)
+	}
+
+	for name, count := range map[string]int{"DownloadFile": 1, "GetBuild": 1, "DownloadBuild": 1, "GetProject": 1, "GET": 3} {
+		if len(byName[name]) != count {
+			t.Errorf("Expected %d %s spans, got %d", count, name, len(byName[name]))
+		}
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+
+	download := byName["DownloadFile"][0]
+	attrs := attribute.NewSet(download.Attributes()...)
+	for key, want := range map[attribute.Key]string{AttrProject: "paper", AttrVersion: "1.21.11", AttrBuild: "74"} {
+		if got, ok := attrs.Value(key); !ok || got.Emit() != want {
+			t.Errorf("Expected DownloadFile attribute %s=%s, got %q", key, want, got.Emit())
+		}
+	}
+
+	// Requests are children of the operation that made them
+	for _, op := range []string{"GetBuild", "DownloadBuild"} {
+		if parent := byName[op][0].Parent().SpanID(); parent != download.SpanContext().SpanID() {
+			t.Errorf("Expected %s to be a child of DownloadFile", op)
+		}
+	}
+	for _, req := range byName["GET"] {
+		if !req.Parent().IsValid() {
+			t.Error("Expected request spans to have a parent")
+		}
+	}
+
+	if status := byName["GetProject"][0].Status(); status.Code != codes.Error {
+		t.Errorf("Expected failed GetProject span to have error status, got %v", status.Code)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+
+	var requests int64
+	if sum, ok := metrics["papermc.client.requests"].(metricdata.Sum[int64]); ok {
+		for _, point := range sum.DataPoints {
+			requests += point.Value
+		}
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 requests to be counted, got %d", requests)
+	}
+
+	if hist, ok := metrics["papermc.client.request.duration"].(metricdata.Histogram[float64]); !ok || len(hist.DataPoints) == 0 {
+		t.Error("Expected request durations to be recorded")
+	}
+
+	var bytes int64
+	if sum, ok := metrics["papermc.client.response.size"].(metricdata.Sum[int64]); ok {
+		for _, point := range sum.DataPoints {
+			bytes += point.Value
+		}
+	}
+	if bytes < int64(len(jar)) {
+		t.Errorf("Expected at least %d bytes to be counted, got %d", len(jar), bytes)
+	}
+}
+
+func TestClientTelemetry_Disabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"project":{"id":"paper","name":"Paper"},"versions":{}}`))
+	}))
+	defer server.Close()
+
+	// A span in the caller's context is left untouched by a client without tracing
+	spans := tracetest.NewSpanRecorder()
+	ctx, parent := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)).Tracer("test").Start(context.Background(), "parent")
+
+	if _, err := NewClient().WithBaseURL(server.URL).GetProject(ctx, "paper"); err != nil {
+		t.Fatalf("GetProject failed: %v", err)
+	}
+
+	if ended := spans.Ended(); len(ended) != 0 {
+		t.Errorf("Expected no spans from a client without tracing, got %d", len(ended))
+	}
+	parent.End()
+}
+
+func TestGetChangelog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/projects/paper/versions/1.21.11/builds" {
+			t.Errorf("Unexpected request path: %s", r.URL.Path)
+		}
+		if r.URL.RawQuery != "" {
+			t.Errorf("Expected builds of all channels, got query %q", r.URL.RawQuery)
+		}
+
+		resp := []BuildV3Response{
+			{ID: 74, Channel: "STABLE", Commits: []CommitV3{{SHA: "d4", Message: "Fix dupe\n\nDetails"}, {SHA: "c3", Message: "Repeated"}}},
+			{ID: 72, Channel: "BETA", Commits: []CommitV3{{SHA: "b2", Message: "Second"}}},
+			{ID: 73, Channel: "BETA", Commits: []CommitV3{{SHA: "c3", Message: "Repeated"}}},
+			{ID: 71, Channel: "BETA", Commits: []CommitV3{{SHA: "a1", Message: "First"}}},
+		}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Fatalf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient().WithBaseURL(server.URL).WithChannel(ChannelStable)
+	changelog, err := client.GetChangelog(context.Background(), "paper", "1.21.11", 71, 0)
+	if err != nil {
+		t.Fatalf("GetChangelog failed: %v", err)
+	}
+
+	if changelog.To != 74 {
+		t.Errorf("Expected range to end at latest build 74, got %d", changelog.To)
+	}
+
+	var ids []int32
+	for _, build := range changelog.Builds {
+		ids = append(ids, build.ID)
+	}
+	if len(ids) != 3 || ids[0] != 74 || ids[1] != 73 || ids[2] != 72 {
+		t.Fatalf("Expected builds 74, 73, 72, got %v", ids)
+	}
+
+	// c3 is listed by 73 and 74 but belongs to the earlier build.
+	if len(changelog.Builds[0].Commits) != 1 || changelog.Builds[0].Commits[0].SHA != "d4" {
+		t.Errorf("Expected build 74 to contain only d4, got %+v", changelog.Builds[0].Commits)
+	}
+	if changelog.CommitCount() != 3 {
+		t.Errorf("Expected 3 commits, got %d", changelog.CommitCount())
+	}
+
+	if _, err := client.GetChangelog(context.Background(), "paper", "1.21.11", 74, 70); err == nil {
+		t.Error("Expected an error for an inverted range")
+	}
+}
+
+func TestSearchCommits(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp any
+		switch r.URL.Path {
+		case "/v3/projects/paper":
+			resp = ProjectV3Response{
+				Project:  ProjectMeta{ID: "paper", Name: "Paper"},
+				Versions: map[string][]string{"1.21": {"1.21.11", "1.21.10"}, "1.20": {"1.20.6"}},
+			}
+		case "/v3/projects/paper/versions/1.21.10/builds":
+			resp = []BuildV3Response{
+				{ID: 10, Time: day(2), Channel: "STABLE", Commits: []CommitV3{{SHA: "aaa", Time: day(1), Message: "Fix item dupe"}}},
+				{ID: 11, Time: day(4), Channel: "STABLE", Commits: []CommitV3{{SHA: "bbb", Time: day(3), Message: "Fix another DUPE"}}},
+			}
+		case "/v3/projects/paper/versions/1.21.11/builds":
+			// The first 1.21.11 build repeats commits already shipped in 1.21.10.
+			resp = []BuildV3Response{
+				{ID: 1, Time: day(5), Channel: "BETA", Commits: []CommitV3{{SHA: "bbb", Time: day(3), Message: "Fix another DUPE"}}},
+				{ID: 2, Time: day(6), Channel: "BETA", Commits: []CommitV3{{SHA: "ccc", Time: day(6), Message: "Update upstream"}}},
+			}
+		case "/v3/projects/paper/versions/1.20.6/builds":
+			t.Error("Family filter should skip 1.20.6")
+			resp = []BuildV3Response{}
+		default:
+			t.Errorf("Unexpected request path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Fatalf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient().WithBaseURL(server.URL)
+	matches, err := client.SearchCommits(context.Background(), "paper", CommitSearchOptions{
+		Pattern: regexp.MustCompile(`(?i)dupe`),
+		Family:  "1.21",
+	})
+	if err != nil {
+		t.Fatalf("SearchCommits failed: %v", err)
+	}
+
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %+v", matches)
+	}
+	if matches[0].Commit.SHA != "bbb" || matches[0].Version != "1.21.10" || matches[0].Build != 11 {
+		t.Errorf("Expected bbb in its earliest build 1.21.10 #11, got %+v", matches[0])
+	}
+	if matches[1].Commit.SHA != "aaa" {
+		t.Errorf("Expected aaa second, got %+v", matches[1])
+	}
+
+	matches, err = client.SearchCommits(context.Background(), "paper", CommitSearchOptions{
+		Pattern: regexp.MustCompile(`(?i)dupe`),
+		Family:  "1.21",
+		Since:   day(2),
+	})
+	if err != nil {
+		t.Fatalf("SearchCommits with date range failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Commit.SHA != "bbb" {
+		t.Errorf("Expected only bbb after Jan 2, got %+v", matches)
+	}
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		versions = map[string][]string{"1.21": {"1.21.10"}, "1.20": {"1.20.6"}}
+		support  = map[string]string{"1.21.10": "SUPPORTED"}
+		builds   = map[string][]BuildV3Response{"1.21.10": {{ID: 1, Channel: "BETA"}}}
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var resp any
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v3/projects/paper"), "/")
+		switch {
+		case len(parts) == 1:
+			resp = ProjectV3Response{Project: ProjectMeta{ID: "paper"}, Versions: versions}
+		case len(parts) == 3 && parts[1] == "versions":
+			resp = VersionV3Response{Version: VersionMeta{ID: parts[2], Support: SupportInfo{Status: support[parts[2]]}}}
+		case len(parts) == 4 && parts[3] == "builds":
+			if parts[2] == "1.20.6" {
+				t.Error("Only the newest family should be watched")
+			}
+			resp = builds[parts[2]]
+		default:
+			t.Errorf("Unexpected request path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Fatalf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	client := NewClient().WithBaseURL(server.URL)
+	watcher := NewWatcher(client, WatchTarget{Project: "paper"}).WithStateFile(statePath)
+	ctx := context.Background()
+
+	events, commit, err := watcher.Poll(ctx)
+	if err != nil {
+		t.Fatalf("Initial poll failed: %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("Expected the initial poll to only record state, got %+v", events)
+	}
+	if err := commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	mu.Lock()
+	versions["1.21"] = []string{"1.21.11", "1.21.10"}
+	support["1.21.10"] = "DEPRECATED"
+	support["1.21.11"] = "SUPPORTED"
+	builds["1.21.10"] = []BuildV3Response{{ID: 1, Channel: "STABLE"}, {ID: 2, Channel: "BETA"}}
+	builds["1.21.11"] = []BuildV3Response{{ID: 1, Channel: "ALPHA"}}
+	mu.Unlock()
+
+	// Events not handed off before a restart are delivered again.
+	runCtx, cancel := context.WithCancel(ctx)
+	delivered := make(chan Event)
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(runCtx, delivered) }()
+	if event := <-delivered; event.Type != EventNewVersion {
+		t.Errorf("Expected the new version first, got %+v", event)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected Run to stop on cancel, got %v", err)
+	}
+
+	// A fresh watcher must pick up the state saved by the first one.
+	watcher = NewWatcher(client, WatchTarget{Project: "paper"}).WithStateFile(statePath)
+	events, commit, err = watcher.Poll(ctx)
+	if err != nil {
+		t.Fatalf("Second poll failed: %v", err)
+	}
+	if err := commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	var got []string
+	for _, event := range events {
+		got = append(got, fmt.Sprintf("%s %s %d %s", event.Type, event.Version, event.Build, event.Channel+event.Support))
+	}
+	want := []string{
+		"new_version 1.21.11 0 ",
+		"support_changed 1.21.10 0 DEPRECATED",
+		"channel_changed 1.21.10 1 STABLE",
+		"new_build 1.21.10 2 BETA",
+		"new_build 1.21.11 1 ALPHA",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	events, _, err = watcher.Poll(ctx)
+	if err != nil {
+		t.Fatalf("Third poll failed: %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events without changes, got %+v", events)
+	}
+}
+
+func TestWatcher_PollTargetsOfOneProject(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		versions = map[string][]string{"1.21": {"1.21.10"}, "1.20": {"1.20.6"}}
+		builds   = map[string][]BuildV3Response{"1.21.10": {{ID: 1, Channel: "STABLE"}}, "1.20.6": {{ID: 10, Channel: "STABLE"}}}
+		requests = make(map[string]int)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests[r.URL.Path]++
+
+		var resp any
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v3/projects/paper"), "/")
+		switch {
+		case len(parts) == 1:
+			resp = ProjectV3Response{Project: ProjectMeta{ID: "paper"}, Versions: versions}
+		case len(parts) == 3 && parts[1] == "versions":
+			resp = VersionV3Response{Version: VersionMeta{ID: parts[2], Support: SupportInfo{Status: "SUPPORTED"}}}
+		case len(parts) == 4 && parts[3] == "builds":
+			resp = builds[parts[2]]
+		default:
+			t.Errorf("Unexpected request path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Fatalf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient().WithBaseURL(server.URL)
+	watcher := NewWatcher(client, WatchTarget{Project: "paper", Version: "1.20.x"}, WatchTarget{Project: "paper", Version: "1.21.x"})
+	ctx := context.Background()
+
+	_, commit, err := watcher.Poll(ctx)
+	if err != nil {
+		t.Fatalf("Initial poll failed: %v", err)
+	}
+	if err := commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if requests["/v3/projects/paper"] != 1 {
+		t.Errorf("Expected the project to be fetched once per poll, got %d requests", requests["/v3/projects/paper"])
+	}
+
+	mu.Lock()
+	versions["1.21"] = []string{"1.21.11", "1.21.10"}
+	builds["1.20.6"] = append(builds["1.20.6"], BuildV3Response{ID: 11, Channel: "STABLE"})
+	builds["1.21.10"] = append(builds["1.21.10"], BuildV3Response{ID: 2, Channel: "STABLE"})
+	mu.Unlock()
+
+	events, _, err := watcher.Poll(ctx)
+	if err != nil {
+		t.Fatalf("Second poll failed: %v", err)
+	}
+
+	var got []string
+	for _, event := range events {
+		got = append(got, fmt.Sprintf("%s %s %d", event.Type, event.Version, event.Build))
+	}
+	want := []string{
+		"new_version 1.21.11 0",
+		"new_build 1.20.6 11",
+		"new_build 1.21.10 2",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -20,7 +20,10 @@ type DownloadResult struct {
 }
 
 // DownloadFile downloads a file from a build and verifies its hash.
-func (c *Client) DownloadFile(ctx context.Context, projectID, version string, build int32, destPath string) (*DownloadResult, error) {
+func (c *Client) DownloadFile(ctx context.Context, projectID, version string, build int32, destPath string) (_ *DownloadResult, err error) {
+	ctx, span := c.startOperation(ctx, "DownloadFile", AttrProject.String(projectID), AttrVersion.String(version), AttrBuild.Int64(int64(build)))
+	defer func() { c.endOperation(span, err) }()
+
 	// Get build information for hash verification
 	buildInfo, err := c.GetBuild(ctx, projectID, version, build)
 	if err != nil {
@@ -77,6 +80,8 @@ func (c *Client) DownloadFile(ctx context.Context, projectID, version string, bu
 		return result, errors.Newf("SHA256 mismatch: expected %s, got %s", expectedSHA256, actualSHA256)
 	}
 
+	c.logger().DebugContext(ctx, "downloaded file", "path", destPath, "sha256", actualSHA256)
+
 	return result, nil
 }
 
@@ -93,7 +98,10 @@ func (c *Client) GetLatestBuild(ctx context.Context, projectID, version string)
 // GetLatestVersion returns the latest available version for a project.
 // If a channel filter is set on the client (see WithChannel), only versions
 // that have at least one build in that channel are considered.
-func (c *Client) GetLatestVersion(ctx context.Context, projectID string) (string, error) {
+func (c *Client) GetLatestVersion(ctx context.Context, projectID string) (_ string, err error) {
+	ctx, span := c.startOperation(ctx, "GetLatestVersion", AttrProject.String(projectID))
+	defer func() { c.endOperation(span, err) }()
+
 	projectInfo, err := c.GetProject(ctx, projectID)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get project info")
@@ -141,3 +149,83 @@ func (c *Client) GetDefaultDownloadName(ctx context.Context, projectID, version
 
 	return name, nil
 }
+
+// DownloadTo downloads a single build artifact to destPath. The data is first
+// written to a temporary file next to destPath and only renamed into place
+// once its SHA256 matches the checksum published by the API, so an existing
+// file at destPath is never left half-written or replaced by a corrupt one.
+func (c *Client) DownloadTo(ctx context.Context, download DownloadV3, destPath string) (_ *DownloadResult, err error) {
+	ctx, span := c.startOperation(ctx, "DownloadTo")
+	defer func() { c.endOperation(span, err) }()
+
+	if download.URL == "" {
+		return nil, errors.Newf("no download URL for %s", download.Name)
+	}
+
+	reader, err := c.DownloadBuild(ctx, download.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download build")
+	}
+	defer func() { _ = reader.Close() }()
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create destination directory")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(destPath), "."+filepath.Base(destPath)+".*.part")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary file")
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hasher), reader); err != nil {
+		_ = tmp.Close()
+		return nil, errors.Wrap(err, "failed to copy data")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close temporary file")
+	}
+
+	actualSHA256 := hex.EncodeToString(hasher.Sum(nil))
+	result := &DownloadResult{
+		Filename:       destPath,
+		ExpectedSHA256: download.Checksums.SHA256,
+		ActualSHA256:   actualSHA256,
+		Valid:          actualSHA256 == download.Checksums.SHA256,
+	}
+
+	if !result.Valid && result.ExpectedSHA256 != "" {
+		return result, errors.Newf("SHA256 mismatch: expected %s, got %s", result.ExpectedSHA256, actualSHA256)
+	}
+
+	if err := os.Chmod(tmpPath, 0o644); err != nil {
+		return nil, errors.Wrap(err, "failed to set file permissions")
+	}
+
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		return nil, errors.Wrap(err, "failed to move file into place")
+	}
+
+	c.logger().DebugContext(ctx, "downloaded file", "path", destPath, "sha256", actualSHA256)
+
+	return result, nil
+}
+
+// FileSHA256 returns the hex-encoded SHA256 checksum of a local file.
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open file")
+	}
+	defer func() { _ = file.Close() }()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", errors.Wrap(err, "failed to read file")
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// IndexFile is the file holding the response of an endpoint in a static
+// mirror. Endpoints such as /v3/projects/paper are both a resource and a
+// prefix of other endpoints, so each response lives in its own directory.
+const IndexFile = "index.json"
+
+// isFileURL reports whether rawURL uses the file scheme.
+func isFileURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "file://")
+}
+
+// doFileRequest serves a request for a file:// URL from a static mirror
+// directory. Query parameters are ignored.
+func doFileRequest(rawURL string) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse file URL")
+	}
+
+	path := filepath.FromSlash(u.Path)
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, IndexFile)
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.Mark(errors.Newf("API returned non-OK status: %d, body: %s not found in mirror", http.StatusNotFound, u.Path), ErrNotFound)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open mirror file")
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       file,
+	}, nil
+}
+
+// resolveURL resolves a download URL relative to the base URL, as written
+// by static mirrors. Absolute URLs are returned unchanged.
+func (c *Client) resolveURL(ref string) string {
+	if ref == "" {
+		return ref
+	}
+
+	refURL, err := url.Parse(ref)
+	if err != nil || refURL.IsAbs() {
+		return ref
+	}
+
+	base, err := url.Parse(strings.TrimSuffix(c.BaseURL, "/") + "/")
+	if err != nil {
+		return ref
+	}
+
+	return base.ResolveReference(refURL).String()
+}
+
+// resolveDownloads makes the download URLs of a build absolute.
+func (c *Client) resolveDownloads(build *BuildV3Response) {
+	for key, download := range build.Downloads {
+		download.URL = c.resolveURL(download.URL)
+		build.Downloads[key] = download
+	}
+}
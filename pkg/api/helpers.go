@@ -101,9 +101,13 @@ func (c *Client) DownloadPromotedBuild(ctx context.Context, projectID, version,
 	return result, nil
 }
 
-// GetRecommendedVersion returns the recommended version for the project.
-// Usually it's the latest stable (not SNAPSHOT and not pre/rc) version.
-func (c *Client) GetRecommendedVersion(ctx context.Context, projectID string) (string, error) {
+// GetRecommendedVersion returns the recommended version for the project:
+// the newest version "latest" matches (see ResolveVersion), or the newest
+// version if none does.
+func (c *Client) GetRecommendedVersion(ctx context.Context, projectID string) (_ string, err error) {
+	ctx, span := c.startOperation(ctx, "GetRecommendedVersion", AttrProject.String(projectID))
+	defer func() { c.endOperation(span, err) }()
+
 	projectInfo, err := c.GetProject(ctx, projectID)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get project info")
@@ -114,10 +118,11 @@ func (c *Client) GetRecommendedVersion(ctx context.Context, projectID string) (s
 		return "", errors.New("no versions found for this project")
 	}
 
-	// Look for versions without SNAPSHOT, pre, and rc, starting from the end (from new to old)
+	// Look for a release, starting from the end (from new to old)
+	snapshots := snapshotReleases(versions)
 	for i := len(versions) - 1; i >= 0; i-- {
 		version := versions[i]
-		if !isSnapshotOrPreRelease(version) {
+		if matchVersion("latest", version, snapshots) {
 			return version, nil
 		}
 	}
@@ -134,6 +139,13 @@ func isSnapshotOrPreRelease(version string) bool {
 		strings.Contains(lower, "-rc")
 }
 
+// snapshotReleases reports whether a project publishes its releases as
+// Maven -SNAPSHOT versions, as Velocity does, which is the case when its
+// newest version is one. versions must be sorted oldest first.
+func snapshotReleases(versions []string) bool {
+	return len(versions) > 0 && strings.HasSuffix(strings.ToUpper(versions[len(versions)-1]), "-SNAPSHOT")
+}
+
 // GetLatestBuildURL returns the download URL for the latest build of a version.
 func (c *Client) GetLatestBuildURL(ctx context.Context, projectID, version string) (string, error) {
 	build, err := c.GetLatestBuildV3(ctx, projectID, version)
@@ -201,3 +213,82 @@ func (c *Client) GetBuildURL(ctx context.Context, projectID, version string, bui
 
 	return url, nil
 }
+
+// MatchVersion reports whether a version satisfies a version constraint.
+// Supported constraints are an exact version ("1.21.4"), a wildcard family
+// ("1.21.x" or "1.21.*", matching "1.21" and every "1.21.N" release) and
+// "latest" (or an empty string), which matches any version. Wildcards and
+// "latest" never match snapshots, pre-releases or release candidates, except
+// that ResolveVersion and the other functions resolving constraints against
+// a project treat -SNAPSHOT versions as releases for projects publishing
+// their releases that way, such as Velocity.
+func MatchVersion(constraint, version string) bool {
+	return matchVersion(constraint, version, false)
+}
+
+// matchVersion is MatchVersion, treating -SNAPSHOT versions as releases if
+// snapshots is set.
+func matchVersion(constraint, version string, snapshots bool) bool {
+	release := !isSnapshotOrPreRelease(version) ||
+		snapshots && strings.HasSuffix(strings.ToUpper(version), "-SNAPSHOT")
+
+	switch {
+	case constraint == "" || constraint == "latest":
+		return release
+	case strings.HasSuffix(constraint, ".x") || strings.HasSuffix(constraint, ".*"):
+		family := constraint[:len(constraint)-2]
+		if !release {
+			return false
+		}
+		return version == family || strings.HasPrefix(version, family+".")
+	default:
+		return constraint == version
+	}
+}
+
+// ResolveVersion returns the newest project version satisfying the constraint
+// (see MatchVersion). If channel is not empty, only versions with at least
+// one build in that channel are considered; failing to list the builds of a
+// version is an error rather than a reason to skip it.
+func (c *Client) ResolveVersion(ctx context.Context, projectID, constraint string, channel Channel) (_ string, err error) {
+	ctx, span := c.startOperation(ctx, "ResolveVersion", AttrProject.String(projectID), AttrVersion.String(constraint), AttrChannel.String(string(channel)))
+	defer func() { c.endOperation(span, err) }()
+
+	projectInfo, err := c.GetProject(ctx, projectID)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get project info")
+	}
+
+	versions := projectInfo.FlattenVersions()
+	snapshots := snapshotReleases(versions)
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		if !matchVersion(constraint, version, snapshots) {
+			continue
+		}
+
+		if channel == "" {
+			return version, nil
+		}
+
+		// A version without builds may not list any; other failures must not
+		// resolve the constraint to an older version.
+		builds, err := c.GetBuilds(ctx, projectID, version, channel)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get builds of %s", version)
+		}
+
+		if len(builds) > 0 {
+			return version, nil
+		}
+	}
+
+	if channel != "" {
+		return "", errors.Newf("no version of %s matches %q with a build in channel %s", projectID, constraint, channel)
+	}
+
+	return "", errors.Newf("no version of %s matches %q", projectID, constraint)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ErrNoMatchingBuild is returned when a checksum matches no published build.
+var ErrNoMatchingBuild = errors.New("no published build matches the checksum")
+
+// IdentifyOptions narrows down the builds searched by Identify.
+type IdentifyOptions struct {
+	Family  string // Only search versions of this family, e.g. "1.21"
+	Version string // Only search versions matching this constraint, see MatchVersion
+}
+
+// Identification describes the published build a file belongs to.
+type Identification struct {
+	Project     string     `json:"project"`
+	Version     string     `json:"version"`
+	Build       int32      `json:"build"`
+	Channel     string     `json:"channel"`
+	DownloadKey string     `json:"download_key"`
+	Download    DownloadV3 `json:"download"`
+}
+
+// Identify searches the builds of a project, newest version first, for a
+// download whose SHA256 checksum matches sum. It returns an error matching
+// ErrNoMatchingBuild if no build matches. Enabling a cache (see WithCache)
+// makes repeated lookups cheap, since each version's build list is fetched
+// only once.
+func (c *Client) Identify(ctx context.Context, projectID, sum string, opts IdentifyOptions) (_ *Identification, err error) {
+	ctx, span := c.startOperation(ctx, "Identify", AttrProject.String(projectID))
+	defer func() { c.endOperation(span, err) }()
+
+	versions, err := c.selectVersions(ctx, projectID, opts.Family, opts.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+
+		builds, err := c.GetBuilds(ctx, projectID, version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get builds of %s", version)
+		}
+
+		for b := range builds {
+			for key, download := range builds[b].Downloads {
+				if download.Checksums.SHA256 != sum {
+					continue
+				}
+
+				return &Identification{
+					Project:     projectID,
+					Version:     version,
+					Build:       builds[b].ID,
+					Channel:     builds[b].Channel,
+					DownloadKey: key,
+					Download:    download,
+				}, nil
+			}
+		}
+	}
+
+	return nil, errors.Wrapf(ErrNoMatchingBuild, "no %s build has SHA256 %s", projectID, sum)
+}
+
+// IdentifyFile identifies a local file by its SHA256 checksum, see Identify.
+func (c *Client) IdentifyFile(ctx context.Context, projectID, path string, opts IdentifyOptions) (*Identification, error) {
+	sum, err := FileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Identify(ctx, projectID, sum, opts)
+}
+
+// selectVersions returns the versions of a project, oldest first, that
+// belong to family and match the version constraint. Empty filters match
+// every version.
+func (c *Client) selectVersions(ctx context.Context, projectID, family, constraint string) ([]string, error) {
+	projectInfo, err := c.GetProject(ctx, projectID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get project info")
+	}
+
+	if family != "" {
+		if _, ok := projectInfo.Versions[family]; !ok {
+			return nil, errors.Newf("project %s has no version family %s", projectID, family)
+		}
+	}
+
+	var versions []string
+	all := projectInfo.FlattenVersions()
+	snapshots := snapshotReleases(all)
+	for _, version := range all {
+		if family != "" && !slices.Contains(projectInfo.Versions[family], version) {
+			continue
+		}
+		if constraint != "" && !matchVersion(constraint, version, snapshots) {
+			continue
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// discardLogger is used when a client has no logger.
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// WithLogger sets the logger receiving a debug record for every request.
+func (c *Client) WithLogger(logger *slog.Logger) *Client {
+	c.Logger = logger
+	return c
+}
+
+// logger returns the client's logger or one discarding all records.
+func (c *Client) logger() *slog.Logger {
+	if c.Logger == nil {
+		return discardLogger
+	}
+
+	return c.Logger
+}
+
+// logRequest logs a finished request.
+func (c *Client) logRequest(ctx context.Context, r request) {
+	attrs := []slog.Attr{
+		slog.String("method", "GET"),
+		slog.String("url", r.url),
+	}
+	if r.status != 0 {
+		attrs = append(attrs, slog.Int("status", r.status))
+	}
+	attrs = append(attrs,
+		slog.Duration("duration", time.Since(r.start)),
+		slog.Int64("bytes", r.bytes),
+		slog.Bool("cache_hit", r.cacheHit),
+		slog.Int("retries", r.retries),
+	)
+
+	msg := "request"
+	if r.err != nil {
+		msg = "request failed"
+		attrs = append(attrs, slog.String("error", r.err.Error()))
+	}
+
+	c.logger().LogAttrs(ctx, slog.LevelDebug, msg, attrs...)
+}
+
+// trackedBody wraps a response body and finishes the request once the body
+// is closed, when the number of bytes read and the total duration are known.
+type trackedBody struct {
+	io.ReadCloser
+
+	bytes  int64
+	once   sync.Once
+	finish func(bytes int64)
+}
+
+// Read counts the bytes read from the body.
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += int64(n)
+
+	return n, err
+}
+
+// Close closes the body and finishes the request.
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.finish(b.bytes) })
+
+	return err
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"slices"
 	"sort"
 	"time"
 
@@ -88,16 +89,53 @@ func (p *ProjectV3Response) FlattenVersions() []string {
 		allVersions = append(allVersions, versions...)
 	}
 
-	// Sort using semver (requires "v" prefix)
 	sort.Slice(allVersions, func(i, j int) bool {
-		vi := "v" + allVersions[i]
-		vj := "v" + allVersions[j]
-		return semver.Compare(vi, vj) < 0
+		return CompareVersions(allVersions[i], allVersions[j]) < 0
 	})
 
 	return allVersions
 }
 
+// SelectVersions returns the versions matching a version constraint (see
+// MatchVersion), oldest first. An empty constraint selects the versions of
+// the family containing the newest version.
+func (p *ProjectV3Response) SelectVersions(constraint string) []string {
+	versions := p.FlattenVersions()
+
+	if constraint != "" {
+		var matched []string
+		snapshots := snapshotReleases(versions)
+		for _, version := range versions {
+			if matchVersion(constraint, version, snapshots) {
+				matched = append(matched, version)
+			}
+		}
+		return matched
+	}
+
+	if len(versions) == 0 {
+		return nil
+	}
+
+	newest := versions[len(versions)-1]
+	for _, family := range p.Versions {
+		if slices.Contains(family, newest) {
+			return slices.DeleteFunc(versions, func(v string) bool {
+				return !slices.Contains(family, v)
+			})
+		}
+	}
+
+	return []string{newest}
+}
+
+// CompareVersions compares two versions using semver ordering and returns
+// -1, 0 or +1. Pre-releases such as "1.21.11-rc3" sort before the release.
+func CompareVersions(a, b string) int {
+	// semver requires a "v" prefix
+	return semver.Compare("v"+a, "v"+b)
+}
+
 // VersionV3Response represents the v3 API response for a version.
 type VersionV3Response struct {
 	Version VersionMeta `json:"version"`
@@ -113,9 +151,22 @@ type BuildV3Response struct {
 	Downloads map[string]DownloadV3 `json:"downloads"`
 }
 
+// DefaultDownloadKey is the download key of the default server application.
+const DefaultDownloadKey = "server:default"
+
+// GetDownload returns the download registered under the given key.
+// An empty key selects the default server application.
+func (b *BuildV3Response) GetDownload(key string) (DownloadV3, bool) {
+	if key == "" {
+		key = DefaultDownloadKey
+	}
+	download, ok := b.Downloads[key]
+	return download, ok
+}
+
 // GetDownloadURL returns the download URL for the default server application.
 func (b *BuildV3Response) GetDownloadURL() string {
-	if download, ok := b.Downloads["server:default"]; ok {
+	if download, ok := b.Downloads[DefaultDownloadKey]; ok {
 		return download.URL
 	}
 	// Fallback: return first available download URL
@@ -127,7 +178,7 @@ func (b *BuildV3Response) GetDownloadURL() string {
 
 // GetDownloadName returns the filename of the default server application.
 func (b *BuildV3Response) GetDownloadName() string {
-	if download, ok := b.Downloads["server:default"]; ok {
+	if download, ok := b.Downloads[DefaultDownloadKey]; ok {
 		return download.Name
 	}
 	for _, download := range b.Downloads {
@@ -138,7 +189,7 @@ func (b *BuildV3Response) GetDownloadName() string {
 
 // GetDownloadSHA256 returns the SHA256 checksum of the default server application.
 func (b *BuildV3Response) GetDownloadSHA256() string {
-	if download, ok := b.Downloads["server:default"]; ok {
+	if download, ok := b.Downloads[DefaultDownloadKey]; ok {
 		return download.Checksums.SHA256
 	}
 	for _, download := range b.Downloads {
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// DefaultSearchConcurrency is the default number of versions scanned in parallel.
+const DefaultSearchConcurrency = 4
+
+// CommitSearchOptions controls which commits SearchCommits reports.
+type CommitSearchOptions struct {
+	Pattern     *regexp.Regexp // Matched against commit messages and SHAs
+	Family      string         // Only scan versions of this family, e.g. "1.21"
+	Version     string         // Only scan versions matching this constraint, see MatchVersion
+	Since       time.Time      // Only report commits made at or after this time
+	Until       time.Time      // Only report commits made before this time
+	Concurrency int            // Versions scanned in parallel (defaults to DefaultSearchConcurrency)
+}
+
+// CommitMatch is a commit found by SearchCommits together with the earliest
+// build that contains it.
+type CommitMatch struct {
+	Commit    CommitV3  `json:"commit"`
+	Version   string    `json:"version"`
+	Build     int32     `json:"build"`
+	Channel   string    `json:"channel"`
+	BuildTime time.Time `json:"build_time"`
+}
+
+// SearchCommits scans the commits of all builds of the selected versions and
+// returns those matching the options, newest commit first. A commit shipped
+// by several builds (for example in more than one version) is reported once,
+// with the earliest build that contains it. Versions are scanned in
+// parallel; enabling a cache (see WithCache) avoids refetching build lists
+// on repeated searches.
+func (c *Client) SearchCommits(ctx context.Context, projectID string, opts CommitSearchOptions) (_ []CommitMatch, err error) {
+	ctx, span := c.startOperation(ctx, "SearchCommits", AttrProject.String(projectID))
+	defer func() { c.endOperation(span, err) }()
+
+	if opts.Pattern == nil {
+		return nil, errors.New("search pattern is required")
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultSearchConcurrency
+	}
+
+	versions, err := c.selectVersions(ctx, projectID, opts.Family, opts.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		earliest = make(map[string]CommitMatch)
+		sem      = make(chan struct{}, concurrency)
+	)
+
+	for _, version := range versions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			builds, err := c.GetBuilds(ctx, projectID, version)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = errors.Wrapf(err, "failed to get builds of %s", version)
+				}
+				return
+			}
+
+			for _, build := range builds {
+				for _, commit := range build.Commits {
+					if !opts.matches(commit) {
+						continue
+					}
+
+					match := CommitMatch{
+						Commit:    commit,
+						Version:   version,
+						Build:     build.ID,
+						Channel:   build.Channel,
+						BuildTime: build.Time,
+					}
+					if prev, ok := earliest[commit.SHA]; !ok || match.before(prev) {
+						earliest[commit.SHA] = match
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	matches := make([]CommitMatch, 0, len(earliest))
+	for _, match := range earliest {
+		matches = append(matches, match)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].Commit.Time.Equal(matches[j].Commit.Time) {
+			return matches[i].Commit.Time.After(matches[j].Commit.Time)
+		}
+		return matches[i].Commit.SHA < matches[j].Commit.SHA
+	})
+
+	return matches, nil
+}
+
+// matches reports whether a commit satisfies the search options.
+func (o *CommitSearchOptions) matches(commit CommitV3) bool {
+	if !o.Since.IsZero() && commit.Time.Before(o.Since) {
+		return false
+	}
+	if !o.Until.IsZero() && !commit.Time.Before(o.Until) {
+		return false
+	}
+
+	return o.Pattern.MatchString(commit.Message) || o.Pattern.MatchString(commit.SHA)
+}
+
+// before reports whether m was built earlier than other.
+func (m CommitMatch) before(other CommitMatch) bool {
+	if !m.BuildTime.Equal(other.BuildTime) {
+		return m.BuildTime.Before(other.BuildTime)
+	}
+	if m.Version != other.Version {
+		return CompareVersions(m.Version, other.Version) < 0
+	}
+
+	return m.Build < other.Build
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// instrumentationName identifies the client's tracer and meter.
+const instrumentationName = "github.com/lexfrei/goPaperMC/pkg/api"
+
+// Span attributes describing the subject of an operation.
+const (
+	AttrProject = attribute.Key("papermc.project")
+	AttrVersion = attribute.Key("papermc.version")
+	AttrBuild   = attribute.Key("papermc.build")
+	AttrChannel = attribute.Key("papermc.channel")
+)
+
+// attrCacheHit marks requests served from the metadata cache.
+const attrCacheHit = attribute.Key("papermc.cache_hit")
+
+// noopTracer is used when tracing is disabled.
+var noopTracer = noop.NewTracerProvider().Tracer(instrumentationName)
+
+// clientMetrics are the instruments recording requests.
+type clientMetrics struct {
+	requests metric.Int64Counter
+	duration metric.Float64Histogram
+	bytes    metric.Int64Counter
+}
+
+// WithTracerProvider enables tracing: every operation such as GetProject or
+// DownloadFile gets a span, with a child span for each HTTP request.
+func (c *Client) WithTracerProvider(provider trace.TracerProvider) *Client {
+	c.tracer = provider.Tracer(instrumentationName)
+	return c
+}
+
+// WithMeterProvider enables metrics: papermc.client.requests counts
+// requests by status code and cache hit, papermc.client.request.duration
+// records their latency and papermc.client.response.size counts the bytes
+// downloaded.
+func (c *Client) WithMeterProvider(provider metric.MeterProvider) *Client {
+	meter := provider.Meter(instrumentationName)
+
+	// The instrument constructors only fail on invalid names.
+	requests, _ := meter.Int64Counter("papermc.client.requests",
+		metric.WithDescription("Number of API requests"),
+		metric.WithUnit("{request}"))
+	duration, _ := meter.Float64Histogram("papermc.client.request.duration",
+		metric.WithDescription("Duration of API requests"),
+		metric.WithUnit("s"))
+	bytes, _ := meter.Int64Counter("papermc.client.response.size",
+		metric.WithDescription("Bytes downloaded from the API"),
+		metric.WithUnit("By"))
+
+	c.metrics = &clientMetrics{requests: requests, duration: duration, bytes: bytes}
+
+	return c
+}
+
+// startOperation starts the span of a client operation. The channel filter
+// of the client is recorded unless attrs contain a channel.
+func (c *Client) startOperation(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if c.tracer == nil {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	if c.Channel != "" && !hasAttr(attrs, AttrChannel) {
+		attrs = append(attrs, AttrChannel.String(string(c.Channel)))
+	}
+
+	return c.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endOperation ends the span of a client operation started with
+// startOperation, recording err.
+func (c *Client) endOperation(span trace.Span, err error) {
+	if c.tracer == nil {
+		return
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// hasAttr reports whether attrs contain key.
+func hasAttr(attrs []attribute.KeyValue, key attribute.Key) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// request describes an HTTP request for logs, traces and metrics.
+type request struct {
+	url      string
+	status   int
+	start    time.Time
+	bytes    int64
+	cacheHit bool
+	retries  int // Attempts before this one; always 0 as the client sends each request once
+	err      error
+}
+
+// startRequest starts the span of an HTTP request.
+func (c *Client) startRequest(ctx context.Context, url string) (context.Context, trace.Span) {
+	tracer := c.tracer
+	if tracer == nil {
+		tracer = noopTracer
+	}
+
+	return tracer.Start(ctx, "GET", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", "GET"),
+			attribute.String("url.full", url),
+		))
+}
+
+// finishRequest logs a request, records its metrics and ends its span.
+func (c *Client) finishRequest(ctx context.Context, span trace.Span, r request) {
+	c.logRequest(ctx, r)
+
+	attrs := []attribute.KeyValue{attrCacheHit.Bool(r.cacheHit)}
+	if r.status != 0 {
+		attrs = append(attrs, attribute.Int("http.response.status_code", r.status))
+	}
+
+	span.SetAttributes(attrs...)
+	if r.err != nil {
+		span.RecordError(r.err)
+		span.SetStatus(codes.Error, r.err.Error())
+	}
+	span.End()
+
+	if c.metrics == nil {
+		return
+	}
+
+	set := metric.WithAttributes(attrs...)
+	c.metrics.requests.Add(ctx, 1, set)
+	c.metrics.duration.Record(ctx, time.Since(r.start).Seconds(), set)
+	if !r.cacheHit {
+		c.metrics.bytes.Add(ctx, r.bytes)
+	}
+}
+
+// channelAttrs returns the channel attribute for a channel filter.
+func channelAttrs(channels []Channel) []attribute.KeyValue {
+	if len(channels) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(channels))
+	for i, ch := range channels {
+		names[i] = string(ch)
+	}
+
+	return []attribute.KeyValue{AttrChannel.String(strings.Join(names, ","))}
+}
@@ -0,0 +1,329 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/internal/atomicfile"
+)
+
+// DefaultWatchInterval is the default time between two polls of a Watcher.
+const DefaultWatchInterval = 5 * time.Minute
+
+// EventType identifies the kind of change reported by a Watcher.
+type EventType string
+
+const (
+	// EventNewVersion is emitted when a project publishes a new version.
+	EventNewVersion EventType = "new_version"
+	// EventNewBuild is emitted when a watched version gets a new build.
+	EventNewBuild EventType = "new_build"
+	// EventChannelChanged is emitted when a build moves to another channel,
+	// e.g. when it is promoted from BETA to STABLE.
+	EventChannelChanged EventType = "channel_changed"
+	// EventSupportChanged is emitted when a version's support status changes.
+	EventSupportChanged EventType = "support_changed"
+)
+
+// Event is a change observed by a Watcher.
+type Event struct {
+	Type            EventType        `json:"type"`
+	Time            time.Time        `json:"time"`
+	Project         string           `json:"project"`
+	Version         string           `json:"version"`
+	Build           int32            `json:"build,omitempty"`
+	Channel         string           `json:"channel,omitempty"`
+	PreviousChannel string           `json:"previous_channel,omitempty"`
+	Support         string           `json:"support,omitempty"`
+	PreviousSupport string           `json:"previous_support,omitempty"`
+	BuildInfo       *BuildV3Response `json:"build_info,omitempty"` // Set for build events
+}
+
+// WatchTarget selects the versions of a project a Watcher follows.
+type WatchTarget struct {
+	Project string
+	// Version is a version constraint (see MatchVersion) selecting the
+	// versions whose builds and support status are watched. If empty, the
+	// versions of the project's newest version family are watched.
+	Version string
+}
+
+// watchState is the persisted knowledge of a Watcher.
+type watchState struct {
+	Projects map[string]*projectState `json:"projects"`
+}
+
+type projectState struct {
+	Versions []string                 `json:"versions"`
+	Watched  map[string]*versionState `json:"watched"`
+}
+
+type versionState struct {
+	Support string           `json:"support"`
+	Builds  map[int32]string `json:"builds"` // Build ID to channel
+}
+
+// Watcher periodically polls the API and reports new versions, new builds,
+// channel promotions and support status changes as events. The first poll
+// of a project only records its current state. If a state file is set, the
+// recorded state is loaded on start and saved once the events of a poll have
+// been handed off, so no event is lost across restarts.
+type Watcher struct {
+	Client    *Client
+	Targets   []WatchTarget
+	Interval  time.Duration
+	StatePath string          // File persisting state between runs (empty means in memory only)
+	OnError   func(err error) // Called when a poll fails (nil means ignore)
+
+	state *watchState
+}
+
+// NewWatcher creates a watcher for the given targets.
+func NewWatcher(client *Client, targets ...WatchTarget) *Watcher {
+	return &Watcher{
+		Client:   client,
+		Targets:  targets,
+		Interval: DefaultWatchInterval,
+	}
+}
+
+// WithInterval sets the time between two polls.
+func (w *Watcher) WithInterval(interval time.Duration) *Watcher {
+	w.Interval = interval
+	return w
+}
+
+// WithStateFile sets the file used to persist state between runs.
+func (w *Watcher) WithStateFile(path string) *Watcher {
+	w.StatePath = path
+	return w
+}
+
+// Run polls immediately and then every Interval, sending events to the
+// channel until the context is canceled. The state is committed once all
+// events of a poll have been received, so events still undelivered when the
+// context is canceled are sent again after a restart. Failed polls and
+// commits are reported to OnError and retried on the next tick.
+func (w *Watcher) Run(ctx context.Context, events chan<- Event) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		polled, commit, err := w.Poll(ctx)
+		if err != nil && w.OnError != nil && ctx.Err() == nil {
+			w.OnError(err)
+		}
+
+		for _, event := range polled {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if err := commit(); err != nil && w.OnError != nil {
+			w.OnError(err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Poll checks all targets once and returns the observed changes, along with
+// a function committing them to the watcher's state and state file. Until
+// commit is called, the next poll reports the same changes again, so call
+// it once the events have been handled. Only projects polled without error
+// are committed.
+func (w *Watcher) Poll(ctx context.Context) ([]Event, func() error, error) {
+	noop := func() error { return nil }
+
+	if w.state == nil {
+		state, err := loadWatchState(w.StatePath)
+		if err != nil {
+			return nil, noop, err
+		}
+		w.state = state
+	}
+
+	// Targets of the same project share its state, so the project is polled
+	// once for the versions selected by all of them.
+	var projects []string
+	constraints := make(map[string][]string)
+	for _, target := range w.Targets {
+		if _, ok := constraints[target.Project]; !ok {
+			projects = append(projects, target.Project)
+		}
+		constraints[target.Project] = append(constraints[target.Project], target.Version)
+	}
+
+	var (
+		events []Event
+		errs   []error
+		next   = make(map[string]*projectState, len(projects))
+	)
+	for _, project := range projects {
+		polled, state, err := w.pollProject(ctx, project, constraints[project])
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to poll %s", project))
+			continue
+		}
+		events = append(events, polled...)
+		next[project] = state
+	}
+
+	if len(next) == 0 {
+		return events, noop, errors.Join(errs...)
+	}
+
+	commit := func() error {
+		if w.state.Projects == nil {
+			w.state.Projects = make(map[string]*projectState)
+		}
+		maps.Copy(w.state.Projects, next)
+
+		if w.StatePath == "" {
+			return nil
+		}
+
+		return saveWatchState(w.StatePath, w.state)
+	}
+
+	return events, commit, errors.Join(errs...)
+}
+
+// pollProject compares the current state of a project, watching the
+// versions selected by any of the constraints, with the recorded one and
+// returns the changes and the new state of the project.
+func (w *Watcher) pollProject(ctx context.Context, project string, constraints []string) ([]Event, *projectState, error) {
+	now := time.Now().UTC()
+
+	projectInfo, err := w.Client.GetProject(ctx, project)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get project info")
+	}
+	versions := projectInfo.FlattenVersions()
+
+	prev, known := w.state.Projects[project]
+	next := &projectState{Versions: versions, Watched: make(map[string]*versionState)}
+
+	var events []Event
+	if known {
+		for _, version := range versions {
+			if !slices.Contains(prev.Versions, version) {
+				events = append(events, Event{Type: EventNewVersion, Time: now, Project: project, Version: version})
+			}
+		}
+	}
+
+	var selected []string
+	for _, constraint := range constraints {
+		for _, version := range projectInfo.SelectVersions(constraint) {
+			if !slices.Contains(selected, version) {
+				selected = append(selected, version)
+			}
+		}
+	}
+
+	for _, version := range selected {
+		versionInfo, err := w.Client.GetVersion(ctx, project, version)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to get version %s", version)
+		}
+
+		builds, err := w.Client.GetBuilds(ctx, project, version)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to get builds of %s", version)
+		}
+		sort.Slice(builds, func(i, j int) bool { return builds[i].ID < builds[j].ID })
+
+		current := &versionState{Support: versionInfo.Version.Support.Status, Builds: make(map[int32]string, len(builds))}
+		for _, build := range builds {
+			current.Builds[build.ID] = build.Channel
+		}
+		next.Watched[version] = current
+
+		// Versions seen for the first time during the initial poll are only recorded.
+		if !known {
+			continue
+		}
+
+		old, watched := prev.Watched[version]
+		if !watched {
+			// A version entering the watched set only reports builds if it is new.
+			if slices.Contains(prev.Versions, version) {
+				continue
+			}
+			old = &versionState{Support: current.Support}
+		}
+
+		if old.Support != current.Support {
+			events = append(events, Event{
+				Type: EventSupportChanged, Time: now, Project: project, Version: version,
+				Support: current.Support, PreviousSupport: old.Support,
+			})
+		}
+
+		for i := range builds {
+			build := &builds[i]
+			channel, seen := old.Builds[build.ID]
+			switch {
+			case !seen:
+				events = append(events, Event{
+					Type: EventNewBuild, Time: now, Project: project, Version: version,
+					Build: build.ID, Channel: build.Channel, BuildInfo: build,
+				})
+			case channel != build.Channel:
+				events = append(events, Event{
+					Type: EventChannelChanged, Time: now, Project: project, Version: version,
+					Build: build.ID, Channel: build.Channel, PreviousChannel: channel, BuildInfo: build,
+				})
+			}
+		}
+	}
+
+	return events, next, nil
+}
+
+// loadWatchState reads the state file, returning an empty state if the path
+// is empty or the file does not exist yet.
+func loadWatchState(path string) (*watchState, error) {
+	state := &watchState{Projects: make(map[string]*projectState)}
+	if path == "" {
+		return state, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read watch state")
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, errors.Wrap(err, "failed to decode watch state")
+	}
+
+	return state, nil
+}
+
+// saveWatchState atomically writes the state file.
+func saveWatchState(path string, state *watchState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode watch state")
+	}
+
+	return atomicfile.WriteFile(path, data, 0o600)
+}
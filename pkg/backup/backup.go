@@ -0,0 +1,499 @@
+// Package backup archives the worlds of a server directory so they can be
+// restored after an update goes wrong.
+//
+// The worlds are detected from level-name in server.properties: the main
+// world and, on Bukkit-based servers, its separate nether and end folders.
+// Each backup is a timestamped tar archive compressed with zstd or gzip
+// whose first entry is a JSON manifest describing it, so backups can be
+// listed without reading them in full.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/klauspost/compress/zstd"
+	"github.com/lexfrei/goPaperMC/pkg/properties"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+)
+
+const (
+	// DefaultDir is the directory, relative to the server directory, holding backups.
+	DefaultDir = ".papermc/world-backups"
+	// ManifestName is the name of the manifest entry of an archive.
+	ManifestName = "papermc-backup.json"
+	// DefaultLevel is the world name used when server.properties sets none.
+	DefaultLevel = "world"
+	// DefaultKeep is the default number of backups kept.
+	DefaultKeep = 7
+)
+
+// dimensions are the suffixes of the folders Bukkit-based servers keep the
+// nether and the end in, next to the main world.
+var dimensions = []string{"", "_nether", "_the_end"}
+
+// skipped are files that are never archived: the lock the server holds on
+// a world while it runs.
+var skipped = []string{"session.lock"}
+
+// timeLayout is the timestamp in backup file names.
+const timeLayout = "20060102T150405Z"
+
+// Format is the compression of an archive, also its file extension.
+type Format string
+
+const (
+	// FormatZstd is a zstd-compressed tar archive.
+	FormatZstd Format = "tar.zst"
+	// FormatGzip is a gzip-compressed tar archive.
+	FormatGzip Format = "tar.gz"
+)
+
+// ParseFormat parses an archive format. An empty string selects FormatZstd.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.TrimPrefix(s, ".")); f {
+	case "":
+		return FormatZstd, nil
+	case FormatZstd, FormatGzip:
+		return f, nil
+	default:
+		return "", errors.Newf("invalid backup format %q (expected tar.zst or tar.gz)", s)
+	}
+}
+
+// Manifest describes the content of a backup.
+type Manifest struct {
+	Created time.Time          `json:"created"`
+	Level   string             `json:"level"`             // level-name of the server
+	Worlds  []string           `json:"worlds"`            // Archived world folders
+	Install *serverdir.Install `json:"install,omitempty"` // Build installed when the backup was made
+	Reason  string             `json:"reason,omitempty"`
+	Files   int                `json:"files"` // Number of archived files
+	Size    int64              `json:"size"`  // Total size of the archived files
+}
+
+// Backup is an archive in a backup directory.
+type Backup struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	ArchiveSize int64  `json:"archive_size"`
+	Manifest
+}
+
+// Retention decides which backups Prune removes.
+type Retention struct {
+	Keep   int           // Number of newest backups to keep (0 means no limit)
+	MaxAge time.Duration // Remove backups older than this (0 means no limit)
+}
+
+// Options control Create.
+type Options struct {
+	Dir       string    // Backup directory, relative to the server directory (defaults to DefaultDir)
+	Format    Format    // Defaults to FormatZstd
+	Reason    string    // Recorded in the manifest, e.g. "before update to 1.21.11 build 75"
+	Retention Retention // Applied after the backup is written
+}
+
+// Result describes the outcome of Create.
+type Result struct {
+	Backup *Backup
+	Pruned []Backup // Backups removed by the retention policy
+}
+
+// Dir returns the backup directory of a server directory: dir if it is
+// absolute, dir inside the server directory if it is relative, or
+// DefaultDir inside the server directory if it is empty.
+func Dir(serverDir, dir string) string {
+	switch {
+	case dir == "":
+		return filepath.Join(serverDir, filepath.FromSlash(DefaultDir))
+	case filepath.IsAbs(dir):
+		return dir
+	default:
+		return filepath.Join(serverDir, dir)
+	}
+}
+
+// Worlds returns the level name of a server directory and the world
+// folders that exist for it: the level itself and its _nether and _the_end
+// folders.
+func Worlds(dir string) (string, []string, error) {
+	level := DefaultLevel
+
+	props, err := properties.Load(filepath.Join(dir, properties.ServerFile))
+	switch {
+	case err == nil:
+		level = props.Value("level-name", DefaultLevel)
+	case !errors.Is(err, os.ErrNotExist):
+		return "", nil, err
+	}
+
+	if !validName(level) {
+		return "", nil, errors.Newf("unsupported level-name %q", level)
+	}
+
+	var worlds []string
+	for _, suffix := range dimensions {
+		if info, err := os.Stat(filepath.Join(dir, level+suffix)); err == nil && info.IsDir() {
+			worlds = append(worlds, level+suffix)
+		}
+	}
+
+	if len(worlds) == 0 {
+		return level, nil, errors.Newf("no world folder %q in %s", level, dir)
+	}
+
+	return level, worlds, nil
+}
+
+// validName reports whether a world name is a single path element.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// entry is a file or directory to archive.
+type entry struct {
+	path string // Relative to the server directory, slash-separated
+	info os.FileInfo
+}
+
+// Create archives the worlds of a server directory into a new backup and
+// applies the retention policy. The archive is written to a temporary file
+// and renamed once complete. Files changing while they are archived may
+// end up inconsistent, so the server should be stopped, or saving turned
+// off with save-off, while a backup is made.
+func Create(ctx context.Context, dir string, opts Options) (*Result, error) {
+	format, err := ParseFormat(string(opts.Format))
+	if err != nil {
+		return nil, err
+	}
+
+	level, worlds, err := Worlds(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := Manifest{Created: time.Now().UTC(), Level: level, Worlds: worlds, Reason: opts.Reason}
+	if lock, err := serverdir.ReadLock(dir); err == nil {
+		manifest.Install = &lock.Install
+	}
+
+	var entries []entry
+	for _, world := range worlds {
+		err := filepath.Walk(filepath.Join(dir, world), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && !info.Mode().IsRegular() || slices.Contains(skipped, info.Name()) {
+				return nil
+			}
+
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry{path: filepath.ToSlash(rel), info: info})
+
+			if info.Mode().IsRegular() {
+				manifest.Files++
+				manifest.Size += info.Size()
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to scan world %s", world)
+		}
+	}
+
+	backupDir := Dir(dir, opts.Dir)
+	if err := os.MkdirAll(backupDir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create backup directory")
+	}
+
+	name := level + "-" + manifest.Created.Format(timeLayout) + "." + string(format)
+	path := filepath.Join(backupDir, name)
+	if _, err := os.Stat(path); err == nil {
+		return nil, errors.Newf("backup %s already exists", name)
+	}
+
+	tmp, err := os.CreateTemp(backupDir, "."+name+".*")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create backup file")
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := writeArchive(ctx, tmp, format, dir, &manifest, entries); err != nil {
+		_ = tmp.Close()
+		return nil, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to write backup")
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, errors.Wrap(err, "failed to move backup into place")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to stat backup")
+	}
+
+	result := &Result{Backup: &Backup{Name: name, Path: path, ArchiveSize: info.Size(), Manifest: manifest}}
+
+	result.Pruned, err = Prune(backupDir, opts.Retention)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// writeArchive writes the manifest and the entries to w.
+func writeArchive(ctx context.Context, w io.Writer, format Format, dir string, manifest *Manifest, entries []entry) error {
+	compressed, err := compressor(w, format)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(compressed)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode manifest")
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    ManifestName,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: manifest.Created,
+		Format:  tar.FormatPAX,
+	})
+	if err == nil {
+		_, err = tw.Write(data)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to write manifest")
+	}
+
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := addFile(tw, dir, e); err != nil {
+			return errors.Wrapf(err, "failed to archive %s", e.path)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "failed to finish archive")
+	}
+
+	return errors.Wrap(compressed.Close(), "failed to finish compression")
+}
+
+// addFile writes one entry. Regular files must still have the size they
+// had when the worlds were scanned.
+func addFile(tw *tar.Writer, dir string, e entry) error {
+	header, err := tar.FileInfoHeader(e.info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = e.path
+	header.Format = tar.FormatPAX
+	header.Uname, header.Gname = "", ""
+	if e.info.IsDir() {
+		header.Name += "/"
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !e.info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(e.path)))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := io.CopyN(tw, f, header.Size); err != nil {
+		return errors.Wrap(err, "file changed while it was archived")
+	}
+
+	return nil
+}
+
+// compressor returns a writer compressing to w in format.
+func compressor(w io.Writer, format Format) (io.WriteCloser, error) {
+	switch format {
+	case FormatGzip:
+		return gzip.NewWriter(w), nil
+	default:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to start compression")
+		}
+
+		return zw, nil
+	}
+}
+
+// open opens an archive and returns a tar reader positioned after its
+// manifest, and the manifest. The returned function closes the archive.
+func open(path string) (*tar.Reader, *Manifest, func(), error) {
+	format, ok := formatOf(path)
+	if !ok {
+		return nil, nil, nil, errors.Newf("%s is not a backup archive", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "failed to open backup")
+	}
+
+	var (
+		r       io.Reader
+		closeFn = func() { _ = f.Close() }
+	)
+
+	switch format {
+	case FormatGzip:
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			closeFn()
+			return nil, nil, nil, errors.Wrapf(err, "failed to read %s", filepath.Base(path))
+		}
+		r = gr
+	default:
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			closeFn()
+			return nil, nil, nil, errors.Wrapf(err, "failed to read %s", filepath.Base(path))
+		}
+		r = zr
+		closeFn = func() { zr.Close(); _ = f.Close() }
+	}
+
+	tr := tar.NewReader(r)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != ManifestName {
+		closeFn()
+		return nil, nil, nil, errors.Newf("%s has no manifest", filepath.Base(path))
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		closeFn()
+		return nil, nil, nil, errors.Wrapf(err, "failed to read manifest of %s", filepath.Base(path))
+	}
+
+	return tr, &manifest, closeFn, nil
+}
+
+// formatOf returns the format of an archive from its file name.
+func formatOf(path string) (Format, bool) {
+	for _, format := range []Format{FormatZstd, FormatGzip} {
+		if strings.HasSuffix(path, "."+string(format)) {
+			return format, true
+		}
+	}
+
+	return "", false
+}
+
+// List returns the backups in a backup directory, newest first. A missing
+// directory has no backups.
+func List(backupDir string) ([]Backup, error) {
+	files, err := os.ReadDir(backupDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read backup directory")
+	}
+
+	var backups []Backup
+	for _, file := range files {
+		if _, ok := formatOf(file.Name()); !ok || file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+
+		b, err := Read(filepath.Join(backupDir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, *b)
+	}
+
+	slices.SortFunc(backups, func(a, b Backup) int {
+		return b.Created.Compare(a.Created)
+	})
+
+	return backups, nil
+}
+
+// Read reads the manifest of a backup archive.
+func Read(path string) (*Backup, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to stat backup")
+	}
+
+	_, manifest, closeFn, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	closeFn()
+
+	return &Backup{Name: filepath.Base(path), Path: path, ArchiveSize: info.Size(), Manifest: *manifest}, nil
+}
+
+// Prune removes the backups the retention policy does not keep and
+// returns them. The newest backup is always kept.
+func Prune(backupDir string, retention Retention) ([]Backup, error) {
+	if retention.Keep <= 0 && retention.MaxAge <= 0 {
+		return nil, nil
+	}
+
+	backups, err := List(backupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Backup
+	for i, b := range backups {
+		if i == 0 {
+			continue
+		}
+
+		tooMany := retention.Keep > 0 && i >= retention.Keep
+		tooOld := retention.MaxAge > 0 && time.Since(b.Created) > retention.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		if err := os.Remove(b.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, errors.Wrapf(err, "failed to remove old backup %s", b.Name)
+		}
+		removed = append(removed, b)
+	}
+
+	return removed, nil
+}
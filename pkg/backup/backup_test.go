@@ -0,0 +1,203 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+)
+
+// newServerDir creates a server directory with a "survival" world, its
+// nether folder and a recorded install.
+func newServerDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"server.properties":                  "level-name=survival\nserver-port=25565\n",
+		"survival/level.dat":                 "level",
+		"survival/session.lock":              "lock",
+		"survival/region/r.0.0.mca":          strings.Repeat("chunk", 1000),
+		"survival_nether/DIM-1/region/r.mca": "nether",
+		"world/level.dat":                    "not the level",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := serverdir.WriteLock(dir, &serverdir.Lock{Install: serverdir.Install{Project: "paper", Version: "1.21.11", Build: 74}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestWorlds(t *testing.T) {
+	dir := newServerDir(t)
+
+	level, worlds, err := Worlds(dir)
+	if err != nil {
+		t.Fatalf("Worlds failed: %v", err)
+	}
+	if level != "survival" || strings.Join(worlds, ",") != "survival,survival_nether" {
+		t.Errorf("Unexpected worlds: %s %v", level, worlds)
+	}
+
+	if _, _, err := Worlds(t.TempDir()); err == nil {
+		t.Error("Expected an error for a directory without worlds")
+	}
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	for _, format := range []Format{FormatZstd, FormatGzip} {
+		t.Run(string(format), func(t *testing.T) {
+			dir := newServerDir(t)
+			ctx := context.Background()
+
+			result, err := Create(ctx, dir, Options{Format: format, Reason: "test"})
+			if err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			b := result.Backup
+			if !strings.HasPrefix(b.Name, "survival-") || !strings.HasSuffix(b.Name, "."+string(format)) {
+				t.Errorf("Unexpected backup name %s", b.Name)
+			}
+			if filepath.Dir(b.Path) != Dir(dir, "") {
+				t.Errorf("Expected the backup in %s, got %s", Dir(dir, ""), b.Path)
+			}
+			if b.Files != 3 || b.Install == nil || b.Install.Build != 74 || b.Reason != "test" {
+				t.Errorf("Unexpected manifest: %+v", b.Manifest)
+			}
+
+			backups, err := List(Dir(dir, ""))
+			if err != nil || len(backups) != 1 || backups[0].Files != 3 {
+				t.Fatalf("Unexpected backups %+v (%v)", backups, err)
+			}
+
+			// Break the world, then restore it.
+			if err := os.WriteFile(filepath.Join(dir, "survival", "level.dat"), []byte("corrupt"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.RemoveAll(filepath.Join(dir, "survival_nether")); err != nil {
+				t.Fatal(err)
+			}
+
+			restored, err := Restore(ctx, dir, b.Path)
+			if err != nil {
+				t.Fatalf("Restore failed: %v", err)
+			}
+
+			for name, want := range map[string]string{
+				"survival/level.dat":                 "level",
+				"survival_nether/DIM-1/region/r.mca": "nether",
+				"world/level.dat":                    "not the level",
+			} {
+				data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+				if err != nil || string(data) != want {
+					t.Errorf("Expected %s to contain %q, got %q (%v)", name, want, data, err)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(dir, "survival", "session.lock")); err == nil {
+				t.Error("Expected session.lock not to be restored")
+			}
+
+			data, err := os.ReadFile(filepath.Join(restored.Replaced, "survival", "level.dat"))
+			if err != nil || string(data) != "corrupt" {
+				t.Errorf("Expected the replaced world to be kept, got %q (%v)", data, err)
+			}
+		})
+	}
+}
+
+// writeTestArchive writes a backup with a given creation time.
+func writeTestArchive(t *testing.T, backupDir string, created time.Time) {
+	t.Helper()
+
+	manifest := &Manifest{Created: created, Level: "world", Worlds: []string{"world"}}
+	path := filepath.Join(backupDir, "world-"+created.Format(timeLayout)+".tar.gz")
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := writeArchive(context.Background(), f, FormatGzip, backupDir, manifest, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	backupDir := t.TempDir()
+	now := time.Now().UTC()
+
+	for _, age := range []time.Duration{0, time.Hour, 2 * time.Hour, 48 * time.Hour, 72 * time.Hour} {
+		writeTestArchive(t, backupDir, now.Add(-age))
+	}
+
+	removed, err := Prune(backupDir, Retention{Keep: 4, MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("Expected the two old backups to be removed, got %d", len(removed))
+	}
+
+	removed, err = Prune(backupDir, Retention{Keep: 1})
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	backups, _ := List(backupDir)
+	if len(removed) != 2 || len(backups) != 1 || now.Sub(backups[0].Created) > time.Minute {
+		t.Errorf("Expected only the newest backup to be kept, got %+v", backups)
+	}
+
+	if removed, _ := Prune(backupDir, Retention{MaxAge: time.Nanosecond}); len(removed) != 0 {
+		t.Error("Expected the newest backup to be kept regardless of its age")
+	}
+}
+
+func TestRestore_Rejects(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "evil.tar.gz")
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	entries := []struct{ name, content string }{
+		{ManifestName, `{"worlds":["world"],"files":1,"size":4}`},
+		{"world/../../escape", "evil"},
+	}
+	for _, e := range entries {
+		_ = tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.content)), Typeflag: tar.TypeReg})
+		_, _ = tw.Write([]byte(e.content))
+	}
+	_ = tw.Close()
+	_ = gw.Close()
+	_ = f.Close()
+
+	if _, err := Restore(context.Background(), dir, path); err == nil {
+		t.Error("Expected an archive entry leaving the server directory to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape")); err == nil {
+		t.Error("Expected nothing to be written outside the server directory")
+	}
+}
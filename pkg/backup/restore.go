@@ -0,0 +1,177 @@
+package backup
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// replacedPrefix names the directories Restore moves replaced worlds to,
+// inside the server directory's .papermc directory.
+const replacedPrefix = "replaced-worlds-"
+
+// RestoreResult describes the outcome of Restore.
+type RestoreResult struct {
+	Backup   *Backup
+	Replaced string // Directory the replaced worlds were moved to, empty if there were none
+}
+
+// Restore replaces the worlds of a server directory with those of a backup
+// archive. The archive is extracted next to the worlds and checked against
+// its manifest before anything is replaced; the current world folders are
+// then moved into a .papermc/replaced-worlds-TIMESTAMP directory rather
+// than deleted. The server must not be running.
+func Restore(ctx context.Context, dir, archive string) (*RestoreResult, error) {
+	backup, err := Read(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, world := range backup.Worlds {
+		if !validName(world) {
+			return nil, errors.Newf("backup %s contains an invalid world name %q", backup.Name, world)
+		}
+	}
+
+	stateDir := filepath.Join(dir, ".papermc")
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create state directory")
+	}
+
+	staging, err := os.MkdirTemp(stateDir, ".restore-*")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create staging directory")
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	if err := extract(ctx, archive, staging, backup); err != nil {
+		return nil, err
+	}
+
+	result := &RestoreResult{Backup: backup}
+	replaced := filepath.Join(stateDir, replacedPrefix+time.Now().UTC().Format(timeLayout))
+
+	for _, world := range backup.Worlds {
+		target := filepath.Join(dir, world)
+
+		if _, err := os.Stat(target); err == nil {
+			if err := os.MkdirAll(replaced, 0o755); err != nil {
+				return result, errors.Wrap(err, "failed to create directory for replaced worlds")
+			}
+			if err := os.Rename(target, filepath.Join(replaced, world)); err != nil {
+				return result, errors.Wrapf(err, "failed to move world %s aside", world)
+			}
+			result.Replaced = replaced
+		}
+
+		if err := os.Rename(filepath.Join(staging, world), target); err != nil {
+			return result, errors.Wrapf(err, "failed to restore world %s", world)
+		}
+	}
+
+	return result, nil
+}
+
+// extract unpacks the worlds of an archive into dir and checks the number
+// and total size of the files against the manifest.
+func extract(ctx context.Context, archive, dir string, backup *Backup) error {
+	tr, _, closeFn, err := open(archive)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	for _, world := range backup.Worlds {
+		if err := os.Mkdir(filepath.Join(dir, world), 0o755); err != nil {
+			return errors.Wrap(err, "failed to create world directory")
+		}
+	}
+
+	var (
+		files int
+		size  int64
+	)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", backup.Name)
+		}
+
+		world, _, _ := strings.Cut(strings.TrimSuffix(header.Name, "/"), "/")
+		if !slices.Contains(backup.Worlds, world) {
+			return errors.Newf("backup %s contains %s outside its worlds", backup.Name, header.Name)
+		}
+
+		path, err := safeJoin(dir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return errors.Wrapf(err, "failed to create %s", header.Name)
+			}
+		case tar.TypeReg:
+			n, err := writeFile(path, tr, header.FileInfo().Mode().Perm())
+			if err != nil {
+				return errors.Wrapf(err, "failed to extract %s", header.Name)
+			}
+			files++
+			size += n
+		default:
+			return errors.Newf("backup %s contains unsupported entry %s", backup.Name, header.Name)
+		}
+	}
+
+	if files != backup.Files || size != backup.Size {
+		return errors.Newf("backup %s is incomplete: expected %d files (%d bytes), found %d (%d bytes)",
+			backup.Name, backup.Files, backup.Size, files, size)
+	}
+
+	return nil
+}
+
+// safeJoin joins an archive path to dir, rejecting paths leaving it.
+func safeJoin(dir, name string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.Newf("archive entry %s leaves the target directory", name)
+	}
+
+	return path, nil
+}
+
+// writeFile writes the content of r to a new file.
+func writeFile(path string, r io.Reader, perm os.FileMode) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm|0o200)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return n, err
+}
@@ -0,0 +1,316 @@
+// Package exporter exposes upstream build freshness as Prometheus metrics.
+//
+// An Exporter periodically fetches the latest builds and support status of
+// selected projects and versions and, optionally, the builds installed in
+// server directories. Scrapes are served from the last refresh, so they
+// never hit the API.
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultInterval is the default time between two refreshes.
+const DefaultInterval = 5 * time.Minute
+
+// namespace prefixes all metric names.
+const namespace = "papermc"
+
+// Exporter collects build freshness metrics.
+type Exporter struct {
+	Client     api.API
+	Targets    []api.WatchTarget
+	ServerDirs []string // Server directories whose installed build is reported
+	Interval   time.Duration
+	OnError    func(err error) // Called when a refresh fails (nil means ignore)
+
+	registry *prometheus.Registry
+
+	latestBuild     *prometheus.GaugeVec
+	latestBuildTime *prometheus.GaugeVec
+	supportStatus   *prometheus.GaugeVec
+	supportEnd      *prometheus.GaugeVec
+	installedBuild  *prometheus.GaugeVec
+	buildsBehind    *prometheus.GaugeVec
+	refreshTime     prometheus.Gauge
+	refreshSuccess  prometheus.Gauge
+}
+
+// New creates an exporter for the given targets. If client is an
+// *api.Client, its HTTP transport is instrumented so API request metrics are
+// exported as well.
+func New(client api.API, targets ...api.WatchTarget) *Exporter {
+	e := &Exporter{
+		Client:   client,
+		Targets:  targets,
+		Interval: DefaultInterval,
+		registry: prometheus.NewRegistry(),
+
+		latestBuild: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "latest_build",
+			Help:      "ID of the latest build of a version in a channel.",
+		}, []string{"project", "version", "channel"}),
+		latestBuildTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "latest_build_timestamp_seconds",
+			Help:      "Creation time of the latest build of a version in a channel.",
+		}, []string{"project", "version", "channel"}),
+		supportStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "version_support_status",
+			Help:      "Support status of a version; 1 for the current status.",
+		}, []string{"project", "version", "status"}),
+		supportEnd: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "version_support_end_timestamp_seconds",
+			Help:      "End of support of a version, if announced.",
+		}, []string{"project", "version"}),
+		installedBuild: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "installed_build",
+			Help:      "ID of the build installed in a server directory.",
+		}, []string{"dir", "project", "version"}),
+		buildsBehind: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "installed_builds_behind",
+			Help:      "Number of build IDs between the installed build and the latest build of its version.",
+		}, []string{"dir", "project", "version"}),
+		refreshTime: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "exporter_last_refresh_timestamp_seconds",
+			Help:      "Time of the last refresh.",
+		}),
+		refreshSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "exporter_last_refresh_success",
+			Help:      "Whether the last refresh completed without errors.",
+		}),
+	}
+
+	e.registry.MustRegister(
+		e.latestBuild, e.latestBuildTime, e.supportStatus, e.supportEnd,
+		e.installedBuild, e.buildsBehind, e.refreshTime, e.refreshSuccess,
+	)
+
+	if c, ok := client.(*api.Client); ok {
+		c.HTTPClient.Transport = instrumentTransport(e.registry, c.HTTPClient.Transport)
+	}
+
+	return e
+}
+
+// WithInterval sets the time between two refreshes.
+func (e *Exporter) WithInterval(interval time.Duration) *Exporter {
+	e.Interval = interval
+	return e
+}
+
+// WithServerDirs sets the server directories whose installed build is reported.
+func (e *Exporter) WithServerDirs(dirs ...string) *Exporter {
+	e.ServerDirs = dirs
+	return e
+}
+
+// Handler returns the HTTP handler serving the metrics.
+func (e *Exporter) Handler() http.Handler {
+	return promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{})
+}
+
+// Run refreshes immediately and then every Interval until the context is canceled.
+func (e *Exporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Refresh(ctx); err != nil && e.OnError != nil && ctx.Err() == nil {
+			e.OnError(err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Refresh fetches the current state of all targets and server directories
+// and updates the metrics. Metrics of projects that fail keep their
+// previous values.
+func (e *Exporter) Refresh(ctx context.Context) error {
+	var errs []error
+
+	// Targets of the same project are refreshed together, as the metrics of
+	// a project are replaced as a whole.
+	var projects []string
+	constraints := make(map[string][]string)
+	for _, target := range e.Targets {
+		if _, ok := constraints[target.Project]; !ok {
+			projects = append(projects, target.Project)
+		}
+		constraints[target.Project] = append(constraints[target.Project], target.Version)
+	}
+
+	for _, project := range projects {
+		if err := e.refreshProject(ctx, project, constraints[project]); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to refresh %s", project))
+		}
+	}
+
+	e.installedBuild.Reset()
+	e.buildsBehind.Reset()
+	for _, dir := range e.ServerDirs {
+		if err := e.refreshServerDir(ctx, dir); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to refresh %s", dir))
+		}
+	}
+
+	e.refreshTime.SetToCurrentTime()
+	err := errors.Join(errs...)
+	if err != nil {
+		e.refreshSuccess.Set(0)
+	} else {
+		e.refreshSuccess.Set(1)
+	}
+
+	return err
+}
+
+// refreshProject updates the build and support metrics of the versions of
+// a project selected by any of the constraints.
+func (e *Exporter) refreshProject(ctx context.Context, project string, constraints []string) error {
+	projectInfo, err := e.Client.GetProject(ctx, project)
+	if err != nil {
+		return errors.Wrap(err, "failed to get project info")
+	}
+
+	// Collect everything first so a failure leaves the previous values intact.
+	var versions []string
+	for _, constraint := range constraints {
+		for _, version := range projectInfo.SelectVersions(constraint) {
+			if !slices.Contains(versions, version) {
+				versions = append(versions, version)
+			}
+		}
+	}
+	support := make(map[string]api.SupportInfo, len(versions))
+	latestByChannel := make(map[string]map[string]*api.BuildV3Response, len(versions))
+
+	for _, version := range versions {
+		versionInfo, err := e.Client.GetVersion(ctx, project, version)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get version %s", version)
+		}
+		support[version] = versionInfo.Version.Support
+
+		builds, err := e.Client.GetBuilds(ctx, project, version)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get builds of %s", version)
+		}
+
+		byChannel := make(map[string]*api.BuildV3Response)
+		for i := range builds {
+			build := &builds[i]
+			if cur, ok := byChannel[build.Channel]; !ok || build.ID > cur.ID {
+				byChannel[build.Channel] = build
+			}
+		}
+		latestByChannel[version] = byChannel
+	}
+
+	labels := prometheus.Labels{"project": project}
+	e.latestBuild.DeletePartialMatch(labels)
+	e.latestBuildTime.DeletePartialMatch(labels)
+	e.supportStatus.DeletePartialMatch(labels)
+	e.supportEnd.DeletePartialMatch(labels)
+
+	for _, version := range versions {
+		for channel, build := range latestByChannel[version] {
+			e.latestBuild.WithLabelValues(project, version, channel).Set(float64(build.ID))
+			e.latestBuildTime.WithLabelValues(project, version, channel).Set(float64(build.Time.Unix()))
+		}
+
+		info := support[version]
+		e.supportStatus.WithLabelValues(project, version, info.Status).Set(1)
+		if info.End != nil {
+			e.supportEnd.WithLabelValues(project, version).Set(float64(info.End.Unix()))
+		}
+	}
+
+	return nil
+}
+
+// refreshServerDir updates the installed build metrics of a server directory.
+func (e *Exporter) refreshServerDir(ctx context.Context, dir string) error {
+	install, err := serverdir.Detect(ctx, e.Client, dir, serverdir.DetectOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to detect installed build")
+	}
+
+	latest, err := e.Client.GetLatestBuildForChannel(ctx, install.Project, install.Version, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to get latest build")
+	}
+
+	e.installedBuild.WithLabelValues(dir, install.Project, install.Version).Set(float64(install.Build))
+	e.buildsBehind.WithLabelValues(dir, install.Project, install.Version).Set(float64(latest.ID - install.Build))
+
+	return nil
+}
+
+// instrumentTransport wraps an HTTP transport with API request metrics.
+func instrumentTransport(registry prometheus.Registerer, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_requests_total",
+		Help:      "API requests made by the client, by HTTP status code.",
+	}, []string{"code"})
+	errorsTotal := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_request_errors_total",
+		Help:      "API requests that failed without a response.",
+	})
+	duration := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of API requests made by the client.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	registry.MustRegister(requests, errorsTotal, duration)
+
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		duration.Observe(time.Since(start).Seconds())
+
+		if err != nil {
+			errorsTotal.Inc()
+			return nil, err
+		}
+		requests.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+
+		return resp, nil
+	})
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
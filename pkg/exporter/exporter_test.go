@@ -0,0 +1,179 @@
+package exporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	buildTime := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	supportEnd := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp any
+		switch r.URL.Path {
+		case "/v3/projects/paper":
+			resp = api.ProjectV3Response{
+				Project:  api.ProjectMeta{ID: "paper", Name: "Paper"},
+				Versions: map[string][]string{"1.21": {"1.21.11"}, "1.20": {"1.20.6"}},
+			}
+		case "/v3/projects/paper/versions/1.21.11":
+			resp = api.VersionV3Response{
+				Version: api.VersionMeta{ID: "1.21.11", Support: api.SupportInfo{Status: "SUPPORTED", End: &supportEnd}},
+			}
+		case "/v3/projects/paper/versions/1.21.11/builds":
+			resp = []api.BuildV3Response{
+				{ID: 80, Channel: "BETA", Time: buildTime.Add(time.Hour)},
+				{ID: 74, Channel: "STABLE", Time: buildTime},
+				{ID: 70, Channel: "STABLE", Time: buildTime.Add(-time.Hour)},
+			}
+		case "/v3/projects/paper/versions/1.20.6":
+			resp = api.VersionV3Response{Version: api.VersionMeta{ID: "1.20.6", Support: api.SupportInfo{Status: "DEPRECATED"}}}
+		case "/v3/projects/paper/versions/1.20.6/builds":
+			resp = []api.BuildV3Response{{ID: 151, Channel: "STABLE", Time: buildTime.Add(-24 * time.Hour)}}
+		case "/v3/projects/paper/versions/1.21.11/builds/latest":
+			resp = api.BuildV3Response{ID: 80, Channel: "BETA", Time: buildTime.Add(time.Hour)}
+		default:
+			t.Errorf("Unexpected request path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Fatalf("Failed to encode response: %v", err)
+		}
+	}))
+}
+
+func scrape(t *testing.T, e *Exporter) string {
+	t.Helper()
+
+	server := httptest.NewServer(e.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read metrics: %v", err)
+	}
+
+	return string(body)
+}
+
+func TestExporter_Refresh(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	// A server directory with build 70 recorded in its lock file.
+	dir := t.TempDir()
+	jar := []byte("paper 1.21.11 build 70")
+	if err := os.WriteFile(filepath.Join(dir, serverdir.DefaultJar), jar, 0o644); err != nil {
+		t.Fatalf("Failed to write jar: %v", err)
+	}
+	sum := sha256.Sum256(jar)
+	lock := &serverdir.Lock{Install: serverdir.Install{
+		Project: "paper", Version: "1.21.11", Build: 70,
+		File: serverdir.DefaultJar, SHA256: hex.EncodeToString(sum[:]),
+	}}
+	if err := serverdir.WriteLock(dir, lock); err != nil {
+		t.Fatalf("Failed to write lock: %v", err)
+	}
+
+	client := api.NewClient().WithBaseURL(server.URL)
+	e := New(client, api.WatchTarget{Project: "paper", Version: "1.21.x"}).WithServerDirs(dir)
+
+	if err := e.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	metrics := scrape(t, e)
+	expected := []string{
+		`papermc_latest_build{channel="STABLE",project="paper",version="1.21.11"} 74`,
+		`papermc_latest_build{channel="BETA",project="paper",version="1.21.11"} 80`,
+		`papermc_latest_build_timestamp_seconds{channel="STABLE",project="paper",version="1.21.11"} 1.7487792e+09`,
+		`papermc_version_support_status{project="paper",status="SUPPORTED",version="1.21.11"} 1`,
+		`papermc_version_support_end_timestamp_seconds{project="paper",version="1.21.11"} 1.7672256e+09`,
+		`papermc_installed_build{dir="` + dir + `",project="paper",version="1.21.11"} 70`,
+		`papermc_installed_builds_behind{dir="` + dir + `",project="paper",version="1.21.11"} 10`,
+		`papermc_api_requests_total{code="200"} 4`,
+		`papermc_exporter_last_refresh_success 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, metrics)
+		}
+	}
+
+	if strings.Contains(metrics, `version="1.20.6"`) {
+		t.Errorf("Expected 1.20.6 not to be exported, got:\n%s", metrics)
+	}
+}
+
+func TestExporter_RefreshTargetsOfOneProject(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := api.NewClient().WithBaseURL(server.URL)
+	e := New(client, api.WatchTarget{Project: "paper", Version: "1.20.x"}, api.WatchTarget{Project: "paper", Version: "1.21.x"})
+
+	// The second refresh must not drop what the first target of the project set.
+	for range 2 {
+		if err := e.Refresh(context.Background()); err != nil {
+			t.Fatalf("Refresh failed: %v", err)
+		}
+	}
+
+	metrics := scrape(t, e)
+	for _, line := range []string{
+		`papermc_latest_build{channel="STABLE",project="paper",version="1.20.6"} 151`,
+		`papermc_latest_build{channel="STABLE",project="paper",version="1.21.11"} 74`,
+		`papermc_version_support_status{project="paper",status="DEPRECATED",version="1.20.6"} 1`,
+		`papermc_version_support_status{project="paper",status="SUPPORTED",version="1.21.11"} 1`,
+	} {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, metrics)
+		}
+	}
+}
+
+func TestExporter_RefreshError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	e := New(api.NewClient().WithBaseURL(server.URL), api.WatchTarget{Project: "paper"})
+	if err := e.Refresh(context.Background()); err == nil {
+		t.Fatal("Expected refresh to fail")
+	}
+
+	metrics := scrape(t, e)
+	for _, line := range []string{
+		`papermc_exporter_last_refresh_success 0`,
+		`papermc_api_requests_total{code="500"} 1`,
+	} {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, metrics)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Package fleet reports what a set of running servers is deployed with.
+//
+// Every server is asked for its status with a Server List Ping. The version
+// name it reports, such as "Paper 1.21.11", is mapped back to a project and
+// version published in the API and compared with the newest version and
+// build. Servers given as a directory also contribute the build recorded in
+// their papermc.lock, which the ping does not reveal.
+package fleet
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/ping"
+	"github.com/lexfrei/goPaperMC/pkg/properties"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+)
+
+const (
+	// DefaultProject is assumed for servers reporting a bare version.
+	DefaultProject = "paper"
+	// DefaultConcurrency is the number of servers pinged in parallel.
+	DefaultConcurrency = 16
+)
+
+// Target is a server to check.
+type Target struct {
+	Name    string // Display name
+	Address string // Address to ping, "host:port"
+	Dir     string // Server directory with a papermc.lock (optional)
+}
+
+// ParseTarget parses a server directory or a "host[:port]" address. The
+// address of a directory is read from its server.properties; servers
+// listening on every interface are pinged on localhost.
+func ParseTarget(arg string) (Target, error) {
+	info, err := os.Stat(arg)
+	if err != nil || !info.IsDir() {
+		addr := arg
+		if _, _, err := net.SplitHostPort(arg); err != nil {
+			addr = net.JoinHostPort(arg, strconv.Itoa(ping.DefaultPort))
+		}
+
+		return Target{Name: arg, Address: addr}, nil
+	}
+
+	props, err := properties.Load(filepath.Join(arg, properties.ServerFile))
+	if err != nil {
+		return Target{}, err
+	}
+
+	port, err := strconv.Atoi(props.Value("server-port", strconv.Itoa(ping.DefaultPort)))
+	if err != nil {
+		return Target{}, errors.Wrapf(err, "invalid server-port in %s", filepath.Join(arg, properties.ServerFile))
+	}
+
+	host := props.Value("server-ip", "")
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	return Target{Name: arg, Address: net.JoinHostPort(host, strconv.Itoa(port)), Dir: arg}, nil
+}
+
+// Options control Check.
+type Options struct {
+	Project     string      // Project of servers reporting a bare version (defaults to DefaultProject)
+	Channel     api.Channel // Only compare with builds in this channel (empty means any)
+	Concurrency int         // Servers pinged in parallel (defaults to DefaultConcurrency)
+
+	// Ping requests the status of a server. It defaults to ping.Ping.
+	Ping func(ctx context.Context, addr string) (*ping.Status, error)
+}
+
+// Report is the state of one server.
+type Report struct {
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Online   bool   `json:"online"`
+	Error    string `json:"error,omitempty"`
+	Reported string `json:"reported_version,omitempty"` // Version name from the ping
+	Protocol int    `json:"protocol,omitempty"`
+	Players  int    `json:"players"`
+	Max      int    `json:"max_players"`
+
+	Project       string `json:"project,omitempty"` // Project and version the reported name maps to
+	Version       string `json:"version,omitempty"`
+	Build         int32  `json:"build,omitempty"` // Running build, if known
+	LatestVersion string `json:"latest_version,omitempty"`
+	LatestBuild   int32  `json:"latest_build,omitempty"` // Newest build of the running version
+
+	Behind []string `json:"behind,omitempty"` // Why the server is out of date
+}
+
+// IsBehind reports whether the server runs an outdated version or build.
+func (r *Report) IsBehind() bool {
+	return len(r.Behind) > 0
+}
+
+// Check pings every target and compares what it runs with the newest
+// version and build of its project. Reports are returned in target order;
+// servers that cannot be pinged are reported offline rather than failing
+// the check. An error is returned only if the projects cannot be listed.
+func Check(ctx context.Context, client api.API, targets []Target, opts Options) ([]Report, error) {
+	opts.setDefaults()
+
+	projects, err := client.GetProjects(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list projects")
+	}
+
+	c := &checker{
+		client:   client,
+		opts:     opts,
+		projects: make(map[string][]string),
+		latest:   make(map[string]*latest),
+	}
+	for _, p := range projects.Projects {
+		info := api.ProjectV3Response{Project: p.Project, Versions: p.Versions}
+		c.projects[p.Project.ID] = info.FlattenVersions()
+	}
+
+	reports := make([]Report, len(targets))
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, opts.Concurrency)
+	)
+
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			reports[i] = c.check(ctx, target)
+		}()
+	}
+
+	wg.Wait()
+
+	return reports, nil
+}
+
+// setDefaults fills in unset options.
+func (o *Options) setDefaults() {
+	if o.Project == "" {
+		o.Project = DefaultProject
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = DefaultConcurrency
+	}
+	if o.Ping == nil {
+		o.Ping = ping.Ping
+	}
+}
+
+// latest is the newest version of a project.
+type latest struct {
+	once    sync.Once
+	version string
+	err     error
+}
+
+// checker holds the state shared by the checks of one Check call.
+type checker struct {
+	client   api.API
+	opts     Options
+	projects map[string][]string // Versions of every project, oldest first
+
+	mu     sync.Mutex
+	latest map[string]*latest
+}
+
+// check pings a target and compares it with the newest builds.
+func (c *checker) check(ctx context.Context, target Target) Report {
+	report := Report{Name: target.Name, Address: target.Address}
+	if report.Name == "" {
+		report.Name = target.Address
+	}
+
+	status, err := c.opts.Ping(ctx, target.Address)
+	if err != nil {
+		report.Error = errors.UnwrapAll(err).Error()
+		return report
+	}
+
+	report.Online = true
+	report.Reported = status.Version.Name
+	report.Protocol = status.Version.Protocol
+	report.Players = status.Players.Online
+	report.Max = status.Players.Max
+
+	report.Project, report.Version, report.Build = MatchVersion(status.Version.Name, c.projects, c.opts.Project)
+	if report.Version == "" {
+		report.Error = "reported version does not match a published version"
+		return report
+	}
+
+	if target.Dir != "" {
+		if lock, err := serverdir.ReadLock(target.Dir); err == nil && lock.Project == report.Project {
+			if lock.Version == report.Version {
+				report.Build = lock.Build
+			} else {
+				report.Behind = append(report.Behind, "runs "+report.Version+" but "+lock.Version+" is installed, restart pending")
+			}
+		}
+	}
+
+	if err := c.compare(ctx, &report); err != nil {
+		report.Error = errors.UnwrapAll(err).Error()
+	}
+
+	return report
+}
+
+// compare fills in the newest version and build and the reasons the server
+// is behind.
+func (c *checker) compare(ctx context.Context, report *Report) error {
+	newest, err := c.latestVersion(ctx, report.Project)
+	if err != nil {
+		return err
+	}
+	report.LatestVersion = newest
+
+	if api.CompareVersions(report.Version, newest) < 0 {
+		report.Behind = append(report.Behind, "version "+newest+" is available")
+	}
+
+	build, err := c.client.GetLatestBuildForChannel(ctx, report.Project, report.Version, c.opts.Channel)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get latest build of %s %s", report.Project, report.Version)
+	}
+	report.LatestBuild = build.ID
+
+	if report.Build != 0 && report.Build < build.ID {
+		report.Behind = append(report.Behind, "build "+strconv.Itoa(int(build.ID))+" of "+report.Version+" is available")
+	}
+
+	return nil
+}
+
+// latestVersion returns the newest version of a project, resolving it once
+// per check. For Velocity, which publishes its releases as -SNAPSHOT
+// versions, this is its newest -SNAPSHOT version.
+func (c *checker) latestVersion(ctx context.Context, project string) (string, error) {
+	c.mu.Lock()
+	l, ok := c.latest[project]
+	if !ok {
+		l = &latest{}
+		c.latest[project] = l
+	}
+	c.mu.Unlock()
+
+	l.once.Do(func() {
+		l.version, l.err = c.client.ResolveVersion(ctx, project, "latest", c.opts.Channel)
+	})
+
+	return l.version, l.err
+}
+
+// buildPattern matches the build in Velocity's version names, e.g.
+// "Velocity 3.4.0-SNAPSHOT (git-5c0e0b3a-b500)".
+var buildPattern = regexp.MustCompile(`\bgit-[0-9a-f]+-b(\d+)\b`)
+
+// MatchVersion maps a reported version name to a project and one of its
+// versions, given the versions of every project. A project ID appearing as
+// a word of the name, like "Paper" in "Paper 1.21.11", selects the project;
+// otherwise defaultProject is assumed. The version is the first word that
+// is a version of the project, and the build is taken from a git-HASH-bN
+// suffix if there is one. An empty version means nothing matched.
+func MatchVersion(name string, projects map[string][]string, defaultProject string) (string, string, int32) {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == ' ' || r == '(' || r == ')' || r == ',' || r == '/'
+	})
+
+	project := defaultProject
+	for _, word := range words {
+		if _, ok := projects[strings.ToLower(word)]; ok {
+			project = strings.ToLower(word)
+			break
+		}
+	}
+
+	var build int32
+	if m := buildPattern.FindStringSubmatch(name); m != nil {
+		if n, err := strconv.ParseInt(m[1], 10, 32); err == nil {
+			build = int32(n)
+		}
+	}
+
+	for _, word := range words {
+		if slices.Contains(projects[project], word) {
+			return project, word, build
+		}
+	}
+
+	return project, "", 0
+}
@@ -0,0 +1,153 @@
+package fleet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/api/apitest"
+	"github.com/lexfrei/goPaperMC/pkg/ping"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+)
+
+func newTestServer(t *testing.T) *apitest.Server {
+	t.Helper()
+
+	return apitest.NewServer(t, apitest.NewBuilder().
+		Project("paper").
+		Version("1.21", "1.21.10").
+		Build(120, api.ChannelStable).
+		Build(130, api.ChannelStable).
+		Version("1.21", "1.21.11").
+		Build(74, api.ChannelStable).
+		Build(75, api.ChannelStable).
+		Project("velocity").
+		Version("3.0.0", "3.1.1").
+		Build(102, api.ChannelStable).
+		Version("3.0.0", "3.4.0-SNAPSHOT").
+		Build(500, api.ChannelStable).
+		Build(510, api.ChannelStable))
+}
+
+// fakePing answers with the version names of addresses.
+func fakePing(versions map[string]string) func(context.Context, string) (*ping.Status, error) {
+	return func(_ context.Context, addr string) (*ping.Status, error) {
+		name, ok := versions[addr]
+		if !ok {
+			return nil, errors.Newf("failed to connect to %s: connection refused", addr)
+		}
+
+		return &ping.Status{Version: ping.Version{Name: name, Protocol: 774}, Players: ping.Players{Online: 3, Max: 20}}, nil
+	}
+}
+
+func TestCheck(t *testing.T) {
+	server := newTestServer(t)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "server.properties"), []byte("server-port=25570\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := serverdir.WriteLock(dir, &serverdir.Lock{Install: serverdir.Install{Project: "paper", Version: "1.21.11", Build: 74}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local, err := ParseTarget(dir)
+	if err != nil {
+		t.Fatalf("ParseTarget failed: %v", err)
+	}
+	if local.Address != "127.0.0.1:25570" || local.Dir != dir {
+		t.Fatalf("Unexpected target: %+v", local)
+	}
+
+	remote, _ := ParseTarget("old.example.com")
+	proxy, _ := ParseTarget("proxy.example.com:25577")
+	down, _ := ParseTarget("down.example.com")
+	odd, _ := ParseTarget("odd.example.com")
+
+	reports, err := Check(context.Background(), server.Client(), []Target{local, remote, proxy, down, odd}, Options{
+		Ping: fakePing(map[string]string{
+			"127.0.0.1:25570":         "Paper 1.21.11",
+			"old.example.com:25565":   "1.21.10",
+			"proxy.example.com:25577": "Velocity 3.4.0-SNAPSHOT (git-5c0e0b3a-b500)",
+			"odd.example.com:25565":   "Paper 1.8.8",
+		}),
+	})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if r := reports[0]; r.Project != "paper" || r.Version != "1.21.11" || r.Build != 74 || r.LatestBuild != 75 ||
+		!slices.Equal(r.Behind, []string{"build 75 of 1.21.11 is available"}) {
+		t.Errorf("Unexpected report for the server directory: %+v", r)
+	}
+	if r := reports[1]; r.Version != "1.21.10" || r.Build != 0 || r.LatestVersion != "1.21.11" ||
+		!slices.Equal(r.Behind, []string{"version 1.21.11 is available"}) {
+		t.Errorf("Unexpected report for the bare version: %+v", r)
+	}
+	if r := reports[2]; r.Project != "velocity" || r.Version != "3.4.0-SNAPSHOT" || r.Build != 500 || r.LatestVersion != "3.4.0-SNAPSHOT" ||
+		!slices.Equal(r.Behind, []string{"build 510 of 3.4.0-SNAPSHOT is available"}) {
+		t.Errorf("Unexpected report for the proxy: %+v", r)
+	}
+	if r := reports[3]; r.Online || r.Error == "" {
+		t.Errorf("Expected the unreachable server to be offline: %+v", r)
+	}
+	if r := reports[4]; !r.Online || r.Version != "" || r.Error == "" || r.IsBehind() {
+		t.Errorf("Expected an unknown version to be reported: %+v", r)
+	}
+}
+
+func TestCheck_RestartPending(t *testing.T) {
+	server := newTestServer(t)
+
+	dir := t.TempDir()
+	err := serverdir.WriteLock(dir, &serverdir.Lock{Install: serverdir.Install{Project: "paper", Version: "1.21.11", Build: 75}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reports, err := Check(context.Background(), server.Client(), []Target{{Address: "127.0.0.1:25565", Dir: dir}}, Options{
+		Ping: fakePing(map[string]string{"127.0.0.1:25565": "Paper 1.21.10"}),
+	})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	r := reports[0]
+	if r.Name != "127.0.0.1:25565" || r.Build != 0 || len(r.Behind) != 2 {
+		t.Errorf("Expected a pending restart and a newer version, got %+v", r)
+	}
+}
+
+func TestMatchVersion(t *testing.T) {
+	projects := map[string][]string{
+		"paper":    {"1.21.10", "1.21.11"},
+		"folia":    {"1.21.8"},
+		"velocity": {"3.4.0-SNAPSHOT"},
+	}
+
+	tests := []struct {
+		name                     string
+		wantProject, wantVersion string
+		wantBuild                int32
+	}{
+		{"Paper 1.21.11", "paper", "1.21.11", 0},
+		{"Folia 1.21.8", "folia", "1.21.8", 0},
+		{"1.21.10", "paper", "1.21.10", 0},
+		{"Velocity 3.4.0-SNAPSHOT (git-5c0e0b3a-b500)", "velocity", "3.4.0-SNAPSHOT", 500},
+		{"Velocity 1.7.2-1.21.11", "velocity", "", 0},
+	}
+
+	for _, tt := range tests {
+		project, version, build := MatchVersion(tt.name, projects, DefaultProject)
+		if project != tt.wantProject || version != tt.wantVersion || build != tt.wantBuild {
+			t.Errorf("MatchVersion(%q) = %s %s %d, expected %s %s %d",
+				tt.name, project, version, build, tt.wantProject, tt.wantVersion, tt.wantBuild)
+		}
+	}
+}
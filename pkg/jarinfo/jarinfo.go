@@ -0,0 +1,403 @@
+// Package jarinfo inspects server jars offline.
+//
+// It reads the metadata Paper, Folia and Velocity embed in their jars: the
+// manifest implementation version, the Minecraft version.json and, for
+// Paperclip jars, the bundled version, library and patch listings under
+// META-INF. No network access is required.
+package jarinfo
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+// maxNestedJarSize bounds how much of a bundled jar is read into memory.
+const maxNestedJarSize = 256 << 20
+
+// Report is the metadata found in a server jar.
+type Report struct {
+	Path   string `json:"path,omitempty"`
+	SHA256 string `json:"sha256"`
+
+	MainClass             string `json:"main_class,omitempty"`
+	ImplementationTitle   string `json:"implementation_title,omitempty"`
+	ImplementationVendor  string `json:"implementation_vendor,omitempty"`
+	ImplementationVersion string `json:"implementation_version,omitempty"`
+
+	// Project is the lowercase project ID guessed from the implementation
+	// title, e.g. "paper" or "velocity".
+	Project          string `json:"project,omitempty"`
+	MinecraftVersion string `json:"minecraft_version,omitempty"`
+	Build            int32  `json:"build,omitempty"` // Zero if unknown
+	Commit           string `json:"commit,omitempty"`
+
+	Paperclip bool            `json:"paperclip"`
+	Minecraft *MinecraftInfo  `json:"minecraft,omitempty"` // Contents of version.json
+	Versions  []BundledFile   `json:"versions,omitempty"`
+	Libraries []BundledFile   `json:"libraries,omitempty"`
+	Patches   []Patch         `json:"patches,omitempty"`
+	Download  *DownloadSource `json:"download_context,omitempty"`
+}
+
+// MinecraftInfo is the Minecraft version.json embedded in server jars.
+type MinecraftInfo struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	WorldVersion    int       `json:"world_version"`
+	ProtocolVersion int       `json:"protocol_version"`
+	JavaVersion     int       `json:"java_version"`
+	BuildTime       time.Time `json:"build_time"`
+	Stable          bool      `json:"stable"`
+}
+
+// BundledFile is an entry of a Paperclip versions.list or libraries.list.
+type BundledFile struct {
+	SHA256 string `json:"sha256"`
+	ID     string `json:"id"`
+	Path   string `json:"path"`
+}
+
+// Patch is an entry of a Paperclip patches.list.
+type Patch struct {
+	Location     string `json:"location"`
+	OriginalHash string `json:"original_sha256"`
+	PatchHash    string `json:"patch_sha256"`
+	OutputHash   string `json:"output_sha256"`
+	OriginalPath string `json:"original_path"`
+	PatchPath    string `json:"patch_path"`
+	OutputPath   string `json:"output_path"`
+}
+
+// DownloadSource is the Paperclip download-context describing the vanilla
+// server jar the patches are applied to.
+type DownloadSource struct {
+	SHA256 string `json:"sha256"`
+	URL    string `json:"url"`
+	Name   string `json:"name"`
+}
+
+// Inspect reads the embedded metadata of the jar at path.
+func Inspect(path string) (*Report, error) {
+	sum, err := api.FileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open jar")
+	}
+	defer func() { _ = zr.Close() }()
+
+	report, err := inspect(&zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Path = path
+	report.SHA256 = sum
+
+	return report, nil
+}
+
+// InspectReader reads the embedded metadata of a jar from r. The SHA256
+// field of the report is left empty.
+func InspectReader(r io.ReaderAt, size int64) (*Report, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open jar")
+	}
+
+	return inspect(zr)
+}
+
+// inspect collects the metadata of an opened jar.
+func inspect(zr *zip.Reader) (*Report, error) {
+	report := &Report{}
+
+	manifest, err := readManifest(zr)
+	if err != nil {
+		return nil, err
+	}
+	report.applyManifest(manifest)
+
+	if report.Minecraft, err = readVersionJSON(zr); err != nil {
+		return nil, err
+	}
+
+	for name, target := range map[string]*[]BundledFile{
+		"META-INF/versions.list":  &report.Versions,
+		"META-INF/libraries.list": &report.Libraries,
+	} {
+		lines, ok, err := readLines(zr, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			report.Paperclip = true
+			*target = parseBundledFiles(lines)
+		}
+	}
+
+	if lines, ok, err := readLines(zr, "META-INF/patches.list"); err != nil {
+		return nil, err
+	} else if ok {
+		report.Patches = parsePatches(lines)
+	}
+
+	if lines, ok, err := readLines(zr, "META-INF/download-context"); err != nil {
+		return nil, err
+	} else if ok && len(lines) > 0 {
+		if fields := strings.Split(lines[0], "\t"); len(fields) >= 3 {
+			report.Download = &DownloadSource{SHA256: fields[0], URL: fields[1], Name: fields[2]}
+		}
+	}
+
+	// The root manifest of a Paperclip jar describes the launcher only; the
+	// server's own manifest and version.json live in the bundled jar.
+	if report.Paperclip && len(report.Versions) > 0 {
+		if err := report.inspectBundled(zr, report.Versions[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	if report.MinecraftVersion == "" && report.Minecraft != nil {
+		report.MinecraftVersion = report.Minecraft.ID
+	}
+
+	return report, nil
+}
+
+// inspectBundled fills in fields missing from the root jar using the
+// bundled server jar.
+func (r *Report) inspectBundled(zr *zip.Reader, bundled BundledFile) error {
+	file, err := zr.Open(path.Join("META-INF/versions", bundled.Path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to open bundled jar")
+	}
+	defer func() { _ = file.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxNestedJarSize))
+	if err != nil {
+		return errors.Wrap(err, "failed to read bundled jar")
+	}
+
+	inner, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return errors.Wrap(err, "failed to open bundled jar")
+	}
+
+	manifest, err := readManifest(inner)
+	if err != nil {
+		return err
+	}
+
+	bundledReport := &Report{}
+	bundledReport.applyManifest(manifest)
+
+	if bundledReport.ImplementationVersion != "" {
+		r.ImplementationTitle = bundledReport.ImplementationTitle
+		r.ImplementationVendor = bundledReport.ImplementationVendor
+		r.ImplementationVersion = bundledReport.ImplementationVersion
+		r.Project = bundledReport.Project
+		r.MinecraftVersion = bundledReport.MinecraftVersion
+		r.Build = bundledReport.Build
+		r.Commit = bundledReport.Commit
+	}
+
+	if r.Minecraft == nil {
+		if r.Minecraft, err = readVersionJSON(inner); err != nil {
+			return err
+		}
+	}
+
+	if r.MinecraftVersion == "" {
+		r.MinecraftVersion = bundled.ID
+	}
+
+	return nil
+}
+
+var (
+	// Paper 1.20.5+: "1.21.4-232-a1b2c3d (MC: 1.21.4)".
+	modernVersionRe = regexp.MustCompile(`^(\S+)-(\d+)-([0-9a-f]+)\s+\(MC:\s*([^)]+)\)`)
+	// Older Paper: "git-Paper-232 (MC: 1.20.4)".
+	legacyVersionRe = regexp.MustCompile(`^git-[A-Za-z]+-(\d+)\s+\(MC:\s*([^)]+)\)`)
+	// Velocity: "3.4.0-SNAPSHOT (git-a1b2c3d-b500)".
+	velocityVersionRe = regexp.MustCompile(`\(git-([0-9a-f]+)-b(\d+)\)`)
+)
+
+// applyManifest copies manifest attributes into the report and derives the
+// build number, commit and Minecraft version from the implementation version.
+func (r *Report) applyManifest(manifest map[string]string) {
+	r.MainClass = manifest["Main-Class"]
+	r.ImplementationTitle = manifest["Implementation-Title"]
+	r.ImplementationVendor = manifest["Implementation-Vendor"]
+	r.ImplementationVersion = manifest["Implementation-Version"]
+
+	if title := strings.ToLower(r.ImplementationTitle); title != "" && !strings.ContainsAny(title, " \t") {
+		r.Project = title
+	}
+
+	version := r.ImplementationVersion
+	if m := modernVersionRe.FindStringSubmatch(version); m != nil {
+		r.Build = parseBuild(m[2])
+		r.Commit = m[3]
+		r.MinecraftVersion = strings.TrimSpace(m[4])
+	} else if m := legacyVersionRe.FindStringSubmatch(version); m != nil {
+		r.Build = parseBuild(m[1])
+		r.MinecraftVersion = strings.TrimSpace(m[2])
+	} else if m := velocityVersionRe.FindStringSubmatch(version); m != nil {
+		r.Commit = m[1]
+		r.Build = parseBuild(m[2])
+	}
+}
+
+// parseBuild converts a build number, returning zero if it is invalid.
+func parseBuild(s string) int32 {
+	build, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0
+	}
+
+	return int32(build)
+}
+
+// MatchesBuild reports whether the inspected jar is one of the downloads of
+// a build. The checksum is authoritative when known; otherwise the embedded
+// Minecraft version and build number are compared.
+func (r *Report) MatchesBuild(version string, build *api.BuildV3Response) bool {
+	if r.SHA256 != "" {
+		for _, download := range build.Downloads {
+			if download.Checksums.SHA256 == r.SHA256 {
+				return true
+			}
+		}
+	}
+
+	return r.Build != 0 && r.Build == build.ID && r.MinecraftVersion == version
+}
+
+// readManifest parses META-INF/MANIFEST.MF, returning the main section's attributes.
+func readManifest(zr *zip.Reader) (map[string]string, error) {
+	lines, ok, err := readLines(zr, "META-INF/MANIFEST.MF")
+	if err != nil || !ok {
+		return map[string]string{}, err
+	}
+
+	attrs := make(map[string]string)
+	var last string
+	for _, line := range lines {
+		switch {
+		case line == "":
+			// The main section ends at the first blank line.
+			return attrs, nil
+		case strings.HasPrefix(line, " ") && last != "":
+			// Continuation lines start with a single space.
+			attrs[last] += line[1:]
+		default:
+			key, value, found := strings.Cut(line, ":")
+			if !found {
+				continue
+			}
+			last = strings.TrimSpace(key)
+			attrs[last] = strings.TrimSpace(value)
+		}
+	}
+
+	return attrs, nil
+}
+
+// readVersionJSON parses the Minecraft version.json at the jar root, if any.
+func readVersionJSON(zr *zip.Reader) (*MinecraftInfo, error) {
+	file, err := zr.Open("version.json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open version.json")
+	}
+	defer func() { _ = file.Close() }()
+
+	var info MinecraftInfo
+	if err := json.NewDecoder(file).Decode(&info); err != nil {
+		return nil, errors.Wrap(err, "failed to decode version.json")
+	}
+
+	return &info, nil
+}
+
+// readLines returns the lines of a text entry and whether the entry exists.
+func readLines(zr *zip.Reader, name string) ([]string, bool, error) {
+	file, err := zr.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to open %s", name)
+	}
+	defer func() { _ = file.Close() }()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, errors.Wrapf(err, "failed to read %s", name)
+	}
+
+	return lines, true, nil
+}
+
+// parseBundledFiles parses "sha256<TAB>id<TAB>path" lines.
+func parseBundledFiles(lines []string) []BundledFile {
+	files := make([]BundledFile, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		files = append(files, BundledFile{SHA256: fields[0], ID: fields[1], Path: fields[2]})
+	}
+
+	return files
+}
+
+// parsePatches parses patches.list lines of the form
+// "location<TAB>original hash<TAB>patch hash<TAB>output hash<TAB>original path<TAB>patch path<TAB>output path".
+func parsePatches(lines []string) []Patch {
+	patches := make([]Patch, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			continue
+		}
+		patches = append(patches, Patch{
+			Location:     fields[0],
+			OriginalHash: fields[1],
+			PatchHash:    fields[2],
+			OutputHash:   fields[3],
+			OriginalPath: fields[4],
+			PatchPath:    fields[5],
+			OutputPath:   fields[6],
+		})
+	}
+
+	return patches
+}
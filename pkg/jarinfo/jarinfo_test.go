@@ -0,0 +1,142 @@
+package jarinfo
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+// buildJar creates a jar containing the given entries.
+func buildJar(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestInspect_Paperclip(t *testing.T) {
+	inner := buildJar(t, map[string][]byte{
+		"META-INF/MANIFEST.MF": []byte("Manifest-Version: 1.0\r\n" +
+			"Implementation-Title: Paper\r\n" +
+			"Implementation-Version: 1.21.4-232-a1b2c3d (MC: 1.21.4)\r\n" +
+			"Implementation-Vendor: 2025-01-01T00:00:00Z\r\n\r\n" +
+			"Name: ignored\r\nImplementation-Title: Other\r\n"),
+		"version.json": []byte(`{"id":"1.21.4","name":"1.21.4","world_version":4189,"protocol_version":769,"java_version":21,"stable":true}`),
+	})
+
+	outer := buildJar(t, map[string][]byte{
+		"META-INF/MANIFEST.MF":                      []byte("Manifest-Version: 1.0\nMain-Class: io.papermc.paperclip.Main\n"),
+		"META-INF/versions.list":                    []byte("aaa\t1.21.4\t1.21.4/paper-1.21.4.jar\n"),
+		"META-INF/libraries.list":                   []byte("bbb\tcom.google.guava:guava:33.3.1-jre\tcom/google/guava/guava/33.3.1-jre/guava-33.3.1-jre.jar\n"),
+		"META-INF/patches.list":                     []byte("versions\tccc\tddd\taaa\t1.21.4/server-1.21.4.jar\t1.21.4/paper-1.21.4.jar.patch\t1.21.4/paper-1.21.4.jar\n"),
+		"META-INF/download-context":                 []byte("eee\thttps://piston-data.mojang.com/v1/objects/eee/server.jar\tmojang_1.21.4.jar\n"),
+		"META-INF/versions/1.21.4/paper-1.21.4.jar": inner,
+	})
+
+	path := filepath.Join(t.TempDir(), "server.jar")
+	if err := os.WriteFile(path, outer, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Inspect(path)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+
+	if !report.Paperclip {
+		t.Error("Expected a Paperclip jar")
+	}
+	if report.Project != "paper" || report.MinecraftVersion != "1.21.4" || report.Build != 232 || report.Commit != "a1b2c3d" {
+		t.Errorf("Unexpected version info: project=%q mc=%q build=%d commit=%q",
+			report.Project, report.MinecraftVersion, report.Build, report.Commit)
+	}
+	if report.MainClass != "io.papermc.paperclip.Main" {
+		t.Errorf("Expected Paperclip main class, got %q", report.MainClass)
+	}
+	if report.Minecraft == nil || report.Minecraft.ProtocolVersion != 769 || report.Minecraft.JavaVersion != 21 {
+		t.Errorf("Unexpected version.json: %+v", report.Minecraft)
+	}
+	if len(report.Libraries) != 1 || report.Libraries[0].ID != "com.google.guava:guava:33.3.1-jre" {
+		t.Errorf("Unexpected libraries: %+v", report.Libraries)
+	}
+	if len(report.Patches) != 1 || report.Patches[0].OutputPath != "1.21.4/paper-1.21.4.jar" {
+		t.Errorf("Unexpected patches: %+v", report.Patches)
+	}
+	if report.Download == nil || report.Download.Name != "mojang_1.21.4.jar" {
+		t.Errorf("Unexpected download context: %+v", report.Download)
+	}
+
+	sum, err := api.FileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SHA256 != sum {
+		t.Errorf("Expected SHA256 %s, got %s", sum, report.SHA256)
+	}
+
+	if !report.MatchesBuild("1.21.4", &api.BuildV3Response{ID: 232}) {
+		t.Error("Expected report to match build 232 by version and build number")
+	}
+	if report.MatchesBuild("1.21.4", &api.BuildV3Response{ID: 231}) {
+		t.Error("Expected report not to match build 231")
+	}
+}
+
+func TestInspectReader_ImplementationVersions(t *testing.T) {
+	tests := []struct {
+		version string
+		build   int32
+		mc      string
+		commit  string
+	}{
+		{"git-Paper-496 (MC: 1.20.4)", 496, "1.20.4", ""},
+		{"3.4.0-SNAPSHOT (git-0123abc-b500)", 500, "", "0123abc"},
+		{"1.0", 0, "", ""},
+	}
+
+	for _, tt := range tests {
+		data := buildJar(t, map[string][]byte{
+			"META-INF/MANIFEST.MF": []byte("Implementation-Title: Velocity\nImplementation-Version: " + tt.version + "\n"),
+		})
+
+		report, err := InspectReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("InspectReader(%q) failed: %v", tt.version, err)
+		}
+		if report.Build != tt.build || report.MinecraftVersion != tt.mc || report.Commit != tt.commit {
+			t.Errorf("%q: got build=%d mc=%q commit=%q", tt.version, report.Build, report.MinecraftVersion, report.Commit)
+		}
+		if report.Paperclip {
+			t.Errorf("%q: expected a plain jar", tt.version)
+		}
+	}
+}
+
+func TestInspect_NotAJar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.jar")
+	if err := os.WriteFile(path, []byte("not a zip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Inspect(path); err == nil {
+		t.Error("Expected an error for a file that is not a jar")
+	}
+}
@@ -0,0 +1,36 @@
+package java
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+// Report lists the local runtimes able to run a version.
+type Report struct {
+	Project  string    `json:"project"`
+	Version  string    `json:"version"`
+	Minimum  int       `json:"minimum"`
+	Runtimes []Runtime `json:"runtimes"` // All discovered runtimes
+	Usable   []Runtime `json:"usable"`   // Runtimes meeting the minimum, newest first
+}
+
+// Check discovers the local runtimes and compares them with the minimum Java
+// version the API lists for a version of a project.
+func Check(ctx context.Context, client api.API, project, version string, opts Options) (*Report, error) {
+	info, err := client.GetVersion(ctx, project, version)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get version info")
+	}
+
+	runtimes := Discover(opts)
+
+	return &Report{
+		Project:  project,
+		Version:  version,
+		Minimum:  info.Version.Java.Version.Minimum,
+		Runtimes: runtimes,
+		Usable:   Compatible(runtimes, info.Version.Java.Version.Minimum),
+	}, nil
+}
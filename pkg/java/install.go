@@ -0,0 +1,403 @@
+package java
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// DefaultAdoptiumURL is the base URL of the Adoptium API.
+	DefaultAdoptiumURL = "https://api.adoptium.net"
+	// DefaultImageType is the kind of runtime installed by default. Servers
+	// only need a JRE.
+	DefaultImageType = "jre"
+	// DefaultDownloadTimeout bounds the download of a runtime archive.
+	DefaultDownloadTimeout = 10 * time.Minute
+)
+
+// Installer installs runtimes from an Adoptium-compatible API into a
+// managed runtimes directory.
+type Installer struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Dir        string // Runtimes directory; each runtime is a subdirectory
+	ImageType  string // jre or jdk
+	OS         string // Adoptium operating system name, e.g. linux or alpine-linux
+	Arch       string // Adoptium architecture name, e.g. x64 or aarch64
+	Vendor     string // Adoptium vendor (defaults to eclipse)
+}
+
+// NewInstaller creates an installer for the current platform installing
+// into dir.
+func NewInstaller(dir string) *Installer {
+	return &Installer{
+		BaseURL:    DefaultAdoptiumURL,
+		HTTPClient: &http.Client{Timeout: DefaultDownloadTimeout},
+		Dir:        dir,
+		ImageType:  DefaultImageType,
+		OS:         adoptiumOS(runtime.GOOS),
+		Arch:       adoptiumArch(runtime.GOARCH),
+		Vendor:     "eclipse",
+	}
+}
+
+// WithBaseURL sets the base URL of the Adoptium-compatible API.
+func (i *Installer) WithBaseURL(baseURL string) *Installer {
+	i.BaseURL = strings.TrimSuffix(baseURL, "/")
+	return i
+}
+
+// WithImageType sets the kind of runtime to install, jre or jdk.
+func (i *Installer) WithImageType(imageType string) *Installer {
+	i.ImageType = imageType
+	return i
+}
+
+// asset is a release asset of the Adoptium API.
+type asset struct {
+	Binary struct {
+		Package struct {
+			Checksum string `json:"checksum"`
+			Link     string `json:"link"`
+			Name     string `json:"name"`
+			Size     int64  `json:"size"`
+		} `json:"package"`
+	} `json:"binary"`
+	ReleaseName string `json:"release_name"`
+}
+
+// Install installs the latest runtime of a Java major version. A runtime
+// already installed from the same release is reused without downloading.
+// The archive is verified against its published SHA256 checksum and
+// unpacked next to its final location before being moved into place.
+func (i *Installer) Install(ctx context.Context, major int) (*Runtime, error) {
+	if major <= 0 {
+		return nil, errors.Newf("invalid Java version %d", major)
+	}
+
+	latest, err := i.latestAsset(ctx, major)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := latest.Binary.Package
+	if pkg.Link == "" || pkg.Checksum == "" {
+		return nil, errors.Newf("release %s has no downloadable package", latest.ReleaseName)
+	}
+
+	name := filepath.Base(filepath.Clean(latest.ReleaseName + "-" + i.ImageType))
+	if name == "." || strings.HasPrefix(name, ".") {
+		return nil, errors.Newf("invalid release name %q", latest.ReleaseName)
+	}
+	target := filepath.Join(i.Dir, name)
+
+	if rt, err := readInstalled(target); err == nil {
+		return rt, nil
+	}
+
+	if err := os.MkdirAll(i.Dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create runtimes directory")
+	}
+
+	archive, err := i.download(ctx, pkg.Link, pkg.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(archive) }()
+
+	staging, err := os.MkdirTemp(i.Dir, ".install-*")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create staging directory")
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	if strings.HasSuffix(pkg.Name, ".zip") {
+		err = unzip(archive, staging)
+	} else {
+		err = untar(archive, staging)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unpack %s", pkg.Name)
+	}
+
+	// Archives contain a single top-level directory.
+	root := staging
+	if entries, err := os.ReadDir(staging); err == nil && len(entries) == 1 && entries[0].IsDir() {
+		root = filepath.Join(staging, entries[0].Name())
+	}
+
+	if _, err := readInstalled(root); err != nil {
+		return nil, errors.Wrapf(err, "%s does not contain a Java runtime", pkg.Name)
+	}
+
+	if err := os.Rename(root, target); err != nil {
+		return nil, errors.Wrap(err, "failed to move runtime into place")
+	}
+
+	return readInstalled(target)
+}
+
+// latestAsset queries the latest release of a Java major version.
+func (i *Installer) latestAsset(ctx context.Context, major int) (*asset, error) {
+	query := url.Values{
+		"architecture": {i.Arch},
+		"image_type":   {i.ImageType},
+		"os":           {i.OS},
+		"vendor":       {i.Vendor},
+	}
+	endpoint := fmt.Sprintf("%s/v3/assets/latest/%d/hotspot?%s", strings.TrimSuffix(i.BaseURL, "/"), major, query.Encode())
+
+	resp, err := i.get(ctx, endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query Java %d releases", major)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var assets []asset
+	if err := json.NewDecoder(resp.Body).Decode(&assets); err != nil {
+		return nil, errors.Wrap(err, "failed to decode release list")
+	}
+
+	if len(assets) == 0 {
+		return nil, errors.Newf("no Java %d %s release for %s/%s", major, i.ImageType, i.OS, i.Arch)
+	}
+
+	return &assets[0], nil
+}
+
+// download saves an archive to a temporary file in the runtimes directory
+// and verifies its checksum.
+func (i *Installer) download(ctx context.Context, link, checksum string) (string, error) {
+	resp, err := i.get(ctx, link)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to download runtime")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	tmp, err := os.CreateTemp(i.Dir, ".download-*")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temporary file")
+	}
+
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hasher), resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", errors.Wrap(err, "failed to download runtime")
+	}
+
+	if sum := hex.EncodeToString(hasher.Sum(nil)); !strings.EqualFold(sum, checksum) {
+		_ = os.Remove(tmp.Name())
+		return "", errors.Newf("SHA256 mismatch: expected %s, got %s", checksum, sum)
+	}
+
+	return tmp.Name(), nil
+}
+
+// get performs a GET request and fails on statuses other than 200 OK.
+func (i *Installer) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := i.HTTPClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute request")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		_ = resp.Body.Close()
+		return nil, errors.Newf("%s returned status %d: %s", rawURL, resp.StatusCode, body)
+	}
+
+	return resp, nil
+}
+
+// readInstalled reads a runtime unpacked from an archive, whose home is
+// Contents/Home on macOS.
+func readInstalled(dir string) (*Runtime, error) {
+	if rt, err := ReadRuntime(dir); err == nil {
+		return rt, nil
+	}
+
+	return ReadRuntime(filepath.Join(dir, "Contents", "Home"))
+}
+
+// localName converts an archive entry name to a path relative to the
+// target directory, rejecting names that escape it.
+func localName(name string) (string, error) {
+	local := filepath.Clean(filepath.FromSlash(name))
+	if !filepath.IsLocal(local) {
+		return "", errors.Newf("archive entry %q escapes the target directory", name)
+	}
+
+	return local, nil
+}
+
+// untar unpacks a .tar.gz archive into dir. Entries are written through an
+// os.Root, so a path going through links unpacked earlier cannot leave dir.
+func untar(archive, dir string) error {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = root.Close() }()
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = gz.Close() }()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name, err := localName(header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := root.MkdirAll(name, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeEntry(root, name, tr, header.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// Links are resolved relative to their directory and must stay
+			// inside dir once the runtime is moved into place.
+			if filepath.IsAbs(header.Linkname) {
+				return errors.Newf("archive entry %q links outside the target directory", header.Name)
+			}
+			if _, err := localName(filepath.Join(filepath.Dir(name), header.Linkname)); err != nil {
+				return err
+			}
+			if err := root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+				return err
+			}
+			if err := root.Symlink(header.Linkname, name); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// unzip unpacks a .zip archive into dir.
+func unzip(archive, dir string) error {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = root.Close() }()
+
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = zr.Close() }()
+
+	for _, f := range zr.File {
+		name, err := localName(f.Name)
+		if err != nil {
+			return err
+		}
+
+		if f.FileInfo().IsDir() {
+			if err := root.MkdirAll(name, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeEntry(root, name, rc, f.Mode().Perm())
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeEntry writes an archive entry to name inside root.
+func writeEntry(root *os.Root, name string, r io.Reader, perm os.FileMode) error {
+	if err := root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	file, err := root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm|0o200)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// adoptiumOS maps a GOOS to the Adoptium operating system name.
+func adoptiumOS(goos string) string {
+	if goos == "darwin" {
+		return "mac"
+	}
+
+	return goos
+}
+
+// adoptiumArch maps a GOARCH to the Adoptium architecture name.
+func adoptiumArch(goarch string) string {
+	switch goarch {
+	case "amd64":
+		return "x64"
+	case "386":
+		return "x32"
+	case "arm64":
+		return "aarch64"
+	default:
+		return goarch
+	}
+}
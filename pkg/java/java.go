@@ -0,0 +1,224 @@
+// Package java discovers local Java runtimes and checks them against the
+// minimum Java version of a server version.
+//
+// Runtimes are identified by the release file at the root of every JDK and
+// JRE, so no Java process has to be started.
+package java
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ReleaseFile is the file describing a runtime, relative to its home.
+const ReleaseFile = "release"
+
+// DefaultDirs are the directories searched for runtimes installed by Linux
+// package managers and vendor installers.
+var DefaultDirs = []string{
+	"/usr/lib/jvm",
+	"/usr/lib64/jvm",
+	"/usr/java",
+	"/usr/local/java",
+	"/opt/java",
+	"/opt/jdk",
+}
+
+// Runtime is a local Java installation.
+type Runtime struct {
+	Home       string `json:"home"`
+	Executable string `json:"executable"`
+	Version    string `json:"version"` // JAVA_VERSION from the release file, e.g. 21.0.5
+	Major      int    `json:"major"`
+	Vendor     string `json:"vendor,omitempty"`
+	Source     string `json:"source"` // Where the runtime was found: PATH, JAVA_HOME or a directory
+}
+
+// Options control where Discover looks for runtimes.
+type Options struct {
+	Path     string   // Search path for the java executable, like $PATH
+	JavaHome string   // Runtime home, like $JAVA_HOME
+	Dirs     []string // Directories whose subdirectories are runtime homes
+}
+
+// DefaultOptions searches $PATH, $JAVA_HOME and DefaultDirs.
+func DefaultOptions() Options {
+	return Options{
+		Path:     os.Getenv("PATH"),
+		JavaHome: os.Getenv("JAVA_HOME"),
+		Dirs:     DefaultDirs,
+	}
+}
+
+// Discover finds the Java runtimes described by opts. Each runtime is
+// reported once, under the first source it was found in; the runtime on
+// the search path comes first, followed by JAVA_HOME and the directories.
+func Discover(opts Options) []Runtime {
+	var (
+		runtimes []Runtime
+		seen     = make(map[string]bool)
+	)
+
+	add := func(home, source string) {
+		rt, err := ReadRuntime(home)
+		if err != nil {
+			return
+		}
+
+		key := rt.Home
+		if resolved, err := filepath.EvalSymlinks(rt.Home); err == nil {
+			key = resolved
+		}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		rt.Source = source
+		runtimes = append(runtimes, *rt)
+	}
+
+	if exe := lookPath(opts.Path); exe != "" {
+		add(filepath.Dir(filepath.Dir(exe)), "PATH")
+	}
+
+	if opts.JavaHome != "" {
+		add(opts.JavaHome, "JAVA_HOME")
+	}
+
+	for _, dir := range opts.Dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			home := filepath.Join(dir, entry.Name())
+			add(home, dir)
+			// macOS-style bundles keep the home in Contents/Home.
+			add(filepath.Join(home, "Contents", "Home"), dir)
+		}
+	}
+
+	return runtimes
+}
+
+// ReadRuntime reads the release file of a runtime home.
+func ReadRuntime(home string) (*Runtime, error) {
+	release, err := readRelease(filepath.Join(home, ReleaseFile))
+	if err != nil {
+		return nil, err
+	}
+
+	version := release["JAVA_VERSION"]
+	major := MajorVersion(version)
+	if major == 0 {
+		return nil, errors.Newf("%s has no valid JAVA_VERSION", filepath.Join(home, ReleaseFile))
+	}
+
+	exe := filepath.Join(home, "bin", executableName())
+	if _, err := os.Stat(exe); err != nil {
+		return nil, errors.Wrapf(err, "no java executable in %s", home)
+	}
+
+	return &Runtime{
+		Home:       home,
+		Executable: exe,
+		Version:    version,
+		Major:      major,
+		Vendor:     release["IMPLEMENTOR"],
+	}, nil
+}
+
+// readRelease parses the KEY="value" lines of a release file.
+func readRelease(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open release file")
+	}
+	defer func() { _ = file.Close() }()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+
+	return values, errors.Wrap(scanner.Err(), "failed to read release file")
+}
+
+// MajorVersion returns the major version of a Java version string, e.g. 21
+// for 21.0.5 and 8 for 1.8.0_432. It returns 0 for invalid versions.
+func MajorVersion(version string) int {
+	version = strings.TrimPrefix(version, "1.")
+
+	end := strings.IndexFunc(version, func(r rune) bool { return r < '0' || r > '9' })
+	if end >= 0 {
+		version = version[:end]
+	}
+
+	major, err := strconv.Atoi(version)
+	if err != nil {
+		return 0
+	}
+
+	return major
+}
+
+// Compatible returns the runtimes whose major version is at least minimum,
+// newest first. A minimum of 0 accepts every runtime.
+func Compatible(runtimes []Runtime, minimum int) []Runtime {
+	var usable []Runtime
+	for _, rt := range runtimes {
+		if rt.Major >= minimum {
+			usable = append(usable, rt)
+		}
+	}
+
+	slices.SortStableFunc(usable, func(a, b Runtime) int { return b.Major - a.Major })
+
+	return usable
+}
+
+// lookPath finds the java executable in a search path, resolving symlinks
+// such as /usr/bin/java -> /etc/alternatives/java -> the runtime.
+func lookPath(path string) string {
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+
+		exe := filepath.Join(dir, executableName())
+		info, err := os.Stat(exe)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			return resolved
+		}
+		return exe
+	}
+
+	return ""
+}
+
+// executableName is the file name of the java executable.
+func executableName() string {
+	if runtime.GOOS == "windows" {
+		return "java.exe"
+	}
+
+	return "java"
+}
@@ -0,0 +1,345 @@
+package java
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+// fakeRuntime creates a runtime home with a release file and a java
+// executable.
+func fakeRuntime(t *testing.T, home, version, vendor string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Join(home, "bin"), 0o755); err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+
+	release := "IMPLEMENTOR=\"" + vendor + "\"\nJAVA_VERSION=\"" + version + "\"\nOS_NAME=\"Linux\"\n"
+	if err := os.WriteFile(filepath.Join(home, ReleaseFile), []byte(release), 0o644); err != nil {
+		t.Fatalf("Failed to write release file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, "bin", executableName()), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write java executable: %v", err)
+	}
+}
+
+func TestMajorVersion(t *testing.T) {
+	for version, want := range map[string]int{
+		"21.0.5":    21,
+		"17":        17,
+		"1.8.0_432": 8,
+		"25-ea":     25,
+		"":          0,
+		"jdk":       0,
+	} {
+		if got := MajorVersion(version); got != want {
+			t.Errorf("MajorVersion(%q) = %d, want %d", version, got, want)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinked runtimes are not supported on Windows")
+	}
+
+	root := t.TempDir()
+	jvmDir := filepath.Join(root, "jvm")
+	fakeRuntime(t, filepath.Join(jvmDir, "java-17-openjdk"), "17.0.13", "Eclipse Adoptium")
+	fakeRuntime(t, filepath.Join(jvmDir, "java-21-openjdk"), "21.0.5", "Eclipse Adoptium")
+	fakeRuntime(t, filepath.Join(root, "custom"), "1.8.0_432", "Oracle Corporation")
+
+	// Not a runtime: no release file
+	if err := os.MkdirAll(filepath.Join(jvmDir, "default"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// PATH points at Java 21 through a symlink, like /usr/bin/java
+	binDir := filepath.Join(root, "bin")
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(jvmDir, "java-21-openjdk", "bin", "java"), filepath.Join(binDir, "java")); err != nil {
+		t.Fatal(err)
+	}
+
+	runtimes := Discover(Options{
+		Path:     filepath.Join(root, "missing") + string(os.PathListSeparator) + binDir,
+		JavaHome: filepath.Join(root, "custom"),
+		Dirs:     []string{jvmDir, filepath.Join(root, "missing")},
+	})
+
+	want := []struct {
+		major  int
+		source string
+	}{
+		{21, "PATH"},
+		{8, "JAVA_HOME"},
+		{17, jvmDir},
+	}
+	if len(runtimes) != len(want) {
+		t.Fatalf("Expected %d runtimes, got %+v", len(want), runtimes)
+	}
+	for i, w := range want {
+		if runtimes[i].Major != w.major || runtimes[i].Source != w.source {
+			t.Errorf("Runtime %d: expected Java %d from %s, got %+v", i, w.major, w.source, runtimes[i])
+		}
+	}
+	if runtimes[0].Vendor != "Eclipse Adoptium" || runtimes[0].Version != "21.0.5" {
+		t.Errorf("Expected release file details, got %+v", runtimes[0])
+	}
+
+	usable := Compatible(runtimes, 17)
+	if len(usable) != 2 || usable[0].Major != 21 || usable[1].Major != 17 {
+		t.Errorf("Expected Java 21 and 17 to be usable for 17, got %+v", usable)
+	}
+	if usable := Compatible(runtimes, 25); len(usable) != 0 {
+		t.Errorf("Expected no runtime to be usable for 25, got %+v", usable)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/projects/paper/versions/1.20.6" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version":{"id":"1.20.6","java":{"version":{"minimum":21}}},"builds":[151]}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	fakeRuntime(t, filepath.Join(dir, "jdk-17"), "17.0.13", "Eclipse Adoptium")
+
+	client := api.NewClient().WithBaseURL(server.URL)
+	report, err := Check(context.Background(), client, "paper", "1.20.6", Options{Dirs: []string{dir}})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if report.Minimum != 21 || len(report.Runtimes) != 1 || len(report.Usable) != 0 {
+		t.Errorf("Expected Java 17 to be too old for 1.20.6, got %+v", report)
+	}
+}
+
+// stubAPI answers GetVersion with a fixed Java minimum; other calls panic.
+type stubAPI struct {
+	api.API
+
+	minimum int
+}
+
+func (s stubAPI) GetVersion(_ context.Context, _, version string) (*api.VersionV3Response, error) {
+	info := &api.VersionV3Response{}
+	info.Version.ID = version
+	info.Version.Java.Version.Minimum = s.minimum
+
+	return info, nil
+}
+
+func TestCheck_Stub(t *testing.T) {
+	dir := t.TempDir()
+	fakeRuntime(t, filepath.Join(dir, "jdk-21"), "21.0.5", "Eclipse Adoptium")
+
+	report, err := Check(context.Background(), stubAPI{minimum: 21}, "paper", "1.21.11", Options{Dirs: []string{dir}})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if report.Minimum != 21 || len(report.Usable) != 1 {
+		t.Errorf("Expected Java 21 to be usable for 1.21.11, got %+v", report)
+	}
+}
+
+// adoptiumServer serves a Java 21 JRE archive like the Adoptium API. Entries
+// whose content starts with "-> " are symlinks to the rest of it.
+func adoptiumServer(t *testing.T, entries map[string]string, checksum string) (*httptest.Server, map[string]int) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range slices.Sorted(maps.Keys(entries)) {
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(entries[name])), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(name, "/bin/java") {
+			header.Mode = 0o755
+		}
+		if target, ok := strings.CutPrefix(entries[name], "-> "); ok {
+			header = &tar.Header{Name: name, Mode: 0o777, Linkname: target, Typeflag: tar.TypeSymlink}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeSymlink {
+			continue
+		}
+		if _, err := tw.Write([]byte(entries[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+
+	if checksum == "" {
+		sum := sha256.Sum256(archive)
+		checksum = hex.EncodeToString(sum[:])
+	}
+
+	requests := make(map[string]int)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+
+		switch r.URL.Path {
+		case "/v3/assets/latest/21/hotspot":
+			if r.URL.Query().Get("image_type") != "jre" || r.URL.Query().Get("os") == "" {
+				t.Errorf("Unexpected query %s", r.URL.RawQuery)
+			}
+			_ = json.NewEncoder(w).Encode([]map[string]any{{
+				"release_name": "jdk-21.0.5+11",
+				"binary": map[string]any{
+					"package": map[string]any{
+						"name":     "OpenJDK21U-jre_x64_linux_hotspot_21.0.5_11.tar.gz",
+						"link":     server.URL + "/download/jre.tar.gz",
+						"checksum": checksum,
+						"size":     len(archive),
+					},
+				},
+			}})
+		case "/download/jre.tar.gz":
+			_, _ = w.Write(archive)
+		default:
+			_, _ = w.Write([]byte("[]"))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, requests
+}
+
+var jreEntries = map[string]string{
+	"jdk-21.0.5+11-jre/release":     "IMPLEMENTOR=\"Eclipse Adoptium\"\nJAVA_VERSION=\"21.0.5\"\n",
+	"jdk-21.0.5+11-jre/bin/java":    "#!/bin/sh\n",
+	"jdk-21.0.5+11-jre/lib/modules": "modules",
+}
+
+func TestInstaller(t *testing.T) {
+	server, requests := adoptiumServer(t, jreEntries, "")
+
+	dir := t.TempDir()
+	installer := NewInstaller(dir).WithBaseURL(server.URL)
+
+	rt, err := installer.Install(context.Background(), 21)
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	if rt.Major != 21 || rt.Home != filepath.Join(dir, "jdk-21.0.5+11-jre") {
+		t.Errorf("Expected Java 21 in the runtimes directory, got %+v", rt)
+	}
+	if info, err := os.Stat(rt.Executable); err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("Expected an executable java, got %v (%v)", info, err)
+	}
+
+	// Installing again reuses the runtime
+	if _, err := installer.Install(context.Background(), 21); err != nil {
+		t.Fatalf("Second Install failed: %v", err)
+	}
+	if requests["/download/jre.tar.gz"] != 1 {
+		t.Errorf("Expected the archive to be downloaded once, got %d", requests["/download/jre.tar.gz"])
+	}
+
+	// Installed runtimes are discovered in the runtimes directory
+	if runtimes := Discover(Options{Dirs: []string{dir}}); len(runtimes) != 1 || runtimes[0].Major != 21 {
+		t.Errorf("Expected the installed runtime to be discovered, got %+v", runtimes)
+	}
+
+	if _, err := installer.Install(context.Background(), 17); err == nil {
+		t.Error("Expected an error for a version without releases")
+	}
+}
+
+func TestInstaller_Rejects(t *testing.T) {
+	for name, entries := range map[string]map[string]string{
+		"checksum":  jreEntries,
+		"traversal": {"../escape/release": "JAVA_VERSION=\"21\"\n"},
+		"chained links": {
+			"x":      "-> .",
+			"y":      "-> x/..",
+			"y/evil": "evil",
+		},
+		"no java": {"jdk-21.0.5+11-jre/README": "not a runtime"},
+	} {
+		checksum := ""
+		if name == "checksum" {
+			checksum = strings.Repeat("0", 64)
+		}
+		server, _ := adoptiumServer(t, entries, checksum)
+
+		dir := filepath.Join(t.TempDir(), "runtimes")
+		if _, err := NewInstaller(dir).WithBaseURL(server.URL).Install(context.Background(), 21); err == nil {
+			t.Errorf("%s: expected Install to fail", name)
+		}
+
+		if left, _ := os.ReadDir(dir); len(left) != 0 {
+			t.Errorf("%s: expected nothing to be left in the runtimes directory, got %v", name, left)
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape")); err == nil {
+			t.Errorf("%s: archive escaped the runtimes directory", name)
+		}
+	}
+}
+
+func TestPin(t *testing.T) {
+	dir := t.TempDir()
+	home := filepath.Join(t.TempDir(), "jdk-21")
+	fakeRuntime(t, home, "21.0.5", "Eclipse Adoptium")
+
+	if _, err := Pinned(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no pinned runtime, got %v", err)
+	}
+
+	rt, err := ReadRuntime(home)
+	if err != nil {
+		t.Fatalf("ReadRuntime failed: %v", err)
+	}
+	if err := Pin(dir, rt); err != nil {
+		t.Fatalf("Pin failed: %v", err)
+	}
+
+	pinned, err := Pinned(dir)
+	if err != nil {
+		t.Fatalf("Pinned failed: %v", err)
+	}
+	if pinned.Home != home || pinned.Major != 21 {
+		t.Errorf("Expected the pinned runtime, got %+v", pinned)
+	}
+
+	if err := os.RemoveAll(home); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Pinned(dir); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a removed runtime to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package java
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/internal/atomicfile"
+)
+
+// PinFile is the file, relative to a server directory, recording the
+// runtime the server runs on.
+const PinFile = ".papermc/java.json"
+
+// Pin records the runtime a server directory runs on.
+func Pin(dir string, rt *Runtime) error {
+	data, err := json.MarshalIndent(rt, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode runtime")
+	}
+
+	return atomicfile.WriteFile(filepath.Join(dir, PinFile), append(data, '\n'), 0o644)
+}
+
+// Pinned returns the runtime recorded for a server directory, re-reading
+// its release file so a runtime that was removed or replaced is noticed.
+// The returned error matches os.ErrNotExist if no runtime is recorded.
+func Pinned(dir string) (*Runtime, error) {
+	data, err := os.ReadFile(filepath.Join(dir, PinFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read runtime file")
+	}
+
+	var pinned Runtime
+	if err := json.Unmarshal(data, &pinned); err != nil {
+		return nil, errors.Wrap(err, "failed to decode runtime file")
+	}
+
+	rt, err := ReadRuntime(pinned.Home)
+	if err != nil {
+		// Not wrapped: a removed runtime must not look like a missing pin.
+		return nil, errors.Newf("pinned runtime %s is not usable: %s", pinned.Home, err)
+	}
+	rt.Source = pinned.Source
+
+	return rt, nil
+}
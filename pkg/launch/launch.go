@@ -0,0 +1,282 @@
+// Package launch generates the scripts that start a server: a POSIX start.sh
+// running the jar with the JVM flags recommended by the API, and a systemd
+// unit running that script.
+//
+// The start script checks the Java version before launching and can size
+// the heap from the cgroup memory limit of the container or service it runs
+// in.
+package launch
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+const (
+	// ScriptFile is the name of the generated start script.
+	ScriptFile = "start.sh"
+	// DefaultHeapPercent is the share of the memory limit used as heap
+	// when the heap is sized automatically, leaving room for off-heap memory.
+	DefaultHeapPercent = 75
+)
+
+// Options describe how a server is started.
+type Options struct {
+	Project     string   // Project of the installed jar, used in messages
+	Version     string   // Version of the installed jar, used in messages
+	Jar         string   // Jar path relative to the server directory (defaults to server.jar)
+	Java        string   // Java executable (defaults to $JAVA_HOME/bin/java or java)
+	MinJava     int      // Minimum Java major version (0 disables the check)
+	Flags       []string // JVM flags, e.g. the API's recommended flags
+	Heap        string   // Fixed heap size such as 4G (empty means sized at launch)
+	HeapPercent int      // Share of the memory limit used as heap (defaults to DefaultHeapPercent)
+	Args        []string // Arguments passed to the server, e.g. nogui
+}
+
+// heapFlag matches heap size flags, which the script sets itself.
+var heapFlag = regexp.MustCompile(`^-Xm[sx]`)
+
+// ForVersion returns the options for running a version of a project with the
+// minimum Java version and the flags recommended by the API. Heap size flags
+// are dropped, as the script sets the heap itself.
+func ForVersion(project string, meta api.VersionMeta) Options {
+	opts := Options{
+		Project: project,
+		Version: meta.ID,
+		MinJava: meta.Java.Version.Minimum,
+	}
+
+	for _, flag := range meta.Java.Flags.Recommended {
+		if !heapFlag.MatchString(flag) {
+			opts.Flags = append(opts.Flags, flag)
+		}
+	}
+
+	// Proxies have no console GUI to disable.
+	if project != "velocity" {
+		opts.Args = []string{"--nogui"}
+	}
+
+	return opts
+}
+
+// Script renders the start script.
+func Script(opts Options) ([]byte, error) {
+	if opts.Jar == "" {
+		opts.Jar = "server.jar"
+	}
+	if opts.HeapPercent <= 0 {
+		opts.HeapPercent = DefaultHeapPercent
+	}
+	if opts.HeapPercent > 100 {
+		return nil, errors.Newf("heap percentage %d exceeds 100", opts.HeapPercent)
+	}
+
+	var buf bytes.Buffer
+	if err := scriptTemplate.Execute(&buf, opts); err != nil {
+		return nil, errors.Wrap(err, "failed to render start script")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// CommandLine returns the Java executable and arguments starting the server
+// directly rather than through the start script. The heap is only set when
+// Heap is, and the Java version is not checked.
+func CommandLine(opts Options) (string, []string) {
+	java := opts.Java
+	if java == "" {
+		java = "java"
+		if home := os.Getenv("JAVA_HOME"); home != "" {
+			java = filepath.Join(home, "bin", "java")
+		}
+	}
+	if opts.Jar == "" {
+		opts.Jar = "server.jar"
+	}
+
+	var args []string
+	if opts.Heap != "" {
+		args = append(args, "-Xms"+opts.Heap, "-Xmx"+opts.Heap)
+	}
+	args = append(args, opts.Flags...)
+	args = append(args, "-jar", opts.Jar)
+	args = append(args, opts.Args...)
+
+	return java, args
+}
+
+// Unit describes a systemd service running a start script.
+type Unit struct {
+	Description string // Defaults to "Minecraft server in DIR"
+	Dir         string // Absolute server directory
+	User        string // User the server runs as (empty means root or the user manager's user)
+	StopTimeout int    // Seconds to wait for the server to save and stop (defaults to 120)
+}
+
+// SystemdUnit renders a systemd service running the start script of a
+// server directory. The script replaces itself with Java, so systemd
+// signals the JVM directly and Paper saves the worlds on SIGTERM.
+func SystemdUnit(unit Unit) ([]byte, error) {
+	if !filepath.IsAbs(unit.Dir) {
+		return nil, errors.Newf("server directory %q is not absolute", unit.Dir)
+	}
+	if unit.Description == "" {
+		unit.Description = "Minecraft server in " + unit.Dir
+	}
+	if unit.StopTimeout <= 0 {
+		unit.StopTimeout = 120
+	}
+
+	var buf bytes.Buffer
+	if err := unitTemplate.Execute(&buf, unit); err != nil {
+		return nil, errors.Wrap(err, "failed to render systemd unit")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// shellQuote quotes s for a POSIX shell if it contains special characters.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			strings.ContainsRune("-_=+:,./@%", r))
+	}) < 0 {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var funcs = template.FuncMap{"quote": shellQuote}
+
+var scriptTemplate = template.Must(template.New("start.sh").Funcs(funcs).Parse(`#!/bin/sh
+# Start script generated by papermc{{if .Project}} for {{.Project}} {{.Version}}{{end}}.
+#
+# Environment overrides:
+#   JAVA          Java executable
+#   HEAP          Heap size, e.g. 4G (default: {{if .Heap}}{{.Heap}}{{else}}{{.HeapPercent}}% of the memory limit{{end}})
+#   HEAP_PERCENT  Share of the memory limit used as heap when HEAP is unset
+set -eu
+
+cd "$(dirname "$0")"
+
+{{if .Java}}JAVA="${JAVA:-{{quote .Java}}}"
+{{else}}if [ -z "${JAVA:-}" ]; then
+	if [ -n "${JAVA_HOME:-}" ]; then
+		JAVA="$JAVA_HOME/bin/java"
+	else
+		JAVA=java
+	fi
+fi
+{{end}}MIN_JAVA={{.MinJava}}
+HEAP="${HEAP:-{{.Heap}}}"
+HEAP_PERCENT="${HEAP_PERCENT:-{{.HeapPercent}}}"
+
+# java_major prints the major version of $JAVA, e.g. 21 (or 8 for 1.8).
+java_major() {
+	version=$("$JAVA" -XshowSettings:properties -version 2>&1 |
+		sed -n 's/^ *java\.specification\.version = *//p')
+	version=${version#1.}
+	echo "${version%%.*}"
+}
+
+# cgroup_limit lowers limit to the numeric value of file $3 in the cgroup
+# $2 under the hierarchy mounted at $1 and in each of its ancestors.
+cgroup_limit() {
+	dir=$2
+	while :; do
+		value=$(cat "$1$dir/$3" 2>/dev/null || true)
+		case "$value" in
+		'' | *[!0-9]*) ;;
+		*)
+			if [ -z "$limit" ] || [ "$value" -lt "$limit" ]; then
+				limit=$value
+			fi
+			;;
+		esac
+		if [ -z "$dir" ] || [ "$dir" = / ]; then
+			break
+		fi
+		dir=${dir%/*}
+	done
+}
+
+# memory_limit_mb prints the memory limit of the cgroup the script runs in,
+# in MiB, or the total memory of the host if there is no limit. Under
+# systemd the limit is set on the service's cgroup rather than on the root
+# of the hierarchy, so the cgroup is looked up in /proc/self/cgroup.
+memory_limit_mb() {
+	total=$(awk '/^MemTotal:/ { print int($2 / 1024) }' /proc/meminfo 2>/dev/null || true)
+	limit=
+	if [ -r /sys/fs/cgroup/cgroup.controllers ]; then
+		# cgroup v2: "0::/system.slice/paper.service"
+		cgroup_limit /sys/fs/cgroup "$(awk -F: '$1 == "0" { print $3 }' /proc/self/cgroup 2>/dev/null || true)" memory.max
+	elif [ -d /sys/fs/cgroup/memory ]; then
+		# cgroup v1: "4:memory:/system.slice/paper.service"
+		cgroup_limit /sys/fs/cgroup/memory "$(awk -F: '$2 ~ /(^|,)memory(,|$)/ { print $3 }' /proc/self/cgroup 2>/dev/null || true)" memory.limit_in_bytes
+	fi
+	if [ -n "$limit" ]; then
+		limit=$((limit / 1048576))
+		# Without a limit, cgroup v1 reports a value larger than the host memory.
+		if [ -z "$total" ] || [ "$limit" -lt "$total" ]; then
+			echo "$limit"
+			return
+		fi
+	fi
+	echo "$total"
+}
+
+if [ "$MIN_JAVA" -gt 0 ]; then
+	major=$(java_major || true)
+	if [ -z "$major" ]; then
+		echo "Cannot determine the version of $JAVA; Java $MIN_JAVA or newer is required" >&2
+		exit 1
+	fi
+	if [ "$major" -lt "$MIN_JAVA" ]; then
+		echo "$JAVA is Java $major, but{{if .Project}} {{.Project}} {{.Version}}{{else}} this server{{end}} requires Java $MIN_JAVA or newer" >&2
+		exit 1
+	fi
+fi
+
+if [ -z "$HEAP" ]; then
+	memory=$(memory_limit_mb)
+	if [ -z "$memory" ]; then
+		echo "Cannot determine the memory limit; set HEAP, e.g. HEAP=4G" >&2
+		exit 1
+	fi
+	HEAP="$((memory * HEAP_PERCENT / 100))M"
+fi
+
+exec "$JAVA" -Xms"$HEAP" -Xmx"$HEAP"{{range .Flags}} \
+	{{quote .}}{{end}} \
+	-jar {{quote .Jar}}{{range .Args}} {{quote .}}{{end}} "$@"
+`))
+
+var unitTemplate = template.Must(template.New("unit").Funcs(funcs).Parse(`[Unit]
+Description={{.Description}}
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+{{if .User}}User={{.User}}
+{{end}}WorkingDirectory={{.Dir}}
+ExecStart={{.Dir}}/start.sh
+# Paper saves the worlds and exits on SIGTERM, which the JVM reports as 143.
+KillSignal=SIGTERM
+SuccessExitStatus=143
+TimeoutStopSec={{.StopTimeout}}
+Restart=on-failure
+RestartSec=10
+
+[Install]
+WantedBy=multi-user.target
+`))
@@ -0,0 +1,272 @@
+package launch
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/goPaperMC/internal/atomicfile"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+// fakeJava writes a java executable reporting the given specification
+// version and printing its arguments one per line.
+func fakeJava(t *testing.T, specVersion string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "java")
+	script := `#!/bin/sh
+case "$1" in
+-XshowSettings:properties)
+	echo "Property settings:" >&2
+	echo "    java.specification.version = ` + specVersion + `" >&2
+	echo "openjdk version" >&2
+	exit 0
+	;;
+esac
+for arg in "$@"; do
+	echo "$arg"
+done
+`
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write fake java: %v", err)
+	}
+
+	return path
+}
+
+// runScript generates a start script into a new directory and runs it.
+func runScript(t *testing.T, opts Options, env ...string) (string, error) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("start scripts require a POSIX shell")
+	}
+
+	data, err := Script(opts)
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, ScriptFile)
+	if err := atomicfile.WriteFile(path, data, 0o755); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cmd := exec.Command(path, "--port", "25566")
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+
+	return string(out), err
+}
+
+func TestForVersion(t *testing.T) {
+	meta := api.VersionMeta{
+		ID: "1.21.11",
+		Java: api.JavaInfo{
+			Version: api.JavaVersion{Minimum: 21},
+			Flags:   api.JavaFlags{Recommended: []string{"-Xms4G", "-XX:+UseG1GC", "-Xmx4G", "-Dusing.aikars.flags=https://mcflags.emc.gs"}},
+		},
+	}
+
+	opts := ForVersion("paper", meta)
+	if opts.MinJava != 21 || opts.Version != "1.21.11" {
+		t.Errorf("Expected Java 21 for 1.21.11, got %+v", opts)
+	}
+	if strings.Join(opts.Flags, " ") != "-XX:+UseG1GC -Dusing.aikars.flags=https://mcflags.emc.gs" {
+		t.Errorf("Expected heap flags to be dropped, got %v", opts.Flags)
+	}
+	if strings.Join(opts.Args, " ") != "--nogui" {
+		t.Errorf("Expected --nogui for paper, got %v", opts.Args)
+	}
+
+	if opts := ForVersion("velocity", meta); len(opts.Args) != 0 {
+		t.Errorf("Expected no arguments for velocity, got %v", opts.Args)
+	}
+}
+
+func TestScript(t *testing.T) {
+	java := fakeJava(t, "21")
+
+	out, err := runScript(t, Options{
+		Project: "paper",
+		Version: "1.21.11",
+		Java:    java,
+		MinJava: 21,
+		Flags:   []string{"-XX:+UseG1GC", "-Dmessage=it's fine"},
+		Heap:    "2G",
+		Args:    []string{"--nogui"},
+	})
+	if err != nil {
+		t.Fatalf("Script failed: %v\n%s", err, out)
+	}
+
+	want := "-Xms2G\n-Xmx2G\n-XX:+UseG1GC\n-Dmessage=it's fine\n-jar\nserver.jar\n--nogui\n--port\n25566\n"
+	if out != want {
+		t.Errorf("Expected java to be run with\n%s\ngot\n%s", want, out)
+	}
+}
+
+func TestScript_JavaGuard(t *testing.T) {
+	for _, spec := range []string{"17", "1.8"} {
+		out, err := runScript(t, Options{Project: "paper", Version: "1.21.11", Java: fakeJava(t, spec), MinJava: 21, Heap: "1G"})
+		if err == nil {
+			t.Fatalf("Expected Java %s to be rejected, got\n%s", spec, out)
+		}
+
+		major := strings.TrimPrefix(spec, "1.")
+		if !strings.Contains(out, "is Java "+major+", but paper 1.21.11 requires Java 21 or newer") {
+			t.Errorf("Expected a Java version error, got %q", out)
+		}
+	}
+
+	// JAVA from the environment overrides the generated default
+	out, err := runScript(t, Options{Java: fakeJava(t, "17"), MinJava: 21, Heap: "1G"}, "JAVA="+fakeJava(t, "25"))
+	if err != nil {
+		t.Fatalf("Expected JAVA to override the executable: %v\n%s", err, out)
+	}
+}
+
+func TestScript_AutomaticHeap(t *testing.T) {
+	if _, err := os.Stat("/proc/meminfo"); err != nil {
+		t.Skip("no /proc/meminfo to size the heap from")
+	}
+
+	out, err := runScript(t, Options{Java: fakeJava(t, "21"), HeapPercent: 50})
+	if err != nil {
+		t.Fatalf("Script failed: %v\n%s", err, out)
+	}
+
+	lines := strings.Split(out, "\n")
+	if !strings.HasPrefix(lines[0], "-Xms") || !strings.HasSuffix(lines[0], "M") || lines[1] != "-Xmx"+strings.TrimPrefix(lines[0], "-Xms") {
+		t.Errorf("Expected an automatic heap size in MiB, got %q", lines[:2])
+	}
+
+	out, err = runScript(t, Options{Java: fakeJava(t, "21")}, "HEAP=3G")
+	if err != nil || !strings.HasPrefix(out, "-Xms3G\n-Xmx3G\n") {
+		t.Errorf("Expected HEAP to override the heap size, got %q (%v)", out, err)
+	}
+
+	if _, err := Script(Options{HeapPercent: 150}); err == nil {
+		t.Error("Expected an error for a heap percentage above 100")
+	}
+}
+
+func TestScript_MemoryLimit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("start scripts require a POSIX shell")
+	}
+
+	data, err := Script(Options{})
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+	script := string(data)
+	funcs := script[strings.Index(script, "cgroup_limit() {"):strings.Index(script, "if [ \"$MIN_JAVA\"")]
+
+	const (
+		gib   = "1073741824"
+		host  = "MemTotal:       16777216 kB\n"
+		v1Max = "9223372036854771712"
+	)
+	tests := []struct {
+		name   string
+		cgroup string
+		files  map[string]string
+		want   string
+	}{
+		{"v2 service", "0::/system.slice/paper.service\n", map[string]string{
+			"cgroup.controllers":                    "memory",
+			"memory.max":                            "max",
+			"system.slice/memory.max":               "max",
+			"system.slice/paper.service/memory.max": "2147483648",
+			"system.slice/other.service/memory.max": gib,
+		}, "2048"},
+		{"v2 slice", "0::/system.slice/paper.service\n", map[string]string{
+			"cgroup.controllers":                    "memory",
+			"system.slice/memory.max":               gib,
+			"system.slice/paper.service/memory.max": "max",
+		}, "1024"},
+		{"v2 namespace", "0::/\n", map[string]string{
+			"cgroup.controllers": "memory",
+			"memory.max":         gib,
+		}, "1024"},
+		{"v1 service", "12:memory:/system.slice/paper.service\n3:cpu,cpuacct:/\n0::/\n", map[string]string{
+			"memory/memory.limit_in_bytes":                            v1Max,
+			"memory/system.slice/paper.service/memory.limit_in_bytes": "3221225472",
+		}, "3072"},
+		{"v1 unlimited", "12:memory:/\n", map[string]string{
+			"memory/memory.limit_in_bytes": v1Max,
+		}, "16384"},
+		{"no cgroup", "", nil, "16384"},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		root := filepath.Join(dir, "cgroup")
+		for name, content := range tt.files {
+			path := filepath.Join(root, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for name, content := range map[string]string{"self-cgroup": tt.cgroup, "meminfo": host} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		fn := strings.NewReplacer(
+			"/sys/fs/cgroup", root,
+			"/proc/self/cgroup", filepath.Join(dir, "self-cgroup"),
+			"/proc/meminfo", filepath.Join(dir, "meminfo"),
+		).Replace(funcs)
+
+		out, err := exec.Command("sh", "-c", fn+"memory_limit_mb").CombinedOutput()
+		if err != nil || strings.TrimSpace(string(out)) != tt.want {
+			t.Errorf("%s: expected %s MiB, got %q (%v)", tt.name, tt.want, out, err)
+		}
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	java, args := CommandLine(Options{Java: "/opt/java/bin/java", Flags: []string{"-XX:+UseG1GC"}, Heap: "2G", Args: []string{"--nogui"}})
+	if java != "/opt/java/bin/java" || strings.Join(args, " ") != "-Xms2G -Xmx2G -XX:+UseG1GC -jar server.jar --nogui" {
+		t.Errorf("Unexpected command line %s %v", java, args)
+	}
+
+	t.Setenv("JAVA_HOME", "/usr/lib/jvm/temurin-21")
+	if java, args := CommandLine(Options{Jar: "velocity.jar"}); java != filepath.Join("/usr/lib/jvm/temurin-21", "bin", "java") || strings.Join(args, " ") != "-jar velocity.jar" {
+		t.Errorf("Expected JAVA_HOME and no heap flags, got %s %v", java, args)
+	}
+}
+
+func TestSystemdUnit(t *testing.T) {
+	unit, err := SystemdUnit(Unit{Dir: "/srv/lobby", User: "minecraft"})
+	if err != nil {
+		t.Fatalf("SystemdUnit failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"WorkingDirectory=/srv/lobby\n",
+		"ExecStart=/srv/lobby/start.sh\n",
+		"User=minecraft\n",
+		"SuccessExitStatus=143\n",
+		"TimeoutStopSec=120\n",
+	} {
+		if !strings.Contains(string(unit), want) {
+			t.Errorf("Expected unit to contain %q, got\n%s", want, unit)
+		}
+	}
+
+	if _, err := SystemdUnit(Unit{Dir: "srv/lobby"}); err == nil {
+		t.Error("Expected an error for a relative directory")
+	}
+}
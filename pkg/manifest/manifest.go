@@ -0,0 +1,126 @@
+// Package manifest implements declarative management of several server
+// directories from a single YAML file.
+//
+// A manifest lists server directories together with the project, version
+// constraint, channel and artifact each of them should run. Compute turns a
+// manifest into a Plan describing which files are missing or out of date,
+// and Apply executes that plan with the minimal number of downloads.
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"go.yaml.in/yaml/v3"
+)
+
+// DefaultFile is the file name used for a server jar when none is given.
+const DefaultFile = "server.jar"
+
+// Manifest describes a set of server directories managed together.
+type Manifest struct {
+	Servers []Server `yaml:"servers"`
+
+	// baseDir is the directory relative server directories are resolved against.
+	baseDir string
+}
+
+// Server describes the desired state of a single server directory.
+type Server struct {
+	Name     string `yaml:"name"`     // Display name (defaults to Dir)
+	Dir      string `yaml:"dir"`      // Server directory, relative to the manifest
+	Project  string `yaml:"project"`  // Project ID, e.g. paper, velocity, folia
+	Version  string `yaml:"version"`  // Version constraint, see api.MatchVersion
+	Channel  string `yaml:"channel"`  // Build channel (empty means any channel)
+	Artifact string `yaml:"artifact"` // Download key (defaults to server:default)
+	File     string `yaml:"file"`     // Target file name (defaults to server.jar)
+}
+
+// Load reads and validates a manifest file. Relative server directories are
+// resolved against the directory containing the manifest.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read manifest")
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve manifest path")
+	}
+
+	return Parse(data, filepath.Dir(absPath))
+}
+
+// Parse parses and validates manifest data. Relative server directories are
+// resolved against baseDir.
+func Parse(data []byte, baseDir string) (*Manifest, error) {
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, errors.Wrap(err, "failed to parse manifest")
+	}
+
+	m.baseDir = baseDir
+
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Path returns the absolute path of the file managed for a server.
+func (m *Manifest) Path(s Server) string {
+	dir := s.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(m.baseDir, dir)
+	}
+
+	return filepath.Join(dir, s.File)
+}
+
+// validate checks required fields and fills in defaults.
+func (m *Manifest) validate() error {
+	if len(m.Servers) == 0 {
+		return errors.New("manifest does not list any servers")
+	}
+
+	seen := make(map[string]string, len(m.Servers))
+	for i := range m.Servers {
+		s := &m.Servers[i]
+
+		if s.Dir == "" {
+			return errors.Newf("server #%d: dir is required", i+1)
+		}
+		if s.Project == "" {
+			return errors.Newf("server %s: project is required", s.Dir)
+		}
+		if s.Name == "" {
+			s.Name = s.Dir
+		}
+		if s.Artifact == "" {
+			s.Artifact = api.DefaultDownloadKey
+		}
+		if s.File == "" {
+			s.File = DefaultFile
+		}
+
+		s.Channel = strings.ToLower(s.Channel)
+		switch api.Channel(s.Channel) {
+		case "", api.ChannelAlpha, api.ChannelBeta, api.ChannelStable, api.ChannelRecommended:
+		default:
+			return errors.Newf("server %s: unknown channel %q", s.Name, s.Channel)
+		}
+
+		path := m.Path(*s)
+		if other, ok := seen[path]; ok {
+			return errors.Newf("servers %s and %s manage the same file %s", other, s.Name, path)
+		}
+		seen[path] = s.Name
+	}
+
+	return nil
+}
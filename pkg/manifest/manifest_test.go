@@ -0,0 +1,192 @@
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+var jarData = []byte("paper 1.21.11 build 74")
+
+func newTestServer(t *testing.T, downloads *int32) *httptest.Server {
+	t.Helper()
+
+	sum := sha256.Sum256(jarData)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/projects/paper":
+			resp := api.ProjectV3Response{
+				Project: api.ProjectMeta{ID: "paper", Name: "Paper"},
+				Versions: map[string][]string{
+					"1.21": {"1.21.11", "1.21.10"},
+					"1.20": {"1.20.6"},
+				},
+			}
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				t.Fatalf("Failed to encode response: %v", err)
+			}
+		case "/v3/projects/paper/versions/1.21.11/builds":
+			resp := []api.BuildV3Response{
+				{ID: 73, Channel: "STABLE"},
+				{
+					ID:      74,
+					Channel: "STABLE",
+					Downloads: map[string]api.DownloadV3{
+						"server:default": {
+							Name:      "paper-1.21.11-74.jar",
+							URL:       server.URL + "/jars/paper-1.21.11-74.jar",
+							Checksums: api.ChecksumsV3{SHA256: hex.EncodeToString(sum[:])},
+						},
+					},
+				},
+			}
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				t.Fatalf("Failed to encode response: %v", err)
+			}
+		case "/jars/paper-1.21.11-74.jar":
+			atomic.AddInt32(downloads, 1)
+			_, _ = w.Write(jarData)
+		default:
+			t.Errorf("Unexpected request path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return server
+}
+
+func TestParse_Defaults(t *testing.T) {
+	m, err := Parse([]byte(`
+servers:
+  - dir: lobby
+    project: paper
+    channel: STABLE
+`), "/srv")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	s := m.Servers[0]
+	if s.Name != "lobby" || s.Artifact != api.DefaultDownloadKey || s.File != DefaultFile || s.Channel != "stable" {
+		t.Errorf("Unexpected defaults: %+v", s)
+	}
+
+	if got := m.Path(s); got != filepath.Join("/srv", "lobby", "server.jar") {
+		t.Errorf("Expected path /srv/lobby/server.jar, got %s", got)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"no servers":      `servers: []`,
+		"missing project": "servers:\n  - dir: lobby\n",
+		"unknown channel": "servers:\n  - dir: lobby\n    project: paper\n    channel: nightly\n",
+		"duplicate file":  "servers:\n  - dir: a\n    project: paper\n  - dir: a\n    project: folia\n",
+	}
+
+	for name, data := range tests {
+		if _, err := Parse([]byte(data), "/srv"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestComputeAndApply(t *testing.T) {
+	var downloads int32
+	server := newTestServer(t, &downloads)
+	defer server.Close()
+
+	dir := t.TempDir()
+	m, err := Parse([]byte(`
+servers:
+  - name: lobby
+    dir: lobby
+    project: paper
+    version: 1.21.x
+    channel: stable
+  - name: survival
+    dir: survival
+    project: paper
+    version: 1.21.x
+    channel: stable
+  - name: creative
+    dir: creative
+    project: paper
+    version: 1.21.11
+    channel: stable
+`), dir)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	// survival is outdated, creative is already up to date.
+	for name, data := range map[string][]byte{"survival": []byte("old jar"), "creative": jarData} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "server.jar"), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := api.NewClient().WithBaseURL(server.URL)
+	ctx := context.Background()
+
+	plan, err := Compute(ctx, client, m)
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+
+	want := []Action{ActionCreate, ActionReplace, ActionNone}
+	for i, change := range plan.Changes {
+		if change.Action != want[i] {
+			t.Errorf("%s: expected action %s, got %s", change.Server.Name, want[i], change.Action)
+		}
+		if change.Version != "1.21.11" || change.Build != 74 {
+			t.Errorf("%s: expected 1.21.11 build 74, got %s build %d", change.Server.Name, change.Version, change.Build)
+		}
+	}
+	if !plan.HasDrift() {
+		t.Error("Expected plan to report drift")
+	}
+
+	results, err := Apply(ctx, client, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 applied changes, got %d", len(results))
+	}
+	if downloads != 1 {
+		t.Errorf("Expected the shared artifact to be downloaded once, got %d downloads", downloads)
+	}
+
+	for _, name := range []string{"lobby", "survival"} {
+		data, err := os.ReadFile(filepath.Join(dir, name, "server.jar"))
+		if err != nil {
+			t.Fatalf("Failed to read %s jar: %v", name, err)
+		}
+		if string(data) != string(jarData) {
+			t.Errorf("%s: unexpected jar contents %q", name, data)
+		}
+	}
+
+	plan, err = Compute(ctx, client, m)
+	if err != nil {
+		t.Fatalf("Compute after apply failed: %v", err)
+	}
+	if plan.HasDrift() {
+		t.Error("Expected no drift after apply")
+	}
+}
@@ -0,0 +1,247 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+// Action describes what has to happen to a managed file.
+type Action string
+
+const (
+	// ActionNone means the file already matches the desired build.
+	ActionNone Action = "none"
+	// ActionCreate means the file does not exist yet.
+	ActionCreate Action = "create"
+	// ActionReplace means the file exists but differs from the desired build.
+	ActionReplace Action = "replace"
+)
+
+// Change is the planned state of a single managed file.
+type Change struct {
+	Server        Server
+	Path          string // Absolute path of the managed file
+	Version       string // Resolved version
+	Build         int32  // Resolved build ID
+	Channel       string // Channel of the resolved build, as reported by the API
+	Download      api.DownloadV3
+	CurrentSHA256 string // Checksum of the existing file, empty if missing
+	Action        Action
+}
+
+// Plan is the set of changes needed to bring all servers of a manifest to
+// their desired state.
+type Plan struct {
+	Changes []Change
+}
+
+// HasDrift reports whether any managed file differs from its desired state.
+func (p *Plan) HasDrift() bool {
+	for _, change := range p.Changes {
+		if change.Action != ActionNone {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolution is the build selected for a project, version constraint and channel.
+type resolution struct {
+	version string
+	build   *api.BuildV3Response
+}
+
+// Compute resolves the desired build for every server in the manifest and
+// compares it with the files on disk. Servers sharing the same project,
+// version constraint and channel are resolved only once.
+func Compute(ctx context.Context, client api.API, m *Manifest) (*Plan, error) {
+	resolved := make(map[string]resolution)
+	plan := &Plan{Changes: make([]Change, 0, len(m.Servers))}
+
+	for _, s := range m.Servers {
+		key := s.Project + "\x00" + s.Version + "\x00" + s.Channel
+
+		res, ok := resolved[key]
+		if !ok {
+			version, err := client.ResolveVersion(ctx, s.Project, s.Version, api.Channel(s.Channel))
+			if err != nil {
+				return nil, errors.Wrapf(err, "server %s: failed to resolve version", s.Name)
+			}
+
+			build, err := client.GetLatestBuildForChannel(ctx, s.Project, version, api.Channel(s.Channel))
+			if err != nil {
+				return nil, errors.Wrapf(err, "server %s: failed to resolve build", s.Name)
+			}
+
+			res = resolution{version: version, build: build}
+			resolved[key] = res
+		}
+
+		download, ok := res.build.GetDownload(s.Artifact)
+		if !ok {
+			return nil, errors.Newf("server %s: build %d of %s %s has no %s download",
+				s.Name, res.build.ID, s.Project, res.version, s.Artifact)
+		}
+
+		change := Change{
+			Server:   s,
+			Path:     m.Path(s),
+			Version:  res.version,
+			Build:    res.build.ID,
+			Channel:  res.build.Channel,
+			Download: download,
+			Action:   ActionCreate,
+		}
+
+		current, err := api.FileSHA256(change.Path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, errors.Wrapf(err, "server %s: failed to checksum %s", s.Name, change.Path)
+		case current == download.Checksums.SHA256:
+			change.CurrentSHA256 = current
+			change.Action = ActionNone
+		default:
+			change.CurrentSHA256 = current
+			change.Action = ActionReplace
+		}
+
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	return plan, nil
+}
+
+// Write renders the plan in a diff-like format: "+" marks files to create,
+// "~" files to replace and a blank marker files that are up to date.
+func (p *Plan) Write(w io.Writer) error {
+	var created, replaced, unchanged int
+
+	for _, change := range p.Changes {
+		s := change.Server
+		target := fmt.Sprintf("%s %s build %d", s.Project, change.Version, change.Build)
+		if change.Channel != "" {
+			target += ", " + change.Channel
+		}
+		path := filepath.Join(s.Dir, s.File)
+
+		var err error
+		switch change.Action {
+		case ActionCreate:
+			created++
+			_, err = fmt.Fprintf(w, "+ %s: %s (%s)\n", s.Name, path, target)
+		case ActionReplace:
+			replaced++
+			_, err = fmt.Fprintf(w, "~ %s: %s (%s) sha256 %s -> %s\n", s.Name, path, target,
+				shortSHA(change.CurrentSHA256), shortSHA(change.Download.Checksums.SHA256))
+		default:
+			unchanged++
+			_, err = fmt.Fprintf(w, "  %s: %s (%s) up to date\n", s.Name, path, target)
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to write plan")
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\nPlan: %d to create, %d to replace, %d unchanged.\n", created, replaced, unchanged)
+
+	return errors.Wrap(err, "failed to write plan")
+}
+
+// shortSHA abbreviates a checksum for display.
+func shortSHA(sum string) string {
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+
+	return sum
+}
+
+// Result describes how a planned change was applied.
+type Result struct {
+	Change Change
+	// Source is the path the file was copied from, or empty if it was downloaded.
+	Source string
+}
+
+// Apply executes all changes of a plan that require action. Each distinct
+// artifact is downloaded once; other servers needing the same artifact get a
+// verified copy of the downloaded file. Files are replaced atomically.
+func Apply(ctx context.Context, client api.API, plan *Plan) ([]Result, error) {
+	downloaded := make(map[string]string)
+	var results []Result
+
+	for _, change := range plan.Changes {
+		if change.Action == ActionNone {
+			continue
+		}
+
+		sum := change.Download.Checksums.SHA256
+		if source, ok := downloaded[sum]; ok && sum != "" {
+			if err := copyFile(source, change.Path, sum); err != nil {
+				return results, errors.Wrapf(err, "server %s: failed to copy %s", change.Server.Name, source)
+			}
+			results = append(results, Result{Change: change, Source: source})
+			continue
+		}
+
+		if _, err := client.DownloadTo(ctx, change.Download, change.Path); err != nil {
+			return results, errors.Wrapf(err, "server %s: failed to download %s", change.Server.Name, change.Download.Name)
+		}
+
+		downloaded[sum] = change.Path
+		results = append(results, Result{Change: change})
+	}
+
+	return results, nil
+}
+
+// copyFile atomically copies src to dst and verifies the copy's checksum.
+func copyFile(src, dst, expectedSHA256 string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err, "failed to open source file")
+	}
+	defer func() { _ = in.Close() }()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return errors.Wrap(err, "failed to create destination directory")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.part")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary file")
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		_ = tmp.Close()
+		return errors.Wrap(err, "failed to copy data")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to close temporary file")
+	}
+
+	actual, err := api.FileSHA256(tmpPath)
+	if err != nil {
+		return err
+	}
+	if actual != expectedSHA256 {
+		return errors.Newf("SHA256 mismatch: expected %s, got %s", expectedSHA256, actual)
+	}
+
+	if err := os.Chmod(tmpPath, 0o644); err != nil {
+		return errors.Wrap(err, "failed to set file permissions")
+	}
+
+	return errors.Wrap(os.Rename(tmpPath, dst), "failed to move file into place")
+}
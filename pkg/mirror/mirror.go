@@ -0,0 +1,343 @@
+// Package mirror replicates projects, versions and builds of the fill API
+// into a directory for air-gapped environments.
+//
+// The directory is laid out like the API's URL structure. Since an endpoint
+// such as /v3/projects/paper is also the prefix of other endpoints, each
+// response is stored as api.IndexFile in a directory named after it, e.g.
+// v3/projects/paper/index.json. Jars are stored once per checksum under
+// v1/objects/SHA256/NAME, like on the upstream download host, and download
+// URLs in the mirrored metadata point at them.
+//
+// A Client reads the mirror through a file:// base URL, or through any
+// static web server serving the directory with index.json as index file.
+package mirror
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/internal/atomicfile"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+// ObjectsDir is the directory, relative to the mirror root, holding jars.
+const ObjectsDir = "v1/objects"
+
+// Options selects what is mirrored.
+type Options struct {
+	Targets   []api.WatchTarget // Projects and version constraints to mirror
+	Builds    int               // Number of latest builds mirrored per version (0 means all)
+	Channel   api.Channel       // Only mirror builds in this channel (empty means all)
+	Artifacts []string          // Download keys to mirror (empty means api.DefaultDownloadKey)
+	PublicURL string            // Base URL written into download URLs (empty means relative URLs)
+	Verify    bool              // Verify the checksum of jars already in the mirror
+}
+
+// Result summarizes a sync.
+type Result struct {
+	Versions   int   `json:"versions"`
+	Builds     int   `json:"builds"`
+	Downloaded int   `json:"downloaded"`
+	Skipped    int   `json:"skipped"`
+	Bytes      int64 `json:"bytes"`
+}
+
+// Sync updates the mirror in dir. Builds already in the mirror are kept, so
+// repeated syncs only download what is new.
+func Sync(ctx context.Context, client api.API, dir string, opts Options) (*Result, error) {
+	if len(opts.Artifacts) == 0 {
+		opts.Artifacts = []string{api.DefaultDownloadKey}
+	}
+
+	m := &mirror{client: client, dir: dir, opts: opts, result: &Result{}}
+
+	// Merge targets of the same project so each project is written once.
+	projects := make(map[string][]string)
+	var order []string
+	for _, target := range opts.Targets {
+		if _, ok := projects[target.Project]; !ok {
+			order = append(order, target.Project)
+		}
+		projects[target.Project] = append(projects[target.Project], target.Version)
+	}
+
+	var infos []api.ProjectV3Info
+	for _, project := range order {
+		info, err := m.syncProject(ctx, project, projects[project])
+		if err != nil {
+			return m.result, errors.Wrapf(err, "failed to mirror %s", project)
+		}
+		infos = append(infos, *info)
+	}
+
+	if err := m.writeProjects(infos); err != nil {
+		return m.result, err
+	}
+
+	return m.result, nil
+}
+
+type mirror struct {
+	client api.API
+	dir    string
+	opts   Options
+	result *Result
+}
+
+// syncProject mirrors the selected versions of a project and returns its
+// entry for the projects list.
+func (m *mirror) syncProject(ctx context.Context, project string, constraints []string) (*api.ProjectV3Info, error) {
+	projectInfo, err := m.client.GetProject(ctx, project)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get project info")
+	}
+
+	selected := make(map[string]bool)
+	for _, constraint := range constraints {
+		for _, version := range projectInfo.SelectVersions(constraint) {
+			selected[version] = true
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errors.Newf("no versions match %v", constraints)
+	}
+
+	// Versions mirrored earlier stay listed.
+	var previous api.ProjectV3Response
+	if err := m.readJSON(path.Join("v3/projects", project), &previous); err == nil {
+		for _, versions := range previous.Versions {
+			for _, version := range versions {
+				selected[version] = true
+			}
+		}
+	}
+
+	upstream := projectInfo.FlattenVersions()
+	for _, version := range slices.Sorted(maps.Keys(selected)) {
+		if !slices.Contains(upstream, version) {
+			continue // Removed upstream, keep the mirrored copy as is
+		}
+		if err := m.syncVersion(ctx, project, version); err != nil {
+			return nil, errors.Wrapf(err, "failed to mirror %s", version)
+		}
+	}
+
+	filtered := api.ProjectV3Response{
+		Project:  projectInfo.Project,
+		Versions: make(map[string][]string),
+	}
+	for family, versions := range projectInfo.Versions {
+		for _, version := range versions {
+			if selected[version] {
+				filtered.Versions[family] = append(filtered.Versions[family], version)
+			}
+		}
+	}
+	for family, versions := range previous.Versions {
+		for _, version := range versions {
+			if !slices.Contains(filtered.Versions[family], version) {
+				filtered.Versions[family] = append(filtered.Versions[family], version)
+			}
+		}
+	}
+
+	if err := m.writeJSON(path.Join("v3/projects", project), filtered); err != nil {
+		return nil, err
+	}
+
+	if err := m.writeVersions(ctx, project, selected); err != nil {
+		return nil, err
+	}
+
+	return &api.ProjectV3Info{Project: filtered.Project, Versions: filtered.Versions}, nil
+}
+
+// writeVersions writes the versions list of a project, limited to the
+// mirrored versions.
+func (m *mirror) writeVersions(ctx context.Context, project string, selected map[string]bool) error {
+	versions, err := m.client.GetVersions(ctx, project)
+	if err != nil {
+		return errors.Wrap(err, "failed to get versions")
+	}
+
+	filtered := make([]api.VersionV3Response, 0, len(selected))
+	for _, version := range versions {
+		if selected[version.Version.ID] {
+			filtered = append(filtered, version)
+		}
+	}
+
+	return m.writeJSON(path.Join("v3/projects", project, "versions"), filtered)
+}
+
+// syncVersion mirrors a version, its selected builds and their jars.
+func (m *mirror) syncVersion(ctx context.Context, project, version string) error {
+	versionInfo, err := m.client.GetVersion(ctx, project, version)
+	if err != nil {
+		return errors.Wrap(err, "failed to get version info")
+	}
+
+	versionPath := path.Join("v3/projects", project, "versions", version)
+	if err := m.writeJSON(versionPath, versionInfo); err != nil {
+		return err
+	}
+	m.result.Versions++
+
+	var channels []api.Channel
+	if m.opts.Channel != "" {
+		channels = append(channels, m.opts.Channel)
+	}
+
+	builds, err := m.client.GetBuilds(ctx, project, version, channels...)
+	if err != nil {
+		return errors.Wrap(err, "failed to get builds")
+	}
+
+	sort.Slice(builds, func(i, j int) bool { return builds[i].ID < builds[j].ID })
+	if m.opts.Builds > 0 && len(builds) > m.opts.Builds {
+		builds = builds[len(builds)-m.opts.Builds:]
+	}
+
+	// Keep builds mirrored earlier that are not selected anymore.
+	mirrored := make(map[int32]api.BuildV3Response)
+	var previous []api.BuildV3Response
+	if err := m.readJSON(path.Join(versionPath, "builds"), &previous); err == nil {
+		for _, build := range previous {
+			mirrored[build.ID] = build
+		}
+	}
+
+	for i := range builds {
+		build := builds[i]
+		if err := m.syncBuild(ctx, &build); err != nil {
+			return errors.Wrapf(err, "failed to mirror build %d", build.ID)
+		}
+		mirrored[build.ID] = build
+
+		if err := m.writeJSON(path.Join(versionPath, "builds", strconv.Itoa(int(build.ID))), build); err != nil {
+			return err
+		}
+		m.result.Builds++
+	}
+
+	if len(mirrored) == 0 {
+		return nil
+	}
+
+	all := slices.SortedFunc(maps.Values(mirrored), func(a, b api.BuildV3Response) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	if err := m.writeJSON(path.Join(versionPath, "builds"), all); err != nil {
+		return err
+	}
+
+	return m.writeJSON(path.Join(versionPath, "builds", "latest"), all[len(all)-1])
+}
+
+// syncBuild downloads the selected artifacts of a build into the objects
+// directory and points the build's download URLs at them. Artifacts that
+// are not mirrored are dropped from the build.
+func (m *mirror) syncBuild(ctx context.Context, build *api.BuildV3Response) error {
+	downloads := make(map[string]api.DownloadV3)
+
+	for _, key := range m.opts.Artifacts {
+		download, ok := build.GetDownload(key)
+		if !ok {
+			continue
+		}
+
+		object := path.Join(ObjectsDir, download.Checksums.SHA256, download.Name)
+		if err := m.syncObject(ctx, download, filepath.Join(m.dir, filepath.FromSlash(object))); err != nil {
+			return errors.Wrapf(err, "failed to mirror %s", download.Name)
+		}
+
+		if m.opts.PublicURL != "" {
+			download.URL = m.opts.PublicURL + "/" + object
+		} else {
+			download.URL = object
+		}
+		downloads[key] = download
+	}
+
+	build.Downloads = downloads
+
+	return nil
+}
+
+// syncObject downloads a jar unless a copy is already in the mirror.
+func (m *mirror) syncObject(ctx context.Context, download api.DownloadV3, dest string) error {
+	if info, err := os.Stat(dest); err == nil {
+		valid := download.Size == 0 || info.Size() == download.Size
+		if valid && m.opts.Verify {
+			sum, err := api.FileSHA256(dest)
+			if err != nil {
+				return err
+			}
+			valid = sum == download.Checksums.SHA256
+		}
+		if valid {
+			m.result.Skipped++
+			return nil
+		}
+	}
+
+	if _, err := m.client.DownloadTo(ctx, download, dest); err != nil {
+		return err
+	}
+
+	m.result.Downloaded++
+	if info, err := os.Stat(dest); err == nil {
+		m.result.Bytes += info.Size()
+	}
+
+	return nil
+}
+
+// writeProjects writes the projects list, merged with projects mirrored earlier.
+func (m *mirror) writeProjects(infos []api.ProjectV3Info) error {
+	var projects api.ProjectsV3Response
+	_ = m.readJSON("v3/projects", &projects)
+
+	for _, info := range infos {
+		i := slices.IndexFunc(projects.Projects, func(p api.ProjectV3Info) bool {
+			return p.Project.ID == info.Project.ID
+		})
+		if i >= 0 {
+			projects.Projects[i] = info
+		} else {
+			projects.Projects = append(projects.Projects, info)
+		}
+	}
+
+	return m.writeJSON("v3/projects", projects)
+}
+
+// readJSON reads the mirrored response of an endpoint.
+func (m *mirror) readJSON(endpoint string, v any) error {
+	data, err := os.ReadFile(filepath.Join(m.dir, filepath.FromSlash(endpoint), api.IndexFile))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// writeJSON atomically writes the response of an endpoint.
+func (m *mirror) writeJSON(endpoint string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode %s", endpoint)
+	}
+
+	return atomicfile.WriteFile(filepath.Join(m.dir, filepath.FromSlash(endpoint), api.IndexFile), data, 0o644)
+}
@@ -0,0 +1,168 @@
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+func jarFor(build int32) []byte {
+	return []byte("paper build " + strconv.Itoa(int(build)))
+}
+
+// newUpstream serves paper 1.21.11 with the given builds and counts jar downloads.
+func newUpstream(t *testing.T, downloads *int, builds ...int32) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp any
+		switch r.URL.Path {
+		case "/v3/projects/paper":
+			resp = api.ProjectV3Response{
+				Project:  api.ProjectMeta{ID: "paper", Name: "Paper"},
+				Versions: map[string][]string{"1.21": {"1.21.11"}, "1.20": {"1.20.6"}},
+			}
+		case "/v3/projects/paper/versions":
+			resp = []api.VersionV3Response{
+				{Version: api.VersionMeta{ID: "1.21.11"}},
+				{Version: api.VersionMeta{ID: "1.20.6"}},
+			}
+		case "/v3/projects/paper/versions/1.21.11":
+			resp = api.VersionV3Response{Version: api.VersionMeta{ID: "1.21.11", Java: api.JavaInfo{Version: api.JavaVersion{Minimum: 21}}}}
+		case "/v3/projects/paper/versions/1.21.11/builds":
+			list := make([]api.BuildV3Response, 0, len(builds))
+			for _, id := range builds {
+				sum := sha256.Sum256(jarFor(id))
+				name := "paper-1.21.11-" + strconv.Itoa(int(id)) + ".jar"
+				list = append(list, api.BuildV3Response{
+					ID:      id,
+					Channel: "STABLE",
+					Downloads: map[string]api.DownloadV3{
+						"server:default": {
+							Name:      name,
+							URL:       server.URL + "/jars/" + strconv.Itoa(int(id)),
+							Checksums: api.ChecksumsV3{SHA256: hex.EncodeToString(sum[:])},
+							Size:      int64(len(jarFor(id))),
+						},
+						"server:mojmap": {Name: "mojmap.jar", URL: server.URL + "/unused"},
+					},
+				})
+			}
+			resp = list
+		default:
+			if id, ok := strings.CutPrefix(r.URL.Path, "/jars/"); ok {
+				*downloads++
+				n, _ := strconv.Atoi(id)
+				_, _ = w.Write(jarFor(int32(n)))
+				return
+			}
+			t.Errorf("Unexpected request path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Fatalf("Failed to encode response: %v", err)
+		}
+	}))
+
+	return server
+}
+
+func TestSync(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	opts := Options{
+		Targets: []api.WatchTarget{{Project: "paper", Version: "1.21.x"}},
+		Builds:  2,
+	}
+
+	var downloads int
+	upstream := newUpstream(t, &downloads, 10, 11, 12)
+	result, err := Sync(ctx, api.NewClient().WithBaseURL(upstream.URL), dir, opts)
+	upstream.Close()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.Builds != 2 || result.Downloaded != 2 || downloads != 2 {
+		t.Errorf("Expected 2 builds downloaded, got %+v (%d requests)", result, downloads)
+	}
+
+	// A second sync only downloads the new build and keeps the old ones.
+	downloads = 0
+	upstream = newUpstream(t, &downloads, 10, 11, 12, 13)
+	result, err = Sync(ctx, api.NewClient().WithBaseURL(upstream.URL), dir, opts)
+	upstream.Close()
+	if err != nil {
+		t.Fatalf("Second sync failed: %v", err)
+	}
+	if result.Downloaded != 1 || result.Skipped != 1 || downloads != 1 {
+		t.Errorf("Expected 1 download and 1 skip, got %+v (%d requests)", result, downloads)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "v3", "projects", "paper", "versions", "1.21.11", "builds", "latest", api.IndexFile)); err != nil {
+		t.Errorf("Expected latest build in mirror: %v", err)
+	}
+
+	// The mirror is usable through a file:// base URL.
+	client := api.NewClient().WithBaseURL("file://" + filepath.ToSlash(dir))
+
+	project, err := client.GetProject(ctx, "paper")
+	if err != nil {
+		t.Fatalf("GetProject failed: %v", err)
+	}
+	if versions := project.FlattenVersions(); len(versions) != 1 || versions[0] != "1.21.11" {
+		t.Errorf("Expected only 1.21.11 in mirror, got %v", versions)
+	}
+
+	builds, err := client.GetBuilds(ctx, "paper", "1.21.11", api.ChannelStable)
+	if err != nil {
+		t.Fatalf("GetBuilds failed: %v", err)
+	}
+	if len(builds) != 3 {
+		t.Errorf("Expected builds 11-13 in mirror, got %d builds", len(builds))
+	}
+
+	latest, err := client.GetLatestBuildV3(ctx, "paper", "1.21.11")
+	if err != nil {
+		t.Fatalf("GetLatestBuildV3 failed: %v", err)
+	}
+	if latest.ID != 13 {
+		t.Errorf("Expected latest build 13, got %d", latest.ID)
+	}
+	if _, ok := latest.GetDownload("server:mojmap"); ok {
+		t.Error("Expected unmirrored artifact to be dropped")
+	}
+
+	download, _ := latest.GetDownload(api.DefaultDownloadKey)
+	if !strings.HasPrefix(download.URL, "file://") {
+		t.Errorf("Expected download URL resolved against the mirror, got %s", download.URL)
+	}
+
+	dest := filepath.Join(t.TempDir(), "server.jar")
+	if _, err := client.DownloadTo(ctx, download, dest); err != nil {
+		t.Fatalf("DownloadTo failed: %v", err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("Failed to read jar: %v", err)
+	}
+	if string(data) != string(jarFor(13)) {
+		t.Errorf("Unexpected jar content: %q", data)
+	}
+
+	if _, err := client.GetVersion(ctx, "paper", "1.20.6"); err == nil {
+		t.Error("Expected unmirrored version to be missing")
+	}
+}
@@ -0,0 +1,198 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+// Format selects the payload layout of a webhook.
+type Format string
+
+const (
+	// FormatDiscord posts a Discord message with an embed.
+	FormatDiscord Format = "discord"
+	// FormatSlack posts a Slack message built from blocks.
+	FormatSlack Format = "slack"
+	// FormatJSON posts the message fields as a plain JSON object.
+	FormatJSON Format = "json"
+)
+
+// DefaultTemplate renders the summary line of a message.
+const DefaultTemplate = `New {{.Project}} {{.Version}} build #{{.Build}} ({{.Channel}})`
+
+// maxCommits is the number of commit messages included in chat payloads.
+const maxCommits = 10
+
+// Message is the build information sent to webhooks.
+type Message struct {
+	Event       api.EventType  `json:"event,omitempty"`
+	Project     string         `json:"project"`
+	Version     string         `json:"version"`
+	Build       int32          `json:"build"`
+	Channel     string         `json:"channel"`
+	Time        time.Time      `json:"time"`
+	Commits     []api.CommitV3 `json:"commits"`
+	DownloadURL string         `json:"download_url"`
+}
+
+// MessageFromBuild creates a message describing a build.
+func MessageFromBuild(project, version string, build *api.BuildV3Response) Message {
+	return Message{
+		Project:     project,
+		Version:     version,
+		Build:       build.ID,
+		Channel:     build.Channel,
+		Time:        build.Time,
+		Commits:     build.Commits,
+		DownloadURL: build.GetDownloadURL(),
+	}
+}
+
+// MessageFromEvent creates a message from a watcher event. Only build
+// events (new builds and channel changes) produce a message.
+func MessageFromEvent(event api.Event) (Message, bool) {
+	if event.BuildInfo == nil {
+		return Message{}, false
+	}
+
+	msg := MessageFromBuild(event.Project, event.Version, event.BuildInfo)
+	msg.Event = event.Type
+
+	return msg, true
+}
+
+// ParseTemplate parses a message template. The template is executed with a
+// Message as data; an empty text selects DefaultTemplate.
+func ParseTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse message template")
+	}
+
+	return tmpl, nil
+}
+
+// Payload renders the request body for a webhook format. The summary text is
+// produced by tmpl (DefaultTemplate if nil).
+func Payload(format Format, tmpl *template.Template, msg Message) ([]byte, error) {
+	if tmpl == nil {
+		var err error
+		if tmpl, err = ParseTemplate(""); err != nil {
+			return nil, err
+		}
+	}
+
+	var text bytes.Buffer
+	if err := tmpl.Execute(&text, msg); err != nil {
+		return nil, errors.Wrap(err, "failed to render message template")
+	}
+	summary := strings.TrimSpace(text.String())
+
+	var payload any
+	switch format {
+	case FormatDiscord:
+		payload = discordPayload(summary, msg)
+	case FormatSlack:
+		payload = slackPayload(summary, msg)
+	case FormatJSON, "":
+		payload = struct {
+			Text string `json:"text"`
+			Message
+		}{Text: summary, Message: msg}
+	default:
+		return nil, errors.Newf("unknown webhook format %q", format)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode payload")
+	}
+
+	return data, nil
+}
+
+// commitLines renders up to maxCommits commits as "`sha` message" lines,
+// which both Discord and Slack display with the SHA as code.
+func commitLines(commits []api.CommitV3) string {
+	var b strings.Builder
+	for i, commit := range commits {
+		if i == maxCommits {
+			fmt.Fprintf(&b, "…and %d more\n", len(commits)-maxCommits)
+			break
+		}
+		sha := commit.SHA
+		if len(sha) > 7 {
+			sha = sha[:7]
+		}
+		message, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+		fmt.Fprintf(&b, "`%s` %s\n", sha, message)
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// discordPayload builds a Discord webhook message with a single embed.
+func discordPayload(summary string, msg Message) map[string]any {
+	embed := map[string]any{
+		"title": summary,
+		"fields": []map[string]any{
+			{"name": "Version", "value": msg.Version, "inline": true},
+			{"name": "Build", "value": fmt.Sprint(msg.Build), "inline": true},
+			{"name": "Channel", "value": msg.Channel, "inline": true},
+		},
+	}
+	if msg.DownloadURL != "" {
+		embed["url"] = msg.DownloadURL
+	}
+	if !msg.Time.IsZero() {
+		embed["timestamp"] = msg.Time.Format(time.RFC3339)
+	}
+	if commits := commitLines(msg.Commits); commits != "" {
+		embed["description"] = commits
+	}
+
+	return map[string]any{
+		"content": summary,
+		"embeds":  []any{embed},
+	}
+}
+
+// slackPayload builds a Slack message from blocks. The top-level text is
+// used for notifications and clients without block support.
+func slackPayload(summary string, msg Message) map[string]any {
+	blocks := []map[string]any{
+		{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": "*" + summary + "*"}},
+		{"type": "context", "elements": []map[string]any{{
+			"type": "mrkdwn",
+			"text": fmt.Sprintf("%s %s · build %d · %s", msg.Project, msg.Version, msg.Build, msg.Channel),
+		}}},
+	}
+	if commits := commitLines(msg.Commits); commits != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": map[string]any{"type": "mrkdwn", "text": commits},
+		})
+	}
+	if msg.DownloadURL != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": map[string]any{"type": "mrkdwn", "text": "<" + msg.DownloadURL + "|Download>"},
+		})
+	}
+
+	return map[string]any{
+		"text":   summary,
+		"blocks": blocks,
+	}
+}
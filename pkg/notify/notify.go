@@ -0,0 +1,215 @@
+// Package notify posts build notifications to chat webhooks.
+//
+// Messages describing a build are rendered as Discord embeds, Slack blocks
+// or a generic JSON object and posted to every configured webhook, retrying
+// transient failures.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// DefaultRetries is the default number of retries after a failed post.
+	DefaultRetries = 3
+	// DefaultRetryDelay is the delay before the first retry; it doubles on every retry.
+	DefaultRetryDelay = time.Second
+	// DefaultTimeout is the default timeout of a single post.
+	DefaultTimeout = 10 * time.Second
+)
+
+// Webhook is a destination for notifications.
+type Webhook struct {
+	URL      string
+	Format   Format
+	Template *template.Template // Summary template (DefaultTemplate if nil)
+}
+
+// ParseWebhook parses a webhook given as "FORMAT=URL" or as a bare URL. For
+// bare URLs the format is derived from the host: Discord and Slack webhook
+// hosts select their format, anything else receives generic JSON.
+func ParseWebhook(s string) (Webhook, error) {
+	format, rawURL, found := strings.Cut(s, "=")
+	if !found || strings.Contains(format, ":") {
+		rawURL = s
+		format = ""
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return Webhook{}, errors.New("invalid webhook URL, expected an absolute URL optionally prefixed with FORMAT=")
+	}
+
+	webhook := Webhook{URL: rawURL, Format: Format(strings.ToLower(format))}
+	if webhook.Format == "" {
+		webhook.Format = detectFormat(u.Hostname())
+	}
+
+	switch webhook.Format {
+	case FormatDiscord, FormatSlack, FormatJSON:
+	default:
+		return Webhook{}, errors.Newf("unknown webhook format %q", format)
+	}
+
+	return webhook, nil
+}
+
+// detectFormat guesses the payload format from a webhook host name.
+func detectFormat(host string) Format {
+	switch {
+	case host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com"):
+		return FormatDiscord
+	case host == "hooks.slack.com":
+		return FormatSlack
+	default:
+		return FormatJSON
+	}
+}
+
+// Redact returns a webhook URL without its user info, path and query,
+// which carry the secret of most webhooks, for use in messages and logs.
+func Redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "<redacted>"
+	}
+
+	return u.Scheme + "://" + u.Host + "/<redacted>"
+}
+
+// redactURL redacts the URL that the HTTP client puts into its errors.
+func redactURL(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+
+	return &url.Error{Op: urlErr.Op, URL: Redact(urlErr.URL), Err: urlErr.Err}
+}
+
+// Notifier posts messages to a set of webhooks.
+type Notifier struct {
+	HTTPClient *http.Client
+	Webhooks   []Webhook
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// NewNotifier creates a notifier posting to the given webhooks.
+func NewNotifier(webhooks ...Webhook) *Notifier {
+	return &Notifier{
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		Webhooks:   webhooks,
+		Retries:    DefaultRetries,
+		RetryDelay: DefaultRetryDelay,
+	}
+}
+
+// WithRetries sets the number of retries and the initial retry delay.
+func (n *Notifier) WithRetries(retries int, delay time.Duration) *Notifier {
+	n.Retries = retries
+	n.RetryDelay = delay
+	return n
+}
+
+// Notify posts a message to every webhook. A failing webhook does not stop
+// delivery to the others; all failures are returned together.
+func (n *Notifier) Notify(ctx context.Context, msg Message) error {
+	var errs []error
+	for _, webhook := range n.Webhooks {
+		payload, err := Payload(webhook.Format, webhook.Template, msg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := n.post(ctx, webhook.URL, payload); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to notify %s webhook at %s", webhook.Format, Redact(webhook.URL)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// post sends a payload, retrying network errors, rate limiting and server
+// errors with exponential backoff. A Retry-After header overrides the delay.
+func (n *Notifier) post(ctx context.Context, webhookURL string, payload []byte) error {
+	delay := n.RetryDelay
+
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := n.postOnce(ctx, webhookURL, payload)
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt >= n.Retries {
+			return err
+		}
+
+		wait := delay
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		delay *= 2
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "notification canceled")
+		}
+	}
+}
+
+// permanentError marks a failure that retrying will not fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// postOnce sends a payload once. It returns the server's requested retry
+// delay, if any.
+func (n *Notifier) postOnce(ctx context.Context, webhookURL string, payload []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, &permanentError{errors.Wrap(redactURL(err), "failed to create request")}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.HTTPClient.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(redactURL(err), "failed to execute request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return 0, nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = errors.Newf("webhook returned status %d, body: %s", resp.StatusCode, body)
+
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return 0, &permanentError{err}
+	}
+
+	var retryAfter time.Duration
+	if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return retryAfter, err
+}
@@ -0,0 +1,209 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+var testMessage = Message{
+	Project:     "paper",
+	Version:     "1.21.11",
+	Build:       74,
+	Channel:     "STABLE",
+	Commits:     []api.CommitV3{{SHA: "0123456789abcdef", Message: "Fix item dupe\n\nLong description"}},
+	DownloadURL: "https://example.com/paper-1.21.11-74.jar",
+}
+
+func TestParseWebhook(t *testing.T) {
+	tests := []struct {
+		input  string
+		format Format
+		ok     bool
+	}{
+		{"https://discord.com/api/webhooks/1/abc", FormatDiscord, true},
+		{"https://hooks.slack.com/services/T/B/X", FormatSlack, true},
+		{"https://example.com/hook", FormatJSON, true},
+		{"slack=https://chat.example.com/hook", FormatSlack, true},
+		{"https://example.com/hook?a=b", FormatJSON, true},
+		{"teams=https://example.com/hook", "", false},
+		{"not a url", "", false},
+	}
+
+	for _, tt := range tests {
+		webhook, err := ParseWebhook(tt.input)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseWebhook(%q) error = %v, want ok=%v", tt.input, err, tt.ok)
+			continue
+		}
+		if tt.ok && webhook.Format != tt.format {
+			t.Errorf("ParseWebhook(%q) format = %q, want %q", tt.input, webhook.Format, tt.format)
+		}
+	}
+}
+
+func TestPayload_Discord(t *testing.T) {
+	data, err := Payload(FormatDiscord, nil, testMessage)
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+
+	var payload struct {
+		Content string `json:"content"`
+		Embeds  []struct {
+			Title       string `json:"title"`
+			URL         string `json:"url"`
+			Description string `json:"description"`
+		} `json:"embeds"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+
+	if payload.Content != "New paper 1.21.11 build #74 (STABLE)" {
+		t.Errorf("Unexpected content %q", payload.Content)
+	}
+	if len(payload.Embeds) != 1 || payload.Embeds[0].URL != testMessage.DownloadURL {
+		t.Fatalf("Unexpected embeds: %+v", payload.Embeds)
+	}
+	if payload.Embeds[0].Description != "`0123456` Fix item dupe" {
+		t.Errorf("Unexpected description %q", payload.Embeds[0].Description)
+	}
+}
+
+func TestPayload_SlackTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate(`{{.Project}} #{{.Build}} is out`)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	data, err := Payload(FormatSlack, tmpl, testMessage)
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+
+	var payload struct {
+		Text   string           `json:"text"`
+		Blocks []map[string]any `json:"blocks"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+
+	if payload.Text != "paper #74 is out" {
+		t.Errorf("Unexpected text %q", payload.Text)
+	}
+	if len(payload.Blocks) != 4 {
+		t.Errorf("Expected 4 blocks, got %d", len(payload.Blocks))
+	}
+}
+
+func TestNotify_Retries(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts = make(map[string]int)
+		bodies   = make(map[string]string)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts[r.URL.Path]++
+		body, _ := io.ReadAll(r.Body)
+		bodies[r.URL.Path] = string(body)
+
+		switch r.URL.Path {
+		case "/flaky":
+			if attempts[r.URL.Path] < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/broken":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(
+		Webhook{URL: server.URL + "/flaky", Format: FormatJSON},
+		Webhook{URL: server.URL + "/broken", Format: FormatJSON},
+	).WithRetries(3, time.Millisecond)
+
+	err := notifier.Notify(context.Background(), testMessage)
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("Expected the broken webhook to fail with status 400, got %v", err)
+	}
+
+	if attempts["/flaky"] != 3 {
+		t.Errorf("Expected 3 attempts for the flaky webhook, got %d", attempts["/flaky"])
+	}
+	if attempts["/broken"] != 1 {
+		t.Errorf("Expected client errors not to be retried, got %d attempts", attempts["/broken"])
+	}
+
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(bodies["/flaky"]), &payload); err != nil {
+		t.Fatalf("Invalid JSON payload: %v", err)
+	}
+	if payload["download_url"] != testMessage.DownloadURL || payload["build"] != float64(74) {
+		t.Errorf("Unexpected generic payload: %v", payload)
+	}
+}
+
+func TestNotify_RedactsURL(t *testing.T) {
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer broken.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	notifier := NewNotifier(
+		Webhook{URL: broken.URL + "/api/webhooks/1/secret-token", Format: FormatDiscord},
+		Webhook{URL: down.URL + "/hook?token=secret-token", Format: FormatJSON},
+		Webhook{URL: "http://[::1/secret-token", Format: FormatJSON},
+	).WithRetries(0, time.Millisecond)
+
+	err := notifier.Notify(context.Background(), testMessage)
+	if err == nil {
+		t.Fatal("Expected every webhook to fail")
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Expected webhook secrets to be redacted, got %v", err)
+	}
+	if !strings.Contains(err.Error(), broken.URL+"/<redacted>") {
+		t.Errorf("Expected the webhook host in the error, got %v", err)
+	}
+
+	if _, err := ParseWebhook("secret-token"); err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Expected an invalid webhook to be rejected without echoing it, got %v", err)
+	}
+}
+
+func TestMessageFromEvent(t *testing.T) {
+	if _, ok := MessageFromEvent(api.Event{Type: api.EventNewVersion, Project: "paper"}); ok {
+		t.Error("Expected version events not to produce a message")
+	}
+
+	msg, ok := MessageFromEvent(api.Event{
+		Type:      api.EventChannelChanged,
+		Project:   "paper",
+		Version:   "1.21.11",
+		BuildInfo: &api.BuildV3Response{ID: 74, Channel: "STABLE"},
+	})
+	if !ok || msg.Build != 74 || msg.Event != api.EventChannelChanged {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+}
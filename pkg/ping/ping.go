@@ -0,0 +1,340 @@
+// Package ping is a client for the Server List Ping protocol Minecraft
+// clients use to show a server's version, player counts and MOTD in the
+// server list.
+//
+// A status request is a handshake with the next state set to status, an
+// empty status request and an optional ping whose pong gives the latency.
+// Packets are prefixed with their length and packet ID as VarInts.
+package ping
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// DefaultPort is the default port of Minecraft servers.
+	DefaultPort = 25565
+	// DefaultTimeout bounds dialing and the whole exchange with the server.
+	DefaultTimeout = 5 * time.Second
+)
+
+const (
+	// protocolAny is sent as the protocol version of the handshake; servers
+	// answer status requests regardless of the client's version.
+	protocolAny = -1
+	// stateStatus is the next state of a handshake requesting the status.
+	stateStatus = 1
+	// maxPacket bounds the size of packets read from the server.
+	maxPacket = 1 << 21
+)
+
+// Packet IDs in the status state.
+const (
+	packetStatus = 0x00
+	packetPing   = 0x01
+)
+
+// Status is the status a server reports.
+type Status struct {
+	Version     Version         `json:"version"`
+	Players     Players         `json:"players"`
+	Description json.RawMessage `json:"description,omitempty"` // MOTD as a string or text component
+	Favicon     string          `json:"favicon,omitempty"`     // PNG data URL
+	Latency     time.Duration   `json:"-"`                     // Round trip of the ping, zero if the server did not answer it
+}
+
+// Version is the version a server reports: a display name such as
+// "Paper 1.21.11" and the protocol number clients must speak.
+type Version struct {
+	Name     string `json:"name"`
+	Protocol int    `json:"protocol"`
+}
+
+// Players are the player counts a server reports. Sample lists some of the
+// online players; servers may leave it out or fill it with arbitrary text.
+type Players struct {
+	Max    int      `json:"max"`
+	Online int      `json:"online"`
+	Sample []Player `json:"sample,omitempty"`
+}
+
+// Player is an entry of the player sample.
+type Player struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// Ping requests the status of the server at addr ("host" or "host:port").
+// The exchange is bounded by DefaultTimeout and the deadline of ctx.
+func Ping(ctx context.Context, addr string) (*Status, error) {
+	host, port, err := splitAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to %s", addr)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	reader := bufio.NewReader(conn)
+
+	var handshake bytes.Buffer
+	writeVarInt(&handshake, protocolAny)
+	writeString(&handshake, host)
+	_ = binary.Write(&handshake, binary.BigEndian, uint16(port))
+	writeVarInt(&handshake, stateStatus)
+
+	// The handshake is followed by the status request, an empty packet.
+	var request bytes.Buffer
+	appendPacket(&request, packetStatus, handshake.Bytes())
+	appendPacket(&request, packetStatus, nil)
+
+	if _, err := conn.Write(request.Bytes()); err != nil {
+		return nil, errors.Wrap(err, "failed to send status request")
+	}
+
+	id, body, err := readPacket(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read status")
+	}
+	if id != packetStatus {
+		return nil, errors.Newf("unexpected packet 0x%02x in response to the status request", id)
+	}
+
+	data, err := readString(bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read status")
+	}
+
+	var status Status
+	if err := json.Unmarshal([]byte(data), &status); err != nil {
+		return nil, errors.Wrap(err, "failed to parse status")
+	}
+
+	// Some servers close the connection instead of answering the ping;
+	// the status is still valid without a latency.
+	status.Latency, _ = measureLatency(conn, reader)
+
+	return &status, nil
+}
+
+// splitAddr splits an address into host and port, defaulting to DefaultPort.
+func splitAddr(addr string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		// No port, or an IPv6 address without brackets.
+		return strings.Trim(addr, "[]"), DefaultPort, nil
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, errors.Newf("invalid port in %q", addr)
+	}
+
+	return host, port, nil
+}
+
+// measureLatency sends a ping and returns the time until its pong.
+func measureLatency(conn net.Conn, reader *bufio.Reader) (time.Duration, error) {
+	start := time.Now()
+
+	var payload bytes.Buffer
+	_ = binary.Write(&payload, binary.BigEndian, start.UnixMilli())
+
+	var packet bytes.Buffer
+	appendPacket(&packet, packetPing, payload.Bytes())
+
+	if _, err := conn.Write(packet.Bytes()); err != nil {
+		return 0, errors.Wrap(err, "failed to send ping")
+	}
+
+	id, body, err := readPacket(reader)
+	if err != nil {
+		return 0, err
+	}
+	if id != packetPing || !bytes.Equal(body, payload.Bytes()) {
+		return 0, errors.New("invalid pong")
+	}
+
+	return time.Since(start), nil
+}
+
+// appendPacket appends a packet with its length and ID.
+func appendPacket(buf *bytes.Buffer, id int32, body []byte) {
+	var payload bytes.Buffer
+	writeVarInt(&payload, id)
+	payload.Write(body)
+
+	writeVarInt(buf, int32(payload.Len()))
+	buf.Write(payload.Bytes())
+}
+
+// readPacket receives a packet and returns its ID and body.
+func readPacket(r *bufio.Reader) (int32, []byte, error) {
+	length, err := readVarInt(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if length <= 0 || length > maxPacket {
+		return 0, nil, errors.Newf("invalid packet length %d", length)
+	}
+
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return 0, nil, err
+	}
+
+	body := bytes.NewReader(packet)
+	id, err := readVarInt(body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return id, packet[len(packet)-body.Len():], nil
+}
+
+// writeVarInt appends a VarInt: 7 bits per byte, least significant first,
+// with the high bit set on all but the last byte.
+func writeVarInt(buf *bytes.Buffer, v int32) {
+	u := uint32(v)
+	for u >= 0x80 {
+		buf.WriteByte(byte(u) | 0x80)
+		u >>= 7
+	}
+	buf.WriteByte(byte(u))
+}
+
+// readVarInt reads a VarInt of at most five bytes.
+func readVarInt(r io.ByteReader) (int32, error) {
+	var v uint32
+	for i := range 5 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		v |= uint32(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return int32(v), nil
+		}
+	}
+
+	return 0, errors.New("VarInt is too long")
+}
+
+// writeString appends a VarInt-prefixed UTF-8 string.
+func writeString(buf *bytes.Buffer, s string) {
+	writeVarInt(buf, int32(len(s)))
+	buf.WriteString(s)
+}
+
+// readString reads a VarInt-prefixed UTF-8 string.
+func readString(r *bytes.Reader) (string, error) {
+	length, err := readVarInt(r)
+	if err != nil {
+		return "", err
+	}
+	if length < 0 || int(length) > r.Len() {
+		return "", errors.Newf("invalid string length %d", length)
+	}
+
+	data := make([]byte, length)
+	_, _ = r.Read(data)
+
+	return string(data), nil
+}
+
+// MOTD returns the description as plain text, without colors and
+// formatting codes.
+func (s *Status) MOTD() string {
+	var b strings.Builder
+	appendText(&b, s.Description)
+
+	return stripCodes(b.String())
+}
+
+// component is the part of a text component MOTD rendering needs.
+type component struct {
+	Text      string            `json:"text"`
+	Translate string            `json:"translate"`
+	Extra     []json.RawMessage `json:"extra"`
+}
+
+// appendText appends the text of a string, text component or array of
+// components.
+func appendText(b *strings.Builder, raw json.RawMessage) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return
+	}
+
+	switch raw[0] {
+	case '"':
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			b.WriteString(s)
+		}
+	case '[':
+		var parts []json.RawMessage
+		if json.Unmarshal(raw, &parts) == nil {
+			for _, part := range parts {
+				appendText(b, part)
+			}
+		}
+	case '{':
+		var c component
+		if json.Unmarshal(raw, &c) != nil {
+			return
+		}
+		if c.Text != "" {
+			b.WriteString(c.Text)
+		} else {
+			b.WriteString(c.Translate)
+		}
+		for _, extra := range c.Extra {
+			appendText(b, extra)
+		}
+	}
+}
+
+// stripCodes removes legacy formatting codes, a section sign followed by a
+// character.
+func stripCodes(s string) string {
+	var b strings.Builder
+	skip := false
+	for _, r := range s {
+		switch {
+		case skip:
+			skip = false
+		case r == '§':
+			skip = true
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
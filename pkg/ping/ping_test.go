@@ -0,0 +1,165 @@
+package ping
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"strconv"
+	"testing"
+)
+
+// handshake is what the test server received in a handshake.
+type handshake struct {
+	protocol int32
+	host     string
+	port     uint16
+	state    int32
+}
+
+// newTestServer starts an in-process server answering status requests with
+// status and, unless noPong is set, pings with pongs. Received handshakes
+// are sent to the returned channel.
+func newTestServer(t *testing.T, status string, noPong bool) (string, <-chan handshake) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	handshakes := make(chan handshake, 1)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn, status, noPong, handshakes)
+		}
+	}()
+
+	return listener.Addr().String(), handshakes
+}
+
+func serve(conn net.Conn, status string, noPong bool, handshakes chan<- handshake) {
+	defer func() { _ = conn.Close() }()
+
+	reader := bufio.NewReader(conn)
+
+	id, body, err := readPacket(reader)
+	if err != nil || id != packetStatus {
+		return
+	}
+
+	r := bytes.NewReader(body)
+	var hs handshake
+	hs.protocol, _ = readVarInt(r)
+	hs.host, _ = readString(r)
+	hi, _ := r.ReadByte()
+	lo, _ := r.ReadByte()
+	hs.port = uint16(hi)<<8 | uint16(lo)
+	hs.state, _ = readVarInt(r)
+	handshakes <- hs
+
+	if id, _, err := readPacket(reader); err != nil || id != packetStatus {
+		return
+	}
+
+	var response, payload bytes.Buffer
+	writeString(&payload, status)
+	appendPacket(&response, packetStatus, payload.Bytes())
+	if _, err := conn.Write(response.Bytes()); err != nil {
+		return
+	}
+
+	id, body, err = readPacket(reader)
+	if err != nil || id != packetPing || noPong {
+		return
+	}
+
+	var pong bytes.Buffer
+	appendPacket(&pong, packetPing, body)
+	_, _ = conn.Write(pong.Bytes())
+}
+
+const paperStatus = `{
+	"version": {"name": "Paper 1.21.11", "protocol": 774},
+	"players": {"max": 20, "online": 2, "sample": [{"name": "Notch", "id": "069a79f4-44e9-4726-a5be-fca90e38aaf5"}]},
+	"description": {"text": "§aA ", "extra": [{"text": "Paper", "bold": true}, " server"]}
+}`
+
+func TestPing(t *testing.T) {
+	addr, handshakes := newTestServer(t, paperStatus, false)
+
+	status, err := Ping(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+
+	if status.Version.Name != "Paper 1.21.11" || status.Version.Protocol != 774 {
+		t.Errorf("Unexpected version: %+v", status.Version)
+	}
+	if status.Players.Online != 2 || status.Players.Max != 20 || len(status.Players.Sample) != 1 {
+		t.Errorf("Unexpected players: %+v", status.Players)
+	}
+	if motd := status.MOTD(); motd != "A Paper server" {
+		t.Errorf("Expected MOTD %q, got %q", "A Paper server", motd)
+	}
+	if status.Latency <= 0 {
+		t.Error("Expected a latency")
+	}
+
+	_, port, _ := net.SplitHostPort(addr)
+	hs := <-handshakes
+	if hs.protocol != protocolAny || hs.host != "127.0.0.1" || strconv.Itoa(int(hs.port)) != port || hs.state != stateStatus {
+		t.Errorf("Unexpected handshake: %+v", hs)
+	}
+}
+
+func TestPing_NoPong(t *testing.T) {
+	addr, _ := newTestServer(t, `{"version":{"name":"1.21.11","protocol":774},"players":{"max":10,"online":0},"description":"Plain §lMOTD"}`, true)
+
+	status, err := Ping(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("Expected the status without a pong, got %v", err)
+	}
+	if status.Latency != 0 {
+		t.Errorf("Expected no latency, got %s", status.Latency)
+	}
+	if motd := status.MOTD(); motd != "Plain MOTD" {
+		t.Errorf("Expected MOTD %q, got %q", "Plain MOTD", motd)
+	}
+}
+
+func TestPing_Invalid(t *testing.T) {
+	addr, _ := newTestServer(t, "not json", false)
+
+	if _, err := Ping(context.Background(), addr); err == nil {
+		t.Error("Expected an error for an invalid status")
+	}
+
+	if _, err := Ping(context.Background(), "127.0.0.1:99999"); err == nil {
+		t.Error("Expected an error for an invalid port")
+	}
+}
+
+func TestVarInt(t *testing.T) {
+	for _, v := range []int32{0, 1, 127, 128, 25565, 2147483647, -1} {
+		var buf bytes.Buffer
+		writeVarInt(&buf, v)
+
+		got, err := readVarInt(&buf)
+		if err != nil || got != v {
+			t.Errorf("Round trip of %d gave %d (%v)", v, got, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	writeVarInt(&buf, -1)
+	if buf.Len() != 5 {
+		t.Errorf("Expected -1 to take 5 bytes, got %d", buf.Len())
+	}
+}
@@ -0,0 +1,260 @@
+// Package properties reads and edits Java properties files such as
+// server.properties.
+//
+// Files are edited in place: comments, blank lines and the order of keys
+// are kept, and new keys are appended at the end.
+package properties
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/internal/atomicfile"
+)
+
+// ServerFile is the name of a Minecraft server's properties file.
+const ServerFile = "server.properties"
+
+// Properties is a properties file. The zero value is an empty file.
+type Properties struct {
+	lines []line
+	index map[string]int // Key to position in lines
+}
+
+// line is a line of a properties file. Comments and blank lines have no key.
+type line struct {
+	raw   string
+	key   string
+	value string
+}
+
+// Parse parses the content of a properties file. Continuation lines are
+// joined; the key of each entry ends at the first unescaped '=', ':' or
+// whitespace.
+func Parse(data []byte) (*Properties, error) {
+	p := &Properties{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		raw := scanner.Text()
+
+		// A line ending in an odd number of backslashes continues on the next one.
+		logical := raw
+		for continues(logical) && scanner.Scan() {
+			next := scanner.Text()
+			raw += "\n" + next
+			logical = logical[:len(logical)-1] + strings.TrimLeft(next, " \t\f")
+		}
+
+		trimmed := strings.TrimLeft(logical, " \t\f")
+		if trimmed == "" || trimmed[0] == '#' || trimmed[0] == '!' {
+			p.lines = append(p.lines, line{raw: raw})
+			continue
+		}
+
+		key, value := splitEntry(trimmed)
+		p.add(line{raw: raw, key: unescape(key), value: unescape(value)})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read properties")
+	}
+
+	return p, nil
+}
+
+// Load reads a properties file.
+func Load(path string) (*Properties, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read properties file")
+	}
+
+	p, err := Parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	return p, nil
+}
+
+// Get returns the value of a key and whether it is set.
+func (p *Properties) Get(key string) (string, bool) {
+	i, ok := p.index[key]
+	if !ok {
+		return "", false
+	}
+
+	return p.lines[i].value, true
+}
+
+// Value returns the value of a key, or fallback if it is not set or empty.
+func (p *Properties) Value(key, fallback string) string {
+	if value, ok := p.Get(key); ok && value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// Set sets the value of a key, replacing its line or appending a new one.
+func (p *Properties) Set(key, value string) {
+	entry := line{raw: escape(key, true) + "=" + escape(value, false), key: key, value: value}
+
+	if i, ok := p.index[key]; ok {
+		p.lines[i] = entry
+		return
+	}
+
+	p.add(entry)
+}
+
+// Keys returns the keys in file order.
+func (p *Properties) Keys() []string {
+	var keys []string
+	for i, l := range p.lines {
+		if l.key != "" && p.index[l.key] == i {
+			keys = append(keys, l.key)
+		}
+	}
+
+	return keys
+}
+
+// Bytes returns the content of the file.
+func (p *Properties) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, l := range p.lines {
+		buf.WriteString(l.raw)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+// WriteFile atomically writes the file to path.
+func (p *Properties) WriteFile(path string) error {
+	return atomicfile.WriteFile(path, p.Bytes(), 0o644)
+}
+
+// add appends an entry. A repeated key refers to its last line, as the last
+// value wins when Java loads the file.
+func (p *Properties) add(l line) {
+	if p.index == nil {
+		p.index = make(map[string]int)
+	}
+
+	p.index[l.key] = len(p.lines)
+	p.lines = append(p.lines, l)
+}
+
+// continues reports whether a line ends in an unescaped backslash.
+func continues(s string) bool {
+	n := 0
+	for i := len(s) - 1; i >= 0 && s[i] == '\\'; i-- {
+		n++
+	}
+
+	return n%2 == 1
+}
+
+// splitEntry splits an entry into its escaped key and value.
+func splitEntry(s string) (string, string) {
+	end := len(s)
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == '=' || s[i] == ':' || s[i] == ' ' || s[i] == '\t' || s[i] == '\f' {
+			end = i
+			break
+		}
+	}
+
+	key, rest := s[:end], strings.TrimLeft(s[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	return key, rest
+}
+
+// unescape resolves the escape sequences of a key or value.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i == len(s)-1 {
+			b.WriteByte(c)
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 16); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String()
+}
+
+// escape escapes a key or value for writing. Keys also escape separators,
+// values only their leading whitespace.
+func escape(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			if key || (i == 0 && (r == '#' || r == '!')) {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		case ' ':
+			if key || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
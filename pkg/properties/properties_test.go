@@ -0,0 +1,107 @@
+package properties
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const serverProperties = `#Minecraft server properties
+#Fri Oct 17 12:00:00 UTC 2026
+enable-rcon=false
+motd=A Minecraft Server\: §aPaper
+level-name = world
+rcon.password=
+server-port:25565
+  long-value = first \
+      second
+max-players=20
+max-players=30
+`
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(serverProperties))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"motd":          "A Minecraft Server: §aPaper",
+		"level-name":    "world",
+		"rcon.password": "",
+		"server-port":   "25565",
+		"long-value":    "first second",
+		"max-players":   "30",
+	} {
+		if got, ok := p.Get(key); !ok || got != want {
+			t.Errorf("Expected %s=%q, got %q (set: %v)", key, want, got, ok)
+		}
+	}
+
+	if got := p.Value("rcon.password", "none"); got != "none" {
+		t.Errorf("Expected the fallback for an empty value, got %q", got)
+	}
+
+	want := []string{"enable-rcon", "motd", "level-name", "rcon.password", "server-port", "long-value", "max-players"}
+	if keys := p.Keys(); !slices.Equal(keys, want) {
+		t.Errorf("Expected keys %v, got %v", want, keys)
+	}
+
+	// Unchanged files are written back as they were
+	if string(p.Bytes()) != serverProperties {
+		t.Errorf("Expected an unchanged file, got\n%s", p.Bytes())
+	}
+}
+
+func TestSet(t *testing.T) {
+	p, err := Parse([]byte(serverProperties))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	p.Set("server-port", "25566")
+	p.Set("motd", "Lobby\nline two")
+	p.Set("white-list", "true")
+
+	path := filepath.Join(t.TempDir(), ServerFile)
+	if err := p.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `#Minecraft server properties
+#Fri Oct 17 12:00:00 UTC 2026
+enable-rcon=false
+motd=Lobby\nline two
+level-name = world
+rcon.password=
+server-port=25566
+  long-value = first \
+      second
+max-players=20
+max-players=30
+white-list=true
+`
+	if string(data) != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, data)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if motd, _ := loaded.Get("motd"); motd != "Lobby\nline two" {
+		t.Errorf("Expected the value to round-trip, got %q", motd)
+	}
+
+	var empty Properties
+	empty.Set("key with:separators", " leading space")
+	if got := string(empty.Bytes()); got != "key\\ with\\:separators=\\ leading space\n" {
+		t.Errorf("Expected escaped key and value, got %q", got)
+	}
+}
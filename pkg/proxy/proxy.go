@@ -0,0 +1,496 @@
+// Package proxy implements a caching reverse proxy for the fill API.
+//
+// The proxy serves the same /v3/... endpoints as the upstream API. Metadata
+// responses are cached with a TTL and download URLs in them are rewritten
+// to point at the proxy, which downloads each jar once, verifies it and
+// keeps it on disk under its SHA256 checksum.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+const (
+	// DefaultMetadataTTL is the default time metadata responses are cached.
+	DefaultMetadataTTL = 5 * time.Minute
+	// ObjectsPath is the path prefix under which cached jars are served.
+	ObjectsPath = "/objects/"
+	// HealthPath is the path of the health endpoint.
+	HealthPath = "/healthz"
+	// StatsPath is the path of the cache statistics endpoint.
+	StatsPath = "/stats"
+)
+
+// maxSources bounds the number of upstream download URLs remembered for
+// jars that are not cached yet. They are recorded again whenever build
+// metadata is served, so forgetting one only matters for a jar requested
+// without its metadata.
+const maxSources = 10000
+
+// maxMetadataEntries bounds the number of cached metadata responses.
+const maxMetadataEntries = 1000
+
+// Stats holds cache statistics of a Server.
+type Stats struct {
+	MetadataHits   uint64 `json:"metadata_hits"`
+	MetadataMisses uint64 `json:"metadata_misses"`
+	JarHits        uint64 `json:"jar_hits"`
+	JarMisses      uint64 `json:"jar_misses"`
+	UpstreamErrors uint64 `json:"upstream_errors"`
+	Jars           int    `json:"jars"`
+	JarBytes       int64  `json:"jar_bytes"`
+}
+
+// Server is an http.Handler proxying and caching the fill API.
+type Server struct {
+	Upstream   string       // Base URL of the upstream API
+	PublicURL  string       // Base URL of the proxy used in rewritten download URLs (empty means derive from the request)
+	HTTPClient *http.Client // Client used for upstream requests
+	Cache      api.Cache    // Cache for metadata responses
+	JarDir     string       // Directory holding cached jars
+
+	// TrustForwarded derives download URLs from the Host and
+	// X-Forwarded-Proto headers when PublicURL is empty. Only set it behind
+	// a reverse proxy that overwrites them; otherwise the address the
+	// request was received on is used.
+	TrustForwarded bool
+
+	mux *http.ServeMux
+
+	mu      sync.Mutex
+	sources map[string]string           // Upstream download URL by checksum, for jars not cached yet
+	pending map[string]*pendingDownload // Jar downloads in progress by checksum
+
+	metadataHits   atomic.Uint64
+	metadataMisses atomic.Uint64
+	jarHits        atomic.Uint64
+	jarMisses      atomic.Uint64
+	upstreamErrors atomic.Uint64
+}
+
+// New creates a proxy for the upstream API caching metadata for ttl and
+// jars in jarDir.
+func New(upstream, jarDir string, ttl time.Duration) *Server {
+	// Only waiting for response headers is bounded: an overall timeout would
+	// cut off large jar downloads on slow links.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = api.DefaultTimeout
+
+	s := &Server{
+		Upstream:   strings.TrimSuffix(upstream, "/"),
+		HTTPClient: &http.Client{Transport: transport},
+		Cache:      api.NewMemoryCache(ttl).WithMaxEntries(maxMetadataEntries),
+		JarDir:     jarDir,
+		sources:    make(map[string]string),
+		pending:    make(map[string]*pendingDownload),
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("GET /v3/", s.handleMetadata)
+	s.mux.HandleFunc("GET "+ObjectsPath+"{sha256}/{name}", s.handleObject)
+	s.mux.HandleFunc("GET "+HealthPath, s.handleHealth)
+	s.mux.HandleFunc("GET "+StatsPath, s.handleStats)
+
+	return s
+}
+
+// WithPublicURL sets the base URL used in rewritten download URLs.
+func (s *Server) WithPublicURL(publicURL string) *Server {
+	s.PublicURL = strings.TrimSuffix(publicURL, "/")
+	return s
+}
+
+// WithTrustForwarded sets whether download URLs are derived from the Host
+// and X-Forwarded-Proto headers of requests.
+func (s *Server) WithTrustForwarded(trust bool) *Server {
+	s.TrustForwarded = trust
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Stats returns the current cache statistics.
+func (s *Server) Stats() Stats {
+	stats := Stats{
+		MetadataHits:   s.metadataHits.Load(),
+		MetadataMisses: s.metadataMisses.Load(),
+		JarHits:        s.jarHits.Load(),
+		JarMisses:      s.jarMisses.Load(),
+		UpstreamErrors: s.upstreamErrors.Load(),
+	}
+
+	entries, err := os.ReadDir(s.JarDir)
+	if err != nil {
+		return stats
+	}
+	for _, entry := range entries {
+		if !isSHA256(entry.Name()) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			stats.Jars++
+			stats.JarBytes += info.Size()
+		}
+	}
+
+	return stats
+}
+
+// handleMetadata serves an API response from the cache or upstream.
+func (s *Server) handleMetadata(w http.ResponseWriter, r *http.Request) {
+	key := metadataKey(r.URL)
+
+	data, ok := s.Cache.Get(key)
+	if ok {
+		s.metadataHits.Add(1)
+	} else {
+		s.metadataMisses.Add(1)
+
+		ctx, cancel := context.WithTimeout(r.Context(), api.DefaultTimeout)
+		defer cancel()
+
+		resp, err := s.fetch(ctx, s.Upstream+key)
+		if err != nil {
+			s.upstreamErrors.Add(1)
+			http.Error(w, errors.UnwrapAll(err).Error(), http.StatusBadGateway)
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		data, err = io.ReadAll(resp.Body)
+		if err != nil {
+			s.upstreamErrors.Add(1)
+			http.Error(w, "failed to read upstream response", http.StatusBadGateway)
+			return
+		}
+
+		// Errors are passed through but never cached.
+		if resp.StatusCode != http.StatusOK {
+			w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+			w.WriteHeader(resp.StatusCode)
+			_, _ = w.Write(data)
+			return
+		}
+
+		s.Cache.Set(key, data)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(s.rewrite(data, s.baseURL(r)))
+}
+
+// metadataKey returns the path and the query parameters the API knows of a
+// metadata request URL, so clients cannot fill the cache with variations of
+// the same request. It is also the request sent upstream.
+func metadataKey(u *url.URL) string {
+	key := u.EscapedPath()
+	if channels := u.Query()["channel"]; len(channels) > 0 {
+		key += "?" + url.Values{"channel": channels}.Encode()
+	}
+
+	return key
+}
+
+// rewrite points the download URLs of a response at the proxy and records
+// their upstream location. Responses that are not JSON are returned as is.
+func (s *Server) rewrite(data []byte, baseURL string) []byte {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return data
+	}
+
+	if !s.rewriteDownloads(doc, baseURL) {
+		return data
+	}
+
+	rewritten, err := json.Marshal(doc)
+	if err != nil {
+		return data
+	}
+
+	return rewritten
+}
+
+// rewriteDownloads walks a decoded JSON document and rewrites every object
+// shaped like a DownloadV3. It reports whether anything was rewritten.
+func (s *Server) rewriteDownloads(node any, baseURL string) bool {
+	changed := false
+
+	switch v := node.(type) {
+	case map[string]any:
+		if s.rewriteDownload(v, baseURL) {
+			return true
+		}
+		for _, child := range v {
+			changed = s.rewriteDownloads(child, baseURL) || changed
+		}
+	case []any:
+		for _, child := range v {
+			changed = s.rewriteDownloads(child, baseURL) || changed
+		}
+	}
+
+	return changed
+}
+
+// rewriteDownload rewrites a single download object.
+func (s *Server) rewriteDownload(download map[string]any, baseURL string) bool {
+	name, _ := download["name"].(string)
+	source, _ := download["url"].(string)
+	checksums, _ := download["checksums"].(map[string]any)
+	sum, _ := checksums["sha256"].(string)
+
+	if name == "" || source == "" || !isSHA256(sum) {
+		return false
+	}
+
+	sum = strings.ToLower(sum)
+
+	s.mu.Lock()
+	if _, ok := s.sources[sum]; !ok && len(s.sources) >= maxSources {
+		for old := range s.sources {
+			delete(s.sources, old)
+			break
+		}
+	}
+	s.sources[sum] = source
+	s.mu.Unlock()
+
+	download["url"] = baseURL + ObjectsPath + sum + "/" + name
+
+	return true
+}
+
+// handleObject serves a jar by checksum, downloading it on first use.
+func (s *Server) handleObject(w http.ResponseWriter, r *http.Request) {
+	sum := strings.ToLower(r.PathValue("sha256"))
+	if !isSHA256(sum) {
+		http.NotFound(w, r)
+		return
+	}
+
+	path, err := s.object(r.Context(), sum)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "unknown object; request its build metadata first", http.StatusNotFound)
+			return
+		}
+
+		s.upstreamErrors.Add(1)
+		http.Error(w, errors.UnwrapAll(err).Error(), http.StatusBadGateway)
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "failed to open cached jar", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "failed to stat cached jar", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/java-archive")
+	http.ServeContent(w, r, r.PathValue("name"), info.ModTime(), file)
+}
+
+// pendingDownload is a jar download other requests for the jar wait for.
+type pendingDownload struct {
+	done chan struct{}
+}
+
+// object returns the path of the cached jar with the given checksum,
+// downloading and verifying it if it is not cached yet. Concurrent requests
+// for the same jar wait for a single download and retry it if it fails.
+func (s *Server) object(ctx context.Context, sum string) (string, error) {
+	path := filepath.Join(s.JarDir, sum)
+
+	for {
+		s.mu.Lock()
+		pending, ok := s.pending[sum]
+		if ok {
+			s.mu.Unlock()
+
+			select {
+			case <-pending.done:
+				continue
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+
+		if _, err := os.Stat(path); err == nil {
+			delete(s.sources, sum)
+			s.mu.Unlock()
+			s.jarHits.Add(1)
+			return path, nil
+		}
+
+		source, ok := s.sources[sum]
+		if !ok {
+			s.mu.Unlock()
+			return "", errors.Wrapf(os.ErrNotExist, "no known source for %s", sum)
+		}
+
+		pending = &pendingDownload{done: make(chan struct{})}
+		s.pending[sum] = pending
+		s.mu.Unlock()
+
+		s.jarMisses.Add(1)
+		err := s.download(ctx, source, sum, path)
+
+		s.mu.Lock()
+		delete(s.pending, sum)
+		if err == nil {
+			delete(s.sources, sum)
+		}
+		s.mu.Unlock()
+		close(pending.done)
+
+		if err != nil {
+			return "", err
+		}
+
+		return path, nil
+	}
+}
+
+// download fetches a jar, verifies its checksum and stores it atomically.
+func (s *Server) download(ctx context.Context, source, sum, path string) error {
+	resp, err := s.fetch(ctx, source)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Newf("upstream returned non-OK status: %d", resp.StatusCode)
+	}
+
+	if err := os.MkdirAll(s.JarDir, 0o755); err != nil {
+		return errors.Wrap(err, "failed to create jar directory")
+	}
+
+	tmp, err := os.CreateTemp(s.JarDir, ".download-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary file")
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		_ = tmp.Close()
+		return errors.Wrap(err, "failed to download jar")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write jar")
+	}
+
+	actual, err := api.FileSHA256(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if actual != sum {
+		return errors.Newf("checksum mismatch: expected %s, got %s", sum, actual)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrap(err, "failed to store jar")
+	}
+
+	return nil
+}
+
+// fetch sends a GET request upstream.
+func (s *Server) fetch(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "upstream request failed")
+	}
+
+	return resp, nil
+}
+
+// handleHealth reports that the proxy is up.
+func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// handleStats serves the cache statistics as JSON.
+func (s *Server) handleStats(w http.ResponseWriter, _ *http.Request) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(s.Stats()); err != nil {
+		http.Error(w, "failed to encode stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// baseURL returns the base URL clients use to reach the proxy: PublicURL,
+// the forwarded headers if they are trusted, or the local address the
+// request was received on.
+func (s *Server) baseURL(r *http.Request) string {
+	if s.PublicURL != "" {
+		return s.PublicURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if s.TrustForwarded {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		return scheme + "://" + r.Host
+	}
+
+	host := r.Host
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		host = addr.String()
+	}
+
+	return scheme + "://" + host
+}
+
+// isSHA256 reports whether s looks like a hex-encoded SHA256 checksum.
+func isSHA256(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
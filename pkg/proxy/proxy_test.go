@@ -0,0 +1,263 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+var testJar = []byte("paper 1.21.11 build 74")
+
+func testJarSum() string {
+	sum := sha256.Sum256(testJar)
+	return hex.EncodeToString(sum[:])
+}
+
+// newUpstream serves a single paper build and its jar, counting requests by path.
+func newUpstream(t *testing.T, requests map[string]int, mu *sync.Mutex) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/v3/projects/paper/versions/1.21.11/builds/latest":
+			build := api.BuildV3Response{
+				ID:      74,
+				Channel: "STABLE",
+				Downloads: map[string]api.DownloadV3{
+					"server:default": {
+						Name:      "paper-1.21.11-74.jar",
+						URL:       server.URL + "/jars/paper-1.21.11-74.jar",
+						Checksums: api.ChecksumsV3{SHA256: testJarSum()},
+						Size:      int64(len(testJar)),
+					},
+				},
+			}
+			if err := json.NewEncoder(w).Encode(build); err != nil {
+				t.Fatalf("Failed to encode response: %v", err)
+			}
+		case "/jars/paper-1.21.11-74.jar":
+			_, _ = w.Write(testJar)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+		}
+	}))
+
+	return server
+}
+
+func TestServer(t *testing.T) {
+	requests := make(map[string]int)
+	var mu sync.Mutex
+	upstream := newUpstream(t, requests, &mu)
+	defer upstream.Close()
+
+	proxy := New(upstream.URL, t.TempDir(), DefaultMetadataTTL)
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	client := api.NewClient().WithBaseURL(server.URL)
+	ctx := context.Background()
+
+	for range 2 {
+		build, err := client.GetLatestBuildV3(ctx, "paper", "1.21.11")
+		if err != nil {
+			t.Fatalf("GetLatestBuildV3 failed: %v", err)
+		}
+
+		download, ok := build.GetDownload(api.DefaultDownloadKey)
+		if !ok {
+			t.Fatal("Expected default download")
+		}
+		expectedURL := server.URL + ObjectsPath + testJarSum() + "/paper-1.21.11-74.jar"
+		if download.URL != expectedURL {
+			t.Errorf("Expected download URL %s, got %s", expectedURL, download.URL)
+		}
+
+		body, err := client.DownloadBuild(ctx, download.URL)
+		if err != nil {
+			t.Fatalf("DownloadBuild failed: %v", err)
+		}
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			t.Fatalf("Failed to read jar: %v", err)
+		}
+		if string(data) != string(testJar) {
+			t.Errorf("Unexpected jar content: %q", data)
+		}
+	}
+
+	if n := requests["/v3/projects/paper/versions/1.21.11/builds/latest"]; n != 1 {
+		t.Errorf("Expected 1 upstream metadata request, got %d", n)
+	}
+	if n := requests["/jars/paper-1.21.11-74.jar"]; n != 1 {
+		t.Errorf("Expected 1 upstream jar request, got %d", n)
+	}
+
+	stats := proxy.Stats()
+	expected := Stats{MetadataHits: 1, MetadataMisses: 1, JarHits: 1, JarMisses: 1, Jars: 1, JarBytes: int64(len(testJar))}
+	if stats != expected {
+		t.Errorf("Expected stats %+v, got %+v", expected, stats)
+	}
+
+	if len(proxy.sources) != 0 || len(proxy.pending) != 0 {
+		t.Errorf("Expected nothing to be remembered for a stored jar, got %v %v", proxy.sources, proxy.pending)
+	}
+
+	// Unknown query parameters neither reach upstream nor add cache entries.
+	for _, query := range []string{"?a=1", "?a=2&b=3"} {
+		resp, err := http.Get(server.URL + "/v3/projects/paper/versions/1.21.11/builds/latest" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+	if n := requests["/v3/projects/paper/versions/1.21.11/builds/latest"]; n != 1 {
+		t.Errorf("Expected query variations to be served from the cache, got %d upstream requests", n)
+	}
+}
+
+func TestMetadataKey(t *testing.T) {
+	tests := map[string]string{
+		"/v3/projects/paper":     "/v3/projects/paper",
+		"/v3/projects/paper?x=1": "/v3/projects/paper",
+		"/v3/projects/paper/versions/1.21.11/builds?channel=STABLE&x=1":          "/v3/projects/paper/versions/1.21.11/builds?channel=STABLE",
+		"/v3/projects/paper/versions/1.21.11/builds?channel=BETA&channel=STABLE": "/v3/projects/paper/versions/1.21.11/builds?channel=BETA&channel=STABLE",
+	}
+	for raw, want := range tests {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := metadataKey(u); got != want {
+			t.Errorf("metadataKey(%s) = %s, want %s", raw, got, want)
+		}
+	}
+}
+
+func TestNew_Timeouts(t *testing.T) {
+	proxy := New("http://upstream", t.TempDir(), DefaultMetadataTTL)
+	if proxy.HTTPClient.Timeout != 0 {
+		t.Errorf("Expected no overall timeout cutting off jar downloads, got %v", proxy.HTTPClient.Timeout)
+	}
+	if transport, ok := proxy.HTTPClient.Transport.(*http.Transport); !ok || transport.ResponseHeaderTimeout != api.DefaultTimeout {
+		t.Errorf("Expected a response header timeout of %v", api.DefaultTimeout)
+	}
+}
+
+func TestServer_BaseURL(t *testing.T) {
+	requests := make(map[string]int)
+	var mu sync.Mutex
+	upstream := newUpstream(t, requests, &mu)
+	defer upstream.Close()
+
+	proxy := New(upstream.URL, t.TempDir(), DefaultMetadataTTL)
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	downloadURL := func() string {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/v3/projects/paper/versions/1.21.11/builds/latest", http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = "evil.example.com"
+		req.Header.Set("X-Forwarded-Proto", "https")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		var build api.BuildV3Response
+		if err := json.NewDecoder(resp.Body).Decode(&build); err != nil {
+			t.Fatalf("Failed to decode build: %v", err)
+		}
+
+		return build.Downloads[api.DefaultDownloadKey].URL
+	}
+
+	if got := downloadURL(); !strings.HasPrefix(got, server.URL+ObjectsPath) {
+		t.Errorf("Expected the forwarded headers to be ignored, got %s", got)
+	}
+
+	proxy.WithTrustForwarded(true)
+	if got := downloadURL(); !strings.HasPrefix(got, "https://evil.example.com"+ObjectsPath) {
+		t.Errorf("Expected the trusted headers to be used, got %s", got)
+	}
+
+	proxy.WithPublicURL("https://papermc.internal/")
+	if got := downloadURL(); !strings.HasPrefix(got, "https://papermc.internal"+ObjectsPath) {
+		t.Errorf("Expected the public URL to be used, got %s", got)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	requests := make(map[string]int)
+	var mu sync.Mutex
+	upstream := newUpstream(t, requests, &mu)
+	defer upstream.Close()
+
+	server := httptest.NewServer(New(upstream.URL, t.TempDir(), DefaultMetadataTTL))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{"upstream error passed through", "/v3/projects/unknown", http.StatusNotFound, "not found"},
+		{"unknown object", ObjectsPath + strings.Repeat("a", 64) + "/x.jar", http.StatusNotFound, "unknown object"},
+		{"invalid checksum", ObjectsPath + "abc/x.jar", http.StatusNotFound, ""},
+		{"health", HealthPath, http.StatusOK, "ok"},
+		{"stats", StatsPath, http.StatusOK, `"metadata_misses": 1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			if !strings.Contains(string(body), tt.body) {
+				t.Errorf("Expected body to contain %q, got %q", tt.body, body)
+			}
+		})
+	}
+
+	// Errors must not be cached.
+	resp, err := http.Get(server.URL + "/v3/projects/unknown")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if n := requests["/v3/projects/unknown"]; n != 2 {
+		t.Errorf("Expected 2 upstream requests, got %d", n)
+	}
+}
@@ -0,0 +1,271 @@
+// Package rcon is a client for the RCON protocol of Minecraft servers,
+// used to run console commands on a running server.
+//
+// Every packet is a little-endian length, request ID and type followed by
+// a NUL-terminated body. Responses longer than one packet are split by the
+// server; Command collects them by following each command with an invalid
+// request, the end marker, which the server answers only after the
+// command's response.
+package rcon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/properties"
+)
+
+const (
+	// DefaultPort is the default RCON port of Minecraft servers.
+	DefaultPort = 25575
+	// DefaultTimeout bounds dialing and every exchange with the server.
+	DefaultTimeout = 10 * time.Second
+	// MaxPayload is the largest body the server accepts in a request.
+	MaxPayload = 1446
+)
+
+// Packet types.
+const (
+	typeResponse int32 = 0
+	typeCommand  int32 = 2
+	typeLogin    int32 = 3
+)
+
+// maxPacket bounds the size of packets read from the server.
+const maxPacket = 1 << 16
+
+// ErrAuth is returned by Dial when the server rejects the password.
+var ErrAuth = errors.New("RCON authentication failed")
+
+// Client is an authenticated RCON connection. It is safe for concurrent
+// use; commands are run one at a time.
+type Client struct {
+	addr    string
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	mu     sync.Mutex
+	nextID int32
+}
+
+// Dial connects to the RCON server at addr and logs in with password.
+func Dial(ctx context.Context, addr, password string) (*Client, error) {
+	dialer := net.Dialer{Timeout: DefaultTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to %s", addr)
+	}
+
+	c := &Client{addr: addr, conn: conn, reader: bufio.NewReader(conn), timeout: DefaultTimeout, nextID: 1}
+
+	if err := c.login(ctx, password); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Addr returns the address of the server.
+func (c *Client) Addr() string {
+	return c.addr
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// login authenticates the connection. Servers answer with the request ID,
+// or -1 if the password is wrong; some send an empty response first.
+func (c *Client) login(ctx context.Context, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	defer c.deadline(ctx)()
+
+	id := c.id()
+	if err := c.write(id, typeLogin, password); err != nil {
+		return err
+	}
+
+	for {
+		respID, typ, _, err := c.read()
+		if err != nil {
+			return errors.Wrap(err, "failed to read login response")
+		}
+
+		if typ != typeCommand {
+			continue
+		}
+		if respID == -1 {
+			return ErrAuth
+		}
+		if respID != id {
+			return errors.Newf("unexpected login response ID %d", respID)
+		}
+
+		return nil
+	}
+}
+
+// Command runs a console command and returns its output.
+func (c *Client) Command(ctx context.Context, command string) (string, error) {
+	if len(command) > MaxPayload {
+		return "", errors.Newf("command is %d bytes long, the limit is %d", len(command), MaxPayload)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	defer c.deadline(ctx)()
+
+	id := c.id()
+	if err := c.write(id, typeCommand, command); err != nil {
+		return "", err
+	}
+
+	// The end marker is only sent once the first response arrived: the
+	// vanilla server reads a single packet per read and drops the connection
+	// when two packets arrive together.
+	var (
+		out  bytes.Buffer
+		end  int32
+		sent bool
+	)
+	for {
+		respID, _, body, err := c.read()
+		if err != nil {
+			return out.String(), errors.Wrapf(err, "failed to read response to %q", command)
+		}
+
+		switch {
+		case respID == id:
+			out.WriteString(body)
+			if !sent {
+				end = c.id()
+				if err := c.write(end, typeResponse, ""); err != nil {
+					return out.String(), err
+				}
+				sent = true
+			}
+		case sent && respID == end:
+			return out.String(), nil
+		}
+	}
+}
+
+// id returns the next request ID.
+func (c *Client) id() int32 {
+	id := c.nextID
+	c.nextID++
+	if c.nextID <= 0 {
+		c.nextID = 1
+	}
+
+	return id
+}
+
+// deadline applies the timeout and the deadline of ctx to the connection
+// and returns a function clearing it.
+func (c *Client) deadline(ctx context.Context) func() {
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = c.conn.SetDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() { _ = c.conn.SetDeadline(time.Now()) })
+
+	return func() {
+		stop()
+		_ = c.conn.SetDeadline(time.Time{})
+	}
+}
+
+// write sends a packet.
+func (c *Client) write(id, typ int32, body string) error {
+	packet := make([]byte, 12, 14+len(body))
+	binary.LittleEndian.PutUint32(packet[0:], uint32(10+len(body)))
+	binary.LittleEndian.PutUint32(packet[4:], uint32(id))
+	binary.LittleEndian.PutUint32(packet[8:], uint32(typ))
+	packet = append(packet, body...)
+	packet = append(packet, 0, 0)
+
+	if _, err := c.conn.Write(packet); err != nil {
+		return errors.Wrap(err, "failed to send RCON packet")
+	}
+
+	return nil
+}
+
+// read receives a packet.
+func (c *Client) read() (int32, int32, string, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return 0, 0, "", err
+	}
+
+	length := int32(binary.LittleEndian.Uint32(header[:]))
+	if length < 10 || length > maxPacket {
+		return 0, 0, "", errors.Newf("invalid RCON packet length %d", length)
+	}
+
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, packet); err != nil {
+		return 0, 0, "", err
+	}
+
+	id := int32(binary.LittleEndian.Uint32(packet[0:]))
+	typ := int32(binary.LittleEndian.Uint32(packet[4:]))
+	body := bytes.TrimRight(packet[8:], "\x00")
+
+	return id, typ, string(body), nil
+}
+
+// Config is the RCON address and password of a server.
+type Config struct {
+	Address  string
+	Password string
+}
+
+// ServerConfig reads the RCON settings from the server.properties of a
+// server directory. The server is expected on server-ip, or on localhost if
+// it listens on every interface.
+func ServerConfig(dir string) (*Config, error) {
+	props, err := properties.Load(filepath.Join(dir, properties.ServerFile))
+	if err != nil {
+		return nil, err
+	}
+
+	if props.Value("enable-rcon", "false") != "true" {
+		return nil, errors.Newf("RCON is not enabled in %s (set enable-rcon=true)", filepath.Join(dir, properties.ServerFile))
+	}
+
+	password := props.Value("rcon.password", "")
+	if password == "" {
+		return nil, errors.Newf("no rcon.password set in %s", filepath.Join(dir, properties.ServerFile))
+	}
+
+	port, err := strconv.Atoi(props.Value("rcon.port", strconv.Itoa(DefaultPort)))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid rcon.port")
+	}
+
+	host := props.Value("server-ip", "127.0.0.1")
+	if host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	return &Config{Address: net.JoinHostPort(host, strconv.Itoa(port)), Password: password}, nil
+}
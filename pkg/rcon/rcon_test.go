@@ -0,0 +1,234 @@
+package rcon
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// testServer is an in-process RCON server behaving like Minecraft's: it
+// splits long responses into 4096-byte packets, answers unknown request
+// types with an error message and closes the connection on stop.
+type testServer struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newTestServer(t *testing.T, password string) *testServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{listener: listener, password: password}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *testServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *testServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	// Like the vanilla server, read one packet per read and drop the
+	// connection when the read does not contain exactly one packet.
+	buf := make([]byte, 1460)
+	authed := false
+
+	for {
+		n, err := conn.Read(buf)
+		if err != nil || n < 14 {
+			return
+		}
+		length := int(binary.LittleEndian.Uint32(buf))
+		if length != n-4 {
+			return
+		}
+		packet := buf[4:n]
+
+		id := int32(binary.LittleEndian.Uint32(packet[0:]))
+		typ := int32(binary.LittleEndian.Uint32(packet[4:]))
+		body := strings.TrimRight(string(packet[8:]), "\x00")
+
+		switch {
+		case typ == typeLogin:
+			authed = body == s.password
+			if !authed {
+				id = -1
+			}
+			s.send(conn, id, typeCommand, "")
+		case typ == typeCommand && authed:
+			s.mu.Lock()
+			s.commands = append(s.commands, body)
+			s.mu.Unlock()
+
+			switch {
+			case body == "stop":
+				_ = s.listener.Close()
+				return
+			case strings.HasPrefix(body, "repeat "):
+				response := strings.Repeat("x", 10000)
+				for len(response) > 4096 {
+					s.send(conn, id, typeResponse, response[:4096])
+					response = response[4096:]
+				}
+				s.send(conn, id, typeResponse, response)
+			default:
+				s.send(conn, id, typeResponse, "ran "+body)
+			}
+		default:
+			s.send(conn, id, typeResponse, fmt.Sprintf("Unknown request %x", typ))
+		}
+	}
+}
+
+func (s *testServer) send(conn net.Conn, id, typ int32, body string) {
+	packet := binary.LittleEndian.AppendUint32(nil, uint32(10+len(body)))
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(id))
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(typ))
+	packet = append(packet, body...)
+	packet = append(packet, 0, 0)
+	_, _ = conn.Write(packet)
+}
+
+func TestClient(t *testing.T) {
+	server := newTestServer(t, "secret")
+	ctx := context.Background()
+
+	if _, err := Dial(ctx, server.addr(), "wrong"); !errors.Is(err, ErrAuth) {
+		t.Errorf("Expected ErrAuth for a wrong password, got %v", err)
+	}
+
+	client, err := Dial(ctx, server.addr(), "secret")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	out, err := client.Command(ctx, "list")
+	if err != nil || out != "ran list" {
+		t.Errorf("Expected the command output, got %q (%v)", out, err)
+	}
+
+	// Responses split over several packets are joined
+	out, err = client.Command(ctx, "repeat x")
+	if err != nil || len(out) != 10000 {
+		t.Errorf("Expected a 10000-byte response, got %d bytes (%v)", len(out), err)
+	}
+
+	// Commands stay in sync after a split response
+	if out, err := client.Command(ctx, "seed"); err != nil || out != "ran seed" {
+		t.Errorf("Expected the next command's output, got %q (%v)", out, err)
+	}
+
+	if _, err := client.Command(ctx, strings.Repeat("a", MaxPayload+1)); err == nil {
+		t.Error("Expected an error for an oversized command")
+	}
+}
+
+func TestSequence(t *testing.T) {
+	server := newTestServer(t, "secret")
+	ctx := context.Background()
+
+	client, err := Dial(ctx, server.addr(), "secret")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	var slept time.Duration
+	seq := Sequence{
+		Countdown: 45 * time.Second,
+		Sleep: func(_ context.Context, d time.Duration) error {
+			slept += d
+			return nil
+		},
+	}
+
+	if err := seq.Run(ctx, client); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	want := []string{
+		"say Server restarting for an update in 45s",
+		"say Server restarting for an update in 30s",
+		"say Server restarting for an update in 10s",
+		"say Server restarting for an update in 5s",
+		"say Server restarting for an update in 4s",
+		"say Server restarting for an update in 3s",
+		"say Server restarting for an update in 2s",
+		"say Server restarting for an update in 1s",
+		"save-all flush",
+		"stop",
+	}
+	if got := server.received(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected commands\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if slept != 45*time.Second {
+		t.Errorf("Expected the countdown to take 45s, got %s", slept)
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.properties")
+
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("enable-rcon=false\nrcon.password=secret\n")
+	if _, err := ServerConfig(dir); err == nil {
+		t.Error("Expected an error when RCON is disabled")
+	}
+
+	write("enable-rcon=true\nrcon.password=\n")
+	if _, err := ServerConfig(dir); err == nil {
+		t.Error("Expected an error without a password")
+	}
+
+	write("enable-rcon=true\nrcon.password=secret\nserver-ip=\n")
+	if cfg, err := ServerConfig(dir); err != nil || cfg.Address != "127.0.0.1:25575" || cfg.Password != "secret" {
+		t.Errorf("Expected the default address, got %+v (%v)", cfg, err)
+	}
+
+	write("enable-rcon=true\nrcon.password=secret\nrcon.port=25580\nserver-ip=10.0.0.5\n")
+	if cfg, err := ServerConfig(dir); err != nil || cfg.Address != "10.0.0.5:25580" {
+		t.Errorf("Expected the configured address, got %+v (%v)", cfg, err)
+	}
+}
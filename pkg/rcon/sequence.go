@@ -0,0 +1,157 @@
+package rcon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// DefaultCountdown is how long players are warned before the server stops.
+	DefaultCountdown = time.Minute
+	// DefaultMessage is the broadcast announcing the stop; %s is the time left.
+	DefaultMessage = "Server restarting for an update in %s"
+	// DefaultStopTimeout is how long to wait for the server to stop.
+	DefaultStopTimeout = 2 * time.Minute
+)
+
+// DefaultCommands save the worlds and stop the server.
+var DefaultCommands = []string{"save-all flush", "stop"}
+
+// warnings are the times left at which the countdown is broadcast.
+var warnings = []time.Duration{
+	30 * time.Minute, 15 * time.Minute, 10 * time.Minute, 5 * time.Minute, 2 * time.Minute, time.Minute,
+	30 * time.Second, 10 * time.Second, 5 * time.Second, 4 * time.Second, 3 * time.Second, 2 * time.Second, time.Second,
+}
+
+// Sequence stops a server gracefully: it broadcasts a countdown, then runs
+// the commands and waits until the server no longer accepts connections.
+type Sequence struct {
+	Countdown   time.Duration // Zero skips the countdown
+	Message     string        // Broadcast format; %s is the time left (defaults to DefaultMessage)
+	Broadcast   string        // Command broadcasting a message (defaults to say)
+	Commands    []string      // Commands run after the countdown (defaults to DefaultCommands)
+	StopTimeout time.Duration // Time to wait for the server to go down (defaults to DefaultStopTimeout)
+
+	// Sleep waits between announcements; tests replace it.
+	Sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Run runs the sequence on a server. The server closes the connection when
+// it stops, so a lost or unanswered connection after the last command is
+// not an error.
+func (s Sequence) Run(ctx context.Context, c *Client) error {
+	s.setDefaults()
+
+	remaining := s.Countdown
+	if remaining > 0 {
+		if err := s.announce(ctx, c, remaining); err != nil {
+			return err
+		}
+
+		for _, at := range warnings {
+			if at >= remaining {
+				continue
+			}
+
+			if err := s.Sleep(ctx, remaining-at); err != nil {
+				return err
+			}
+			remaining = at
+
+			if err := s.announce(ctx, c, remaining); err != nil {
+				return err
+			}
+		}
+
+		if err := s.Sleep(ctx, remaining); err != nil {
+			return err
+		}
+	}
+
+	for i, command := range s.Commands {
+		_, err := c.Command(ctx, command)
+		if err != nil && !(i == len(s.Commands)-1 && stopping(err)) {
+			return errors.Wrapf(err, "failed to run %q", command)
+		}
+	}
+
+	return s.waitStopped(ctx, c.Addr())
+}
+
+// announce broadcasts the time left.
+func (s Sequence) announce(ctx context.Context, c *Client, remaining time.Duration) error {
+	message := fmt.Sprintf(s.Message, remaining)
+	if _, err := c.Command(ctx, s.Broadcast+" "+message); err != nil {
+		return errors.Wrap(err, "failed to broadcast countdown")
+	}
+
+	return nil
+}
+
+// waitStopped waits until the server refuses connections.
+func (s Sequence) waitStopped(ctx context.Context, addr string) error {
+	deadline := time.Now().Add(s.StopTimeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err != nil {
+			return nil
+		}
+		_ = conn.Close()
+
+		if time.Now().After(deadline) {
+			return errors.Newf("server at %s did not stop within %s", addr, s.StopTimeout)
+		}
+
+		if err := s.Sleep(ctx, 500*time.Millisecond); err != nil {
+			return err
+		}
+	}
+}
+
+// setDefaults fills in unset fields.
+func (s *Sequence) setDefaults() {
+	if s.Message == "" {
+		s.Message = DefaultMessage
+	}
+	if s.Broadcast == "" {
+		s.Broadcast = "say"
+	}
+	if s.Commands == nil {
+		s.Commands = DefaultCommands
+	}
+	if s.StopTimeout <= 0 {
+		s.StopTimeout = DefaultStopTimeout
+	}
+	if s.Sleep == nil {
+		s.Sleep = sleep
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// stopping reports whether an error is expected from a server that is
+// stopping: it closes the connection, possibly before answering.
+func stopping(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, os.ErrDeadlineExceeded) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, net.ErrClosed) ||
+		strings.Contains(err.Error(), "connection reset")
+}
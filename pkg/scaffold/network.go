@@ -0,0 +1,268 @@
+package scaffold
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/internal/atomicfile"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/launch"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+)
+
+const (
+	// ProxyDir is the directory of the proxy inside a network directory.
+	ProxyDir = "proxy"
+	// VelocityFile is the configuration file of Velocity.
+	VelocityFile = "velocity.toml"
+	// SecretFile is the file holding the forwarding secret, relative to the proxy directory.
+	SecretFile = "forwarding.secret"
+	// PaperGlobalFile is the global Paper configuration file, relative to a backend directory.
+	PaperGlobalFile = "config/paper-global.yml"
+	// DefaultBackendPort is the port of the first backend.
+	DefaultBackendPort = 30066
+)
+
+// minForwardingVersion is the first Minecraft version supporting Velocity's
+// modern forwarding.
+const minForwardingVersion = "1.13"
+
+// NetworkOptions control how InitNetwork lays out a network.
+type NetworkOptions struct {
+	ProxyVersion string      // Velocity version constraint (defaults to latest)
+	Version      string      // Paper version constraint of the backends (defaults to latest)
+	Channel      api.Channel // Only consider builds in this channel (empty means any)
+	Backends     []string    // Backend names, also their directory names and server names in Velocity
+	Port         int         // Port the proxy binds to (defaults to DefaultPort)
+	BackendPort  int         // Port of the first backend (defaults to DefaultBackendPort)
+	AcceptEULA   bool        // Accept the Minecraft EULA for the backends
+	Profile      *Profile    // Configuration overrides of the backends
+	Heap         string      // Fixed heap size of each start script (defaults to a share of the memory limit)
+	Force        bool        // Overwrite existing servers
+}
+
+// Backend is a backend server of a network.
+type Backend struct {
+	Name string
+	Port int
+	*Result
+}
+
+// NetworkResult describes a network set up by InitNetwork.
+type NetworkResult struct {
+	Proxy    *Result
+	Backends []Backend
+}
+
+// InitNetwork sets up a Velocity proxy in dir/proxy and a Paper backend in
+// dir/NAME for each backend name. The backends listen on consecutive ports on
+// 127.0.0.1 with online mode disabled, and are registered in velocity.toml,
+// the first one being the server players join. Proxy and backends share a
+// generated forwarding secret for modern forwarding; an existing secret is
+// kept. Every directory is checked before anything is downloaded, so an
+// existing server is never partially overwritten.
+//
+// Unless Heap is set, the proxy and the backends split the share of the
+// memory limit a single server would use as heap evenly, as they usually
+// run on the same host. Whether the Velocity build supports the backends'
+// Minecraft version is not checked: the API does not publish it, and a
+// recent Velocity build supports every release since 1.7.2. Velocity
+// publishes its releases as -SNAPSHOT versions, so "latest", the default
+// ProxyVersion, resolves to its newest -SNAPSHOT version.
+func InitNetwork(ctx context.Context, client api.API, dir string, opts NetworkOptions) (*NetworkResult, error) {
+	opts.setDefaults()
+
+	if err := validateBackends(opts.Backends); err != nil {
+		return nil, err
+	}
+
+	if !opts.Force {
+		for _, name := range append([]string{ProxyDir}, opts.Backends...) {
+			for _, file := range []string{serverdir.LockFile, serverdir.DefaultJar} {
+				path := filepath.Join(dir, name, file)
+				if _, err := os.Stat(path); err == nil {
+					return nil, errors.Wrapf(ErrExists, "%s exists", path)
+				}
+			}
+		}
+	}
+
+	version, err := client.ResolveVersion(ctx, "paper", opts.Version, opts.Channel)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve backend version")
+	}
+	if api.CompareVersions(version, minForwardingVersion) < 0 {
+		return nil, errors.Newf("paper %s does not support Velocity modern forwarding, which requires %s or newer", version, minForwardingVersion)
+	}
+
+	heapPercent := max(launch.DefaultHeapPercent/(len(opts.Backends)+1), 1)
+
+	proxyDir := filepath.Join(dir, ProxyDir)
+	proxy, err := Init(ctx, client, proxyDir, Options{
+		Project:     "velocity",
+		Version:     opts.ProxyVersion,
+		Channel:     opts.Channel,
+		Heap:        opts.Heap,
+		HeapPercent: heapPercent,
+		Force:       true,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to set up proxy")
+	}
+
+	secret, err := forwardingSecret(proxyDir)
+	if err != nil {
+		return nil, err
+	}
+	proxy.Files = append(proxy.Files, SecretFile)
+
+	result := &NetworkResult{Proxy: proxy}
+	for i, name := range opts.Backends {
+		port := opts.BackendPort + i
+
+		backend, err := Init(ctx, client, filepath.Join(dir, name), Options{
+			Project:    "paper",
+			Version:    version,
+			Channel:    opts.Channel,
+			AcceptEULA: opts.AcceptEULA,
+			Profile:    opts.Profile,
+			Properties: map[string]string{
+				"server-ip":   "127.0.0.1",
+				"online-mode": "false",
+			},
+			Port:        port,
+			Heap:        opts.Heap,
+			HeapPercent: heapPercent,
+			Force:       true,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to set up backend %s", name)
+		}
+
+		velocity := map[string]any{
+			"proxies": map[string]any{
+				"velocity": map[string]any{
+					"enabled":     true,
+					"online-mode": true,
+					"secret":      secret,
+				},
+			},
+		}
+		if err := mergeYAML(filepath.Join(dir, name, filepath.FromSlash(PaperGlobalFile)), velocity); err != nil {
+			return nil, err
+		}
+		backend.Files = append(backend.Files, PaperGlobalFile)
+
+		result.Backends = append(result.Backends, Backend{Name: name, Port: port, Result: backend})
+	}
+
+	config, err := velocityConfig(opts.Port, result.Backends)
+	if err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(filepath.Join(proxyDir, VelocityFile), config, 0o644); err != nil {
+		return nil, err
+	}
+	proxy.Files = append(proxy.Files, VelocityFile)
+
+	return result, nil
+}
+
+// setDefaults fills in unset options.
+func (o *NetworkOptions) setDefaults() {
+	if o.Version == "" {
+		o.Version = "latest"
+	}
+	if len(o.Backends) == 0 {
+		o.Backends = []string{"lobby"}
+	}
+	if o.Port == 0 {
+		o.Port = DefaultPort
+	}
+	if o.BackendPort == 0 {
+		o.BackendPort = DefaultBackendPort
+	}
+}
+
+// validateBackends checks that backend names are unique and usable both as
+// directory names and as unquoted TOML keys.
+func validateBackends(names []string) error {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if name == "" || name == ProxyDir || strings.TrimFunc(name, func(r rune) bool {
+			return r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		}) != "" {
+			return errors.Newf("invalid backend name %q: use letters, digits, '-' and '_', and not %q", name, ProxyDir)
+		}
+		if seen[name] {
+			return errors.Newf("duplicate backend name %q", name)
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
+
+// forwardingSecret returns the forwarding secret of a proxy directory,
+// generating it if there is none.
+func forwardingSecret(proxyDir string) (string, error) {
+	path := filepath.Join(proxyDir, SecretFile)
+
+	data, err := os.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(data)) != "" {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", errors.Wrap(err, "failed to read forwarding secret")
+	}
+
+	secret := rand.Text()
+	if err := atomicfile.WriteFile(path, []byte(secret), 0o600); err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// velocityTemplate is the generated velocity.toml. Settings that are not
+// listed keep Velocity's defaults.
+var velocityTemplate = template.Must(template.New(VelocityFile).Parse(`# Generated by papermc network init. Settings not listed here use
+# Velocity's defaults; see https://docs.papermc.io/velocity/configuration
+
+config-version = "2.7"
+bind = "0.0.0.0:{{.Port}}"
+motd = "<#09add3>A Velocity Server"
+online-mode = true
+force-key-authentication = true
+player-info-forwarding-mode = "modern"
+forwarding-secret-file = "{{.SecretFile}}"
+
+[servers]
+{{- range .Backends}}
+{{.Name}} = "127.0.0.1:{{.Port}}"
+{{- end}}
+try = ["{{(index .Backends 0).Name}}"]
+
+[forced-hosts]
+`))
+
+// velocityConfig renders velocity.toml.
+func velocityConfig(port int, backends []Backend) ([]byte, error) {
+	var buf bytes.Buffer
+	err := velocityTemplate.Execute(&buf, map[string]any{
+		"Port":       port,
+		"SecretFile": SecretFile,
+		"Backends":   backends,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render velocity.toml")
+	}
+
+	return buf.Bytes(), nil
+}
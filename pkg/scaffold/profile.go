@@ -0,0 +1,207 @@
+package scaffold
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/internal/atomicfile"
+	"go.yaml.in/yaml/v3"
+)
+
+// DefaultProfile is the profile used when none is given.
+const DefaultProfile = "default"
+
+// Profile is a set of configuration overrides applied to a new server.
+// String values are Go templates rendered with Vars, e.g. "{{.Name}}".
+type Profile struct {
+	Name       string            `yaml:"-"`
+	Properties map[string]string `yaml:"server_properties"` // server.properties entries
+	Bukkit     map[string]any    `yaml:"bukkit"`            // bukkit.yml overrides, merged into the file
+}
+
+// Vars are the values available to profile templates.
+type Vars struct {
+	Name    string // Base name of the server directory
+	Project string
+	Version string
+	Build   int32
+	Port    int
+}
+
+// Profiles are the built-in profiles.
+var Profiles = map[string]*Profile{
+	DefaultProfile: {
+		Properties: map[string]string{
+			"motd": "{{.Name}} - {{.Project}} {{.Version}}",
+		},
+	},
+	"small": {
+		Properties: map[string]string{
+			"motd":                "{{.Name}} - {{.Project}} {{.Version}}",
+			"max-players":         "10",
+			"view-distance":       "6",
+			"simulation-distance": "4",
+			"sync-chunk-writes":   "false",
+		},
+		Bukkit: map[string]any{
+			"spawn-limits": map[string]any{"monsters": 50, "animals": 8, "water-animals": 3, "water-ambient": 10, "ambient": 1},
+			"ticks-per":    map[string]any{"autosave": 6000},
+		},
+	},
+	"lobby": {
+		Properties: map[string]string{
+			"motd":                "{{.Name}}",
+			"gamemode":            "adventure",
+			"force-gamemode":      "true",
+			"difficulty":          "peaceful",
+			"pvp":                 "false",
+			"spawn-monsters":      "false",
+			"generate-structures": "false",
+			"level-type":          "minecraft:flat",
+			"allow-nether":        "false",
+			"view-distance":       "4",
+			"simulation-distance": "4",
+			"spawn-protection":    "0",
+		},
+		Bukkit: map[string]any{
+			"settings":     map[string]any{"allow-end": false},
+			"spawn-limits": map[string]any{"monsters": 0, "animals": 0, "water-animals": 0, "water-ambient": 0, "ambient": 0},
+		},
+	},
+}
+
+// ProfileNames returns the names of the built-in profiles, sorted.
+func ProfileNames() []string {
+	return slices.Sorted(maps.Keys(Profiles))
+}
+
+// LoadProfile returns the built-in profile with the given name or, if there
+// is none, reads a profile from the YAML file at that path.
+func LoadProfile(nameOrPath string) (*Profile, error) {
+	if nameOrPath == "" {
+		nameOrPath = DefaultProfile
+	}
+
+	if profile, ok := Profiles[nameOrPath]; ok {
+		named := *profile
+		named.Name = nameOrPath
+		return &named, nil
+	}
+
+	data, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errors.Newf("unknown profile %q (built-in profiles: %s)", nameOrPath, strings.Join(ProfileNames(), ", "))
+		}
+		return nil, errors.Wrap(err, "failed to read profile")
+	}
+
+	var profile Profile
+	if err := yaml.Unmarshal(data, &profile); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse profile %s", nameOrPath)
+	}
+	profile.Name = nameOrPath
+
+	return &profile, nil
+}
+
+// render renders a template string.
+func render(text string, vars Vars) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid template %q", text)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", errors.Wrapf(err, "failed to render %q", text)
+	}
+
+	return buf.String(), nil
+}
+
+// renderTree renders the string values of a YAML tree.
+func renderTree(value any, vars Vars) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return render(v, vars)
+	case map[string]any:
+		rendered := make(map[string]any, len(v))
+		for key, item := range v {
+			r, err := renderTree(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			rendered[key] = r
+		}
+		return rendered, nil
+	case []any:
+		rendered := make([]any, len(v))
+		for i, item := range v {
+			r, err := renderTree(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			rendered[i] = r
+		}
+		return rendered, nil
+	default:
+		return v, nil
+	}
+}
+
+// mergeYAML merges overrides into the YAML file at path, creating it if it
+// does not exist. Nested mappings are merged key by key; other values
+// replace the existing ones.
+func mergeYAML(path string, overrides map[string]any) error {
+	doc := make(map[string]any)
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return errors.Wrapf(err, "failed to parse %s", path)
+		}
+		if doc == nil {
+			doc = make(map[string]any)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	merge(doc, overrides)
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode %s", path)
+	}
+
+	return atomicfile.WriteFile(path, out, 0o644)
+}
+
+// merge merges src into dst.
+func merge(dst, src map[string]any) {
+	for key, value := range src {
+		nested, ok := value.(map[string]any)
+		if !ok {
+			dst[key] = value
+			continue
+		}
+
+		existing, ok := dst[key].(map[string]any)
+		if !ok {
+			existing = make(map[string]any)
+		}
+		merge(existing, nested)
+		dst[key] = existing
+	}
+}
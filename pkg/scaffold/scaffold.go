@@ -0,0 +1,229 @@
+// Package scaffold lays out new server directories: it downloads a build,
+// records it in papermc.lock, writes configuration from a profile and
+// generates a start script.
+package scaffold
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/internal/atomicfile"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/launch"
+	"github.com/lexfrei/goPaperMC/pkg/properties"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+)
+
+const (
+	// EULAFile is the file recording acceptance of the Minecraft EULA.
+	EULAFile = "eula.txt"
+	// BukkitFile is the Bukkit configuration file of Paper servers.
+	BukkitFile = "bukkit.yml"
+	// DefaultPort is the default port of Minecraft servers.
+	DefaultPort = 25565
+)
+
+// ErrExists is returned by Init when the directory already holds a server.
+var ErrExists = errors.New("directory already contains a server")
+
+// Options control how Init sets up a server directory.
+type Options struct {
+	Project     string            // Project to install (defaults to paper)
+	Version     string            // Version constraint, e.g. 1.21.x (defaults to latest)
+	Channel     api.Channel       // Only consider builds in this channel (empty means any)
+	Jar         string            // Jar file name (defaults to server.jar)
+	AcceptEULA  bool              // Write eula.txt accepting the Minecraft EULA
+	Profile     *Profile          // Configuration overrides (defaults to the default profile)
+	Properties  map[string]string // server.properties entries applied after the profile
+	Port        int               // server-port (0 keeps the profile's or the server's default)
+	Java        string            // Java executable used by the start script
+	Heap        string            // Fixed heap size of the start script
+	HeapPercent int               // Share of the memory limit used as heap, in percent (defaults to launch.DefaultHeapPercent)
+	Force       bool              // Overwrite an existing server
+}
+
+// Result describes a server directory set up by Init.
+type Result struct {
+	Install *serverdir.Install
+	Files   []string // Files written, relative to the directory
+}
+
+// IsProxy reports whether a project is a proxy, which has no
+// server.properties, world or EULA.
+func IsProxy(project string) bool {
+	return project == "velocity" || project == "waterfall"
+}
+
+// Init sets up a server in dir: it downloads the newest build matching the
+// options, records it in papermc.lock and writes eula.txt (if accepted),
+// server.properties and bukkit.yml from the profile and a start script.
+// Existing configuration files are updated rather than replaced. Init fails
+// with ErrExists if dir already holds a server jar or lock file, unless
+// Force is set.
+func Init(ctx context.Context, client api.API, dir string, opts Options) (*Result, error) {
+	opts.setDefaults()
+
+	if !opts.Force {
+		for _, name := range []string{serverdir.LockFile, opts.Jar} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return nil, errors.Wrapf(ErrExists, "%s exists", filepath.Join(dir, name))
+			}
+		}
+	}
+
+	version, err := client.ResolveVersion(ctx, opts.Project, opts.Version, opts.Channel)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve version")
+	}
+
+	build, err := client.GetLatestBuildForChannel(ctx, opts.Project, version, opts.Channel)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve build")
+	}
+
+	download, ok := build.GetDownload(api.DefaultDownloadKey)
+	if !ok {
+		return nil, errors.Newf("build %d of %s %s has no %s download", build.ID, opts.Project, version, api.DefaultDownloadKey)
+	}
+
+	meta, err := client.GetVersion(ctx, opts.Project, version)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get version info")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create server directory")
+	}
+
+	if _, err := client.DownloadTo(ctx, download, filepath.Join(dir, opts.Jar)); err != nil {
+		return nil, errors.Wrap(err, "failed to download build")
+	}
+
+	install := &serverdir.Install{
+		Project:     opts.Project,
+		Version:     version,
+		Build:       build.ID,
+		Channel:     build.Channel,
+		File:        opts.Jar,
+		SHA256:      download.Checksums.SHA256,
+		InstalledAt: time.Now().UTC(),
+	}
+	if err := serverdir.WriteLock(dir, &serverdir.Lock{Install: *install}); err != nil {
+		return nil, err
+	}
+
+	result := &Result{Install: install, Files: []string{opts.Jar, serverdir.LockFile}}
+
+	if !IsProxy(opts.Project) {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve server directory")
+		}
+
+		vars := Vars{Name: filepath.Base(abs), Project: opts.Project, Version: version, Build: build.ID, Port: opts.Port}
+		if vars.Port == 0 {
+			vars.Port = DefaultPort
+		}
+
+		files, err := writeConfig(dir, opts, vars)
+		if err != nil {
+			return nil, err
+		}
+		result.Files = append(result.Files, files...)
+	}
+
+	script := launch.ForVersion(opts.Project, meta.Version)
+	script.Jar = opts.Jar
+	script.Java = opts.Java
+	script.Heap = opts.Heap
+	script.HeapPercent = opts.HeapPercent
+
+	data, err := launch.Script(script)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate start script")
+	}
+	if err := atomicfile.WriteFile(filepath.Join(dir, launch.ScriptFile), data, 0o755); err != nil {
+		return nil, err
+	}
+	result.Files = append(result.Files, launch.ScriptFile)
+
+	return result, nil
+}
+
+// writeConfig writes eula.txt, server.properties and bukkit.yml.
+func writeConfig(dir string, opts Options, vars Vars) ([]string, error) {
+	var files []string
+
+	if opts.AcceptEULA {
+		eula := fmt.Sprintf("#By changing the setting below to TRUE you are indicating your agreement to our EULA (https://aka.ms/MinecraftEULA).\n"+
+			"#Accepted with papermc init on %s\neula=true\n", time.Now().UTC().Format(time.RFC3339))
+		if err := atomicfile.WriteFile(filepath.Join(dir, EULAFile), []byte(eula), 0o644); err != nil {
+			return nil, err
+		}
+		files = append(files, EULAFile)
+	}
+
+	path := filepath.Join(dir, properties.ServerFile)
+	props, err := properties.Load(path)
+	if errors.Is(err, os.ErrNotExist) {
+		props, err = &properties.Properties{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entries := range []map[string]string{opts.Profile.Properties, opts.Properties} {
+		for _, key := range slices.Sorted(maps.Keys(entries)) {
+			rendered, err := render(entries[key], vars)
+			if err != nil {
+				return nil, errors.Wrapf(err, "server.properties %s", key)
+			}
+			props.Set(key, rendered)
+		}
+	}
+	if opts.Port != 0 {
+		props.Set("server-port", strconv.Itoa(opts.Port))
+	}
+
+	if err := props.WriteFile(path); err != nil {
+		return nil, err
+	}
+	files = append(files, properties.ServerFile)
+
+	if len(opts.Profile.Bukkit) > 0 {
+		bukkit, err := renderTree(opts.Profile.Bukkit, vars)
+		if err != nil {
+			return nil, errors.Wrap(err, BukkitFile)
+		}
+
+		if err := mergeYAML(filepath.Join(dir, BukkitFile), bukkit.(map[string]any)); err != nil {
+			return nil, err
+		}
+		files = append(files, BukkitFile)
+	}
+
+	return files, nil
+}
+
+// setDefaults fills in unset options.
+func (o *Options) setDefaults() {
+	if o.Project == "" {
+		o.Project = "paper"
+	}
+	if o.Version == "" {
+		o.Version = "latest"
+	}
+	if o.Jar == "" {
+		o.Jar = serverdir.DefaultJar
+	}
+	if o.Profile == nil {
+		o.Profile = Profiles[DefaultProfile]
+	}
+}
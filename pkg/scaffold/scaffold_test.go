@@ -0,0 +1,265 @@
+package scaffold
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/api/apitest"
+	"github.com/lexfrei/goPaperMC/pkg/launch"
+	"github.com/lexfrei/goPaperMC/pkg/properties"
+	"github.com/lexfrei/goPaperMC/pkg/serverdir"
+	"go.yaml.in/yaml/v3"
+)
+
+// newTestServer serves paper 1.21.10 build 120 and 1.21.11 build 74, and
+// velocity 3.1.1 build 102 and 3.4.0-SNAPSHOT build 520. Like the real API,
+// velocity's newest release is a -SNAPSHOT version.
+func newTestServer(t *testing.T) *apitest.Server {
+	t.Helper()
+
+	return apitest.NewServer(t, apitest.NewBuilder().
+		Project("paper").
+		Version("1.21", "1.21.10").Java(21, "-Xmx4G", "-XX:+UseG1GC").Build(120, api.ChannelStable).
+		Version("1.21", "1.21.11").Java(21, "-Xmx4G", "-XX:+UseG1GC").Build(74, api.ChannelStable).
+		Project("velocity").
+		Version("3.0.0", "3.1.1").Java(11).Build(102, api.ChannelStable).
+		Version("3.0.0", "3.4.0-SNAPSHOT").Java(21, "-Xmx4G", "-XX:+UseG1GC").Build(520, api.ChannelStable))
+}
+
+func TestInit(t *testing.T) {
+	client := newTestServer(t).Client()
+	dir := filepath.Join(t.TempDir(), "lobby")
+
+	result, err := Init(context.Background(), client, dir, Options{
+		Version:    "1.21.x",
+		AcceptEULA: true,
+		Profile:    Profiles["lobby"],
+		Properties: map[string]string{"motd": "{{.Name}} on {{.Version}}", "white-list": "true"},
+		Port:       25570,
+	})
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	if result.Install.Version != "1.21.11" || result.Install.Build != 74 {
+		t.Errorf("Expected paper 1.21.11 build 74, got %+v", result.Install)
+	}
+
+	lock, err := serverdir.ReadLock(dir)
+	if err != nil || lock.SHA256 != result.Install.SHA256 {
+		t.Errorf("Expected the install to be recorded, got %+v (%v)", lock, err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, EULAFile)); err != nil || !strings.Contains(string(data), "\neula=true\n") {
+		t.Errorf("Expected the EULA to be accepted, got %q (%v)", data, err)
+	}
+
+	props, err := properties.Load(filepath.Join(dir, properties.ServerFile))
+	if err != nil {
+		t.Fatalf("Failed to load server.properties: %v", err)
+	}
+	for key, want := range map[string]string{
+		"motd":        "lobby on 1.21.11",
+		"gamemode":    "adventure",
+		"white-list":  "true",
+		"server-port": "25570",
+	} {
+		if got, _ := props.Get(key); got != want {
+			t.Errorf("Expected %s=%s, got %q", key, want, got)
+		}
+	}
+
+	var bukkit map[string]map[string]any
+	data, err := os.ReadFile(filepath.Join(dir, BukkitFile))
+	if err != nil {
+		t.Fatalf("Failed to read bukkit.yml: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &bukkit); err != nil || bukkit["settings"]["allow-end"] != false {
+		t.Errorf("Expected the End to be disabled, got\n%s", data)
+	}
+
+	script, err := os.ReadFile(filepath.Join(dir, "start.sh"))
+	if err != nil || !strings.Contains(string(script), "-XX:+UseG1GC") {
+		t.Errorf("Expected a start script with the recommended flags, got %v", err)
+	}
+
+	// An existing server is not overwritten
+	if _, err := Init(context.Background(), client, dir, Options{}); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists, got %v", err)
+	}
+
+	// Forcing keeps existing settings the profile does not set
+	props.Set("level-seed", "42")
+	if err := props.WriteFile(filepath.Join(dir, properties.ServerFile)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Init(context.Background(), client, dir, Options{Version: "1.21.10", Force: true}); err != nil {
+		t.Fatalf("Forced Init failed: %v", err)
+	}
+	props, err = properties.Load(filepath.Join(dir, properties.ServerFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed, _ := props.Get("level-seed"); seed != "42" {
+		t.Errorf("Expected existing settings to be kept, got level-seed=%q", seed)
+	}
+	if motd, _ := props.Get("motd"); motd != "lobby - paper 1.21.10" {
+		t.Errorf("Expected the default profile's motd, got %q", motd)
+	}
+}
+
+func TestInit_Proxy(t *testing.T) {
+	client := newTestServer(t).Client()
+	dir := t.TempDir()
+
+	result, err := Init(context.Background(), client, dir, Options{Project: "velocity", AcceptEULA: true})
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if result.Install.Version != "3.4.0-SNAPSHOT" {
+		t.Errorf("Expected the newest Velocity release 3.4.0-SNAPSHOT, got %+v", result.Install)
+	}
+
+	for _, name := range []string{EULAFile, properties.ServerFile, BukkitFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("Expected no %s for a proxy", name)
+		}
+	}
+	if len(result.Files) != 3 {
+		t.Errorf("Expected the jar, lock file and start script, got %v", result.Files)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "survival.yaml")
+	profile := `server_properties:
+  difficulty: hard
+  max-players: 40
+  hardcore: true
+bukkit:
+  settings:
+    allow-end: false
+`
+	if err := os.WriteFile(path, []byte(profile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+	if loaded.Properties["max-players"] != "40" || loaded.Properties["hardcore"] != "true" {
+		t.Errorf("Expected scalar values as strings, got %v", loaded.Properties)
+	}
+
+	if builtin, err := LoadProfile(""); err != nil || builtin.Name != DefaultProfile {
+		t.Errorf("Expected the default profile, got %v (%v)", builtin, err)
+	}
+
+	if _, err := LoadProfile("missing"); err == nil || !strings.Contains(err.Error(), "lobby") {
+		t.Errorf("Expected an unknown profile error listing the built-in profiles, got %v", err)
+	}
+}
+
+func TestInitNetwork(t *testing.T) {
+	client := newTestServer(t).Client()
+	dir := t.TempDir()
+
+	result, err := InitNetwork(context.Background(), client, dir, NetworkOptions{
+		Version:    "1.21.x",
+		Backends:   []string{"lobby", "survival"},
+		AcceptEULA: true,
+	})
+	if err != nil {
+		t.Fatalf("InitNetwork failed: %v", err)
+	}
+
+	if result.Proxy.Install.Project != "velocity" || result.Proxy.Install.Version != "3.4.0-SNAPSHOT" {
+		t.Errorf("Expected Velocity 3.4.0-SNAPSHOT, got %+v", result.Proxy.Install)
+	}
+
+	secret, err := os.ReadFile(filepath.Join(dir, ProxyDir, SecretFile))
+	if err != nil || len(secret) == 0 {
+		t.Fatalf("Expected a forwarding secret, got %q (%v)", secret, err)
+	}
+
+	config, err := os.ReadFile(filepath.Join(dir, ProxyDir, VelocityFile))
+	if err != nil {
+		t.Fatalf("Failed to read velocity.toml: %v", err)
+	}
+	for _, want := range []string{
+		`bind = "0.0.0.0:25565"`,
+		`player-info-forwarding-mode = "modern"`,
+		`lobby = "127.0.0.1:30066"`,
+		`survival = "127.0.0.1:30067"`,
+		`try = ["lobby"]`,
+	} {
+		if !strings.Contains(string(config), want) {
+			t.Errorf("Expected velocity.toml to contain %s, got\n%s", want, config)
+		}
+	}
+
+	for i, backend := range result.Backends {
+		if backend.Install.Version != "1.21.11" {
+			t.Errorf("Expected %s to run 1.21.11, got %s", backend.Name, backend.Install.Version)
+		}
+
+		props, err := properties.Load(filepath.Join(dir, backend.Name, properties.ServerFile))
+		if err != nil {
+			t.Fatalf("Failed to load server.properties of %s: %v", backend.Name, err)
+		}
+		if port, _ := props.Get("server-port"); port != strconv.Itoa(DefaultBackendPort+i) {
+			t.Errorf("Expected %s on port %d, got %s", backend.Name, DefaultBackendPort+i, port)
+		}
+		if online, _ := props.Get("online-mode"); online != "false" {
+			t.Errorf("Expected online mode to be disabled on %s", backend.Name)
+		}
+
+		var global struct {
+			Proxies struct {
+				Velocity struct {
+					Enabled bool   `yaml:"enabled"`
+					Secret  string `yaml:"secret"`
+				} `yaml:"velocity"`
+			} `yaml:"proxies"`
+		}
+		data, err := os.ReadFile(filepath.Join(dir, backend.Name, PaperGlobalFile))
+		if err != nil {
+			t.Fatalf("Failed to read paper-global.yml of %s: %v", backend.Name, err)
+		}
+		if err := yaml.Unmarshal(data, &global); err != nil || !global.Proxies.Velocity.Enabled || global.Proxies.Velocity.Secret != string(secret) {
+			t.Errorf("Expected %s to forward with the shared secret, got\n%s", backend.Name, data)
+		}
+	}
+
+	// The proxy and the two backends split the default heap share
+	for _, name := range []string{ProxyDir, "lobby", "survival"} {
+		script, err := os.ReadFile(filepath.Join(dir, name, launch.ScriptFile))
+		if err != nil || !strings.Contains(string(script), `HEAP_PERCENT="${HEAP_PERCENT:-25}"`) {
+			t.Errorf("Expected %s to use a quarter of the memory limit as heap (%v)", name, err)
+		}
+	}
+
+	// Existing servers are not overwritten; forcing keeps the secret
+	if _, err := InitNetwork(context.Background(), client, dir, NetworkOptions{Backends: []string{"creative"}}); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists for the existing proxy, got %v", err)
+	}
+	if _, err := InitNetwork(context.Background(), client, dir, NetworkOptions{Backends: []string{"lobby"}, Force: true}); err != nil {
+		t.Fatalf("Forced InitNetwork failed: %v", err)
+	}
+	if again, _ := os.ReadFile(filepath.Join(dir, ProxyDir, SecretFile)); string(again) != string(secret) {
+		t.Error("Expected the forwarding secret to be kept")
+	}
+
+	for _, names := range [][]string{{"proxy"}, {"a", "a"}, {"../escape"}} {
+		if _, err := InitNetwork(context.Background(), client, t.TempDir(), NetworkOptions{Backends: names}); err == nil {
+			t.Errorf("Expected backend names %v to be rejected", names)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package serverdir
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+	"github.com/lexfrei/goPaperMC/pkg/jarinfo"
+)
+
+// DetectOptions narrows down how the installed build is detected.
+type DetectOptions struct {
+	Jar     string // Jar file name inside the directory (defaults to server.jar)
+	Project string // Project to search when the jar is not recorded (defaults to paper)
+	Version string // Version constraint to search when the jar is not recorded
+}
+
+// Detect determines which published build is installed in a server
+// directory. It trusts the lock file if its checksum matches the jar, then
+// tries the versioned file name of a symlinked jar and the metadata embedded
+// in the jar, and finally looks the jar's SHA256 up among the builds of the
+// project.
+func Detect(ctx context.Context, client api.API, dir string, opts DetectOptions) (*Install, error) {
+	jar := opts.Jar
+	if jar == "" {
+		jar = DefaultJar
+	}
+	jarPath := filepath.Join(dir, jar)
+
+	info, err := os.Stat(jarPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find installed jar")
+	}
+
+	sum, err := api.FileSHA256(jarPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to checksum %s", jarPath)
+	}
+
+	if lock, err := ReadLock(dir); err == nil && lock.File == jar && lock.SHA256 == sum {
+		return &lock.Install, nil
+	}
+
+	if install := detectFromLinkName(ctx, client, jarPath, sum); install != nil {
+		install.File = jar
+		install.InstalledAt = info.ModTime().UTC()
+		return install, nil
+	}
+
+	project := opts.Project
+	if project == "" {
+		project = "paper"
+	}
+
+	if install := detectFromMetadata(ctx, client, jarPath, project, sum); install != nil {
+		install.File = jar
+		install.InstalledAt = info.ModTime().UTC()
+		return install, nil
+	}
+
+	id, err := client.Identify(ctx, project, sum, api.IdentifyOptions{Version: opts.Version})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to identify installed jar")
+	}
+
+	return &Install{
+		Project:     id.Project,
+		Version:     id.Version,
+		Build:       id.Build,
+		Channel:     id.Channel,
+		File:        jar,
+		SHA256:      sum,
+		InstalledAt: info.ModTime().UTC(),
+	}, nil
+}
+
+// detectFromLinkName parses the versioned name of a symlinked jar
+// (e.g. paper-1.21.11-74.jar) and confirms it with the build's checksum.
+func detectFromLinkName(ctx context.Context, client api.API, jarPath, sum string) *Install {
+	target, err := os.Readlink(jarPath)
+	if err != nil {
+		return nil
+	}
+
+	project, version, build, ok := ParseJarName(filepath.Base(target))
+	if !ok {
+		return nil
+	}
+
+	buildInfo, err := client.GetBuild(ctx, project, version, build)
+	if err != nil {
+		return nil
+	}
+
+	for _, download := range buildInfo.Downloads {
+		if download.Checksums.SHA256 == sum {
+			return newInstall(project, version, buildInfo, sum)
+		}
+	}
+
+	return nil
+}
+
+// detectFromMetadata reads the version and build embedded in the jar and
+// confirms them with the build's checksum.
+func detectFromMetadata(ctx context.Context, client api.API, jarPath, project, sum string) *Install {
+	report, err := jarinfo.Inspect(jarPath)
+	if err != nil || report.Build == 0 || report.MinecraftVersion == "" {
+		return nil
+	}
+
+	if report.Project != "" {
+		project = report.Project
+	}
+
+	buildInfo, err := client.GetBuild(ctx, project, report.MinecraftVersion, report.Build)
+	if err != nil {
+		return nil
+	}
+
+	// Embedded metadata alone is not proof; only trust it if the checksum matches.
+	for _, download := range buildInfo.Downloads {
+		if download.Checksums.SHA256 == sum {
+			return newInstall(project, report.MinecraftVersion, buildInfo, sum)
+		}
+	}
+
+	return nil
+}
+
+// ParseJarName splits a versioned jar name such as paper-1.21.11-74.jar or
+// velocity-3.4.0-SNAPSHOT-500.jar into project, version and build.
+func ParseJarName(name string) (string, string, int32, bool) {
+	base, ok := strings.CutSuffix(name, ".jar")
+	if !ok {
+		return "", "", 0, false
+	}
+
+	rest, buildStr, ok := cutLast(base, "-")
+	if !ok {
+		return "", "", 0, false
+	}
+
+	build, err := strconv.ParseInt(buildStr, 10, 32)
+	if err != nil {
+		return "", "", 0, false
+	}
+
+	project, version, ok := strings.Cut(rest, "-")
+	if !ok || project == "" || version == "" {
+		return "", "", 0, false
+	}
+
+	return project, version, int32(build), true
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+
+	return s[:i], s[i+len(sep):], true
+}
+
+// newInstall describes a jar matching the given build.
+func newInstall(project, version string, build *api.BuildV3Response, sum string) *Install {
+	return &Install{
+		Project: project,
+		Version: version,
+		Build:   build.ID,
+		Channel: build.Channel,
+		SHA256:  sum,
+	}
+}
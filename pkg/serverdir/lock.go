@@ -0,0 +1,82 @@
+// Package serverdir manages the server jar installed in a server directory:
+// detecting which build is installed, updating it in place with backups and
+// rolling back to a previous build.
+//
+// The installed build is recorded in a papermc.lock file next to the jar.
+package serverdir
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/internal/atomicfile"
+)
+
+const (
+	// LockFile is the name of the file recording the installed build.
+	LockFile = "papermc.lock"
+	// DefaultJar is the stable file name of the installed server jar.
+	DefaultJar = "server.jar"
+	// BackupDir is the directory, relative to the server directory, holding replaced jars.
+	BackupDir = ".papermc/backups"
+	// DefaultKeep is the default number of backups kept by Update.
+	DefaultKeep = 3
+)
+
+// Install describes a server jar installed from a published build.
+type Install struct {
+	Project     string    `json:"project"`
+	Version     string    `json:"version"`
+	Build       int32     `json:"build"`
+	Channel     string    `json:"channel,omitempty"`
+	File        string    `json:"file"` // Path relative to the server directory
+	SHA256      string    `json:"sha256"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// BackupName returns the versioned file name used when backing up this install.
+func (i *Install) BackupName() string {
+	return BackupFileName(i.Project, i.Version, i.Build)
+}
+
+// BackupFileName returns the versioned jar name for a build, e.g. paper-1.21.11-74.jar.
+func BackupFileName(project, version string, build int32) string {
+	return project + "-" + version + "-" + strconv.FormatInt(int64(build), 10) + ".jar"
+}
+
+// Lock is the content of a papermc.lock file: the installed build and the
+// backups available for rollback, most recent first.
+type Lock struct {
+	Install
+	Backups []Install `json:"backups,omitempty"`
+}
+
+// ReadLock reads the lock file of a server directory. The returned error
+// matches os.ErrNotExist if the directory has no lock file.
+func ReadLock(dir string) (*Lock, error) {
+	data, err := os.ReadFile(filepath.Join(dir, LockFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read lock file")
+	}
+
+	var lock Lock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, errors.Wrap(err, "failed to decode lock file")
+	}
+
+	return &lock, nil
+}
+
+// WriteLock atomically writes the lock file of a server directory.
+func WriteLock(dir string, lock *Lock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode lock file")
+	}
+
+	return atomicfile.WriteFile(filepath.Join(dir, LockFile), append(data, '\n'), 0o644)
+}
@@ -0,0 +1,283 @@
+package serverdir
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+func jarFor(build int32) []byte {
+	return []byte("paper 1.21.11 build " + strconv.Itoa(int(build)))
+}
+
+func sumFor(build int32) string {
+	sum := sha256.Sum256(jarFor(build))
+	return hex.EncodeToString(sum[:])
+}
+
+func buildFor(baseURL string, id int32) api.BuildV3Response {
+	name := BackupFileName("paper", "1.21.11", id)
+
+	return api.BuildV3Response{
+		ID:      id,
+		Channel: "STABLE",
+		Downloads: map[string]api.DownloadV3{
+			"server:default": {
+				Name:      name,
+				URL:       baseURL + "/jars/" + name,
+				Checksums: api.ChecksumsV3{SHA256: sumFor(id)},
+			},
+		},
+	}
+}
+
+// newTestServer serves paper 1.21.11 with the given builds, each having a
+// jar whose content is derived from its build number.
+func newTestServer(t *testing.T, builds ...int32) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v3/projects/paper":
+			resp := api.ProjectV3Response{
+				Project:  api.ProjectMeta{ID: "paper", Name: "Paper"},
+				Versions: map[string][]string{"1.21": {"1.21.11"}},
+			}
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				t.Fatalf("Failed to encode response: %v", err)
+			}
+		case r.URL.Path == "/v3/projects/paper/versions/1.21.11/builds":
+			resp := make([]api.BuildV3Response, 0, len(builds))
+			for _, id := range builds {
+				resp = append(resp, buildFor(server.URL, id))
+			}
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				t.Fatalf("Failed to encode response: %v", err)
+			}
+		case r.URL.Path == "/v3/projects/paper/versions/1.21.11/builds/latest":
+			if err := json.NewEncoder(w).Encode(buildFor(server.URL, builds[len(builds)-1])); err != nil {
+				t.Fatalf("Failed to encode response: %v", err)
+			}
+		case strings.HasPrefix(r.URL.Path, "/v3/projects/paper/versions/1.21.11/builds/"):
+			id, err := strconv.ParseInt(path.Base(r.URL.Path), 10, 32)
+			if err != nil || !slices.Contains(builds, int32(id)) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(buildFor(server.URL, int32(id))); err != nil {
+				t.Fatalf("Failed to encode response: %v", err)
+			}
+		case strings.HasPrefix(r.URL.Path, "/jars/"):
+			_, _, build, ok := ParseJarName(strings.TrimPrefix(r.URL.Path, "/jars/"))
+			if !ok {
+				t.Errorf("Unexpected jar path: %s", r.URL.Path)
+			}
+			_, _ = w.Write(jarFor(build))
+		default:
+			t.Errorf("Unexpected request path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return server
+}
+
+func TestParseJarName(t *testing.T) {
+	tests := []struct {
+		name    string
+		project string
+		version string
+		build   int32
+		ok      bool
+	}{
+		{"paper-1.21.11-74.jar", "paper", "1.21.11", 74, true},
+		{"velocity-3.4.0-SNAPSHOT-500.jar", "velocity", "3.4.0-SNAPSHOT", 500, true},
+		{"paper-1.21.11-rc3-31.jar", "paper", "1.21.11-rc3", 31, true},
+		{"server.jar", "", "", 0, false},
+		{"paper-1.21.11.jar", "", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		project, version, build, ok := ParseJarName(tt.name)
+		if ok != tt.ok || project != tt.project || version != tt.version || build != tt.build {
+			t.Errorf("ParseJarName(%q) = %q, %q, %d, %v", tt.name, project, version, build, ok)
+		}
+	}
+}
+
+func TestUpdateAndRollback(t *testing.T) {
+	server := newTestServer(t, 72, 73, 74)
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, DefaultJar), jarFor(72), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := api.NewClient().WithBaseURL(server.URL)
+	ctx := context.Background()
+
+	// A failing BeforeInstall leaves the installed jar in place
+	_, err := Update(ctx, client, dir, UpdateOptions{BeforeInstall: func(_ context.Context, from, to *Install) error {
+		if from.Build != 72 || to.Build != 74 {
+			t.Errorf("Expected BeforeInstall for 72 -> 74, got %d -> %d", from.Build, to.Build)
+		}
+		return errors.New("server still running")
+	}})
+	if err == nil {
+		t.Fatal("Expected the update to be aborted")
+	}
+	if sum, _ := api.FileSHA256(filepath.Join(dir, DefaultJar)); sum != sumFor(72) {
+		t.Error("Expected server.jar to still contain build 72")
+	}
+
+	// No lock file yet: the installed build is found by checksum.
+	result, err := Update(ctx, client, dir, UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if !result.Updated || result.Previous.Build != 72 || result.Current.Build != 74 {
+		t.Fatalf("Expected update from 72 to 74, got %+v -> %+v", result.Previous, result.Current)
+	}
+
+	sum, err := api.FileSHA256(filepath.Join(dir, DefaultJar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != sumFor(74) {
+		t.Error("Expected server.jar to contain build 74")
+	}
+
+	lock, err := ReadLock(dir)
+	if err != nil {
+		t.Fatalf("ReadLock failed: %v", err)
+	}
+	if lock.Build != 74 || len(lock.Backups) != 1 || lock.Backups[0].Build != 72 {
+		t.Fatalf("Unexpected lock: %+v", lock)
+	}
+
+	result, err = Update(ctx, client, dir, UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Second update failed: %v", err)
+	}
+	if result.Updated {
+		t.Error("Expected second update to be a no-op")
+	}
+
+	rollback, err := Rollback(dir, "")
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if rollback.From.Build != 74 || rollback.To.Build != 72 {
+		t.Errorf("Expected rollback from 74 to 72, got %d -> %d", rollback.From.Build, rollback.To.Build)
+	}
+
+	sum, err = api.FileSHA256(filepath.Join(dir, DefaultJar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != sumFor(72) {
+		t.Error("Expected server.jar to contain build 72 after rollback")
+	}
+
+	if _, err := Rollback(dir, ""); err == nil {
+		t.Error("Expected rollback without backups to fail")
+	}
+}
+
+func TestUpdate_PrunesBackups(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, DefaultJar), jarFor(70), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	all := []int32{70, 71, 72, 73}
+	for i := 1; i < len(all); i++ {
+		latest := all[i]
+		server := newTestServer(t, all[:i+1]...)
+		if _, err := Update(ctx, api.NewClient().WithBaseURL(server.URL), dir, UpdateOptions{Keep: 2}); err != nil {
+			t.Fatalf("Update to %d failed: %v", latest, err)
+		}
+		server.Close()
+	}
+
+	lock, err := ReadLock(dir)
+	if err != nil {
+		t.Fatalf("ReadLock failed: %v", err)
+	}
+	if len(lock.Backups) != 2 || lock.Backups[0].Build != 72 || lock.Backups[1].Build != 71 {
+		t.Fatalf("Expected backups of builds 72 and 71, got %+v", lock.Backups)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, BackupDir, BackupFileName("paper", "1.21.11", 70))); !os.IsNotExist(err) {
+		t.Error("Expected backup of build 70 to be pruned")
+	}
+}
+
+func TestUpdateAndRollback_Symlink(t *testing.T) {
+	server := newTestServer(t, 72, 74)
+	defer server.Close()
+
+	// The jar links to a directory of jars shared with other servers.
+	parent := t.TempDir()
+	jars := filepath.Join(parent, "jars")
+	dir := filepath.Join(parent, "server")
+	for _, d := range []string{jars, dir} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(jars, "paper-1.21.11-72.jar"), jarFor(72), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jarPath := filepath.Join(dir, DefaultJar)
+	if err := os.Symlink("../jars/paper-1.21.11-72.jar", jarPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Update(context.Background(), api.NewClient().WithBaseURL(server.URL), dir, UpdateOptions{}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if link, err := os.Readlink(jarPath); err != nil || link != "../jars/paper-1.21.11-74.jar" {
+		t.Errorf("Expected server.jar to link to build 74, got %q (%v)", link, err)
+	}
+	if sum, _ := api.FileSHA256(jarPath); sum != sumFor(74) {
+		t.Error("Expected server.jar to contain build 74")
+	}
+	if sum, _ := api.FileSHA256(filepath.Join(jars, "paper-1.21.11-72.jar")); sum != sumFor(72) {
+		t.Error("Expected the shared jar of build 72 to be left in place")
+	}
+	if sum, _ := api.FileSHA256(filepath.Join(dir, BackupDir, "paper-1.21.11-72.jar")); sum != sumFor(72) {
+		t.Error("Expected the backup to contain build 72")
+	}
+
+	if _, err := Rollback(dir, ""); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	if link, err := os.Readlink(jarPath); err != nil || link != "../jars/paper-1.21.11-72.jar" {
+		t.Errorf("Expected server.jar to link to build 72 after rollback, got %q (%v)", link, err)
+	}
+	if sum, _ := api.FileSHA256(jarPath); sum != sumFor(72) {
+		t.Error("Expected server.jar to contain build 72 after rollback")
+	}
+	if _, err := os.Stat(filepath.Join(jars, "paper-1.21.11-74.jar")); err != nil {
+		t.Errorf("Expected the shared jar of build 74 to be left in place: %v", err)
+	}
+}
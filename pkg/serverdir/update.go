@@ -0,0 +1,318 @@
+package serverdir
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/goPaperMC/pkg/api"
+)
+
+// UpdateOptions controls how Update selects and installs a newer build.
+type UpdateOptions struct {
+	Jar      string      // Jar file name inside the directory (defaults to server.jar)
+	Project  string      // Project hint used when the installed jar is not recorded
+	Version  string      // Target version constraint (defaults to the installed version)
+	Channel  api.Channel // Only consider builds in this channel (empty means any)
+	Artifact string      // Download key (defaults to server:default)
+	Keep     int         // Number of backups to keep (defaults to DefaultKeep)
+
+	// BeforeInstall is called once the new build is downloaded and
+	// verified, before the installed jar is replaced, e.g. to stop the
+	// server. An error aborts the update and leaves the directory unchanged.
+	BeforeInstall func(ctx context.Context, from, to *Install) error
+}
+
+// UpdateResult describes the outcome of Update.
+type UpdateResult struct {
+	Previous *Install // Build installed before the update
+	Current  *Install // Build installed after the update
+	Updated  bool     // False if the installed build was already the newest
+}
+
+// Update replaces the jar of a server directory with the newest build
+// allowed by the options. The new jar is downloaded and verified before the
+// installed one is moved into the backup directory, so the directory always
+// contains a complete jar. Versions older than the installed one are never
+// selected.
+//
+// If the jar is a symlink, e.g. to a directory of jars shared by several
+// servers, the file it points to is copied into the backup directory and
+// left in place; the new build is stored next to it under its versioned
+// name and the link is repointed to it.
+func Update(ctx context.Context, client api.API, dir string, opts UpdateOptions) (*UpdateResult, error) {
+	opts.setDefaults()
+
+	current, err := Detect(ctx, client, dir, DetectOptions{Jar: opts.Jar, Project: opts.Project})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to detect installed build")
+	}
+
+	result := &UpdateResult{Previous: current, Current: current}
+
+	constraint := opts.Version
+	if constraint == "" {
+		constraint = current.Version
+	}
+
+	version, err := client.ResolveVersion(ctx, current.Project, constraint, opts.Channel)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve target version")
+	}
+
+	if api.CompareVersions(version, current.Version) < 0 {
+		return result, nil
+	}
+
+	build, err := client.GetLatestBuildForChannel(ctx, current.Project, version, opts.Channel)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve target build")
+	}
+
+	if version == current.Version && build.ID <= current.Build {
+		return result, nil
+	}
+
+	download, ok := build.GetDownload(opts.Artifact)
+	if !ok {
+		return nil, errors.Newf("build %d of %s %s has no %s download", build.ID, current.Project, version, opts.Artifact)
+	}
+
+	next := &Install{
+		Project:     current.Project,
+		Version:     version,
+		Build:       build.ID,
+		Channel:     build.Channel,
+		File:        opts.Jar,
+		SHA256:      download.Checksums.SHA256,
+		InstalledAt: time.Now().UTC(),
+	}
+
+	backupDir := filepath.Join(dir, BackupDir)
+	staging := filepath.Join(backupDir, "."+next.BackupName())
+	if _, err := client.DownloadTo(ctx, download, staging); err != nil {
+		return nil, errors.Wrap(err, "failed to download new build")
+	}
+	defer func() { _ = os.Remove(staging) }()
+
+	if opts.BeforeInstall != nil {
+		if err := opts.BeforeInstall(ctx, current, next); err != nil {
+			return nil, errors.Wrap(err, "failed to prepare update")
+		}
+	}
+
+	jarPath := filepath.Join(dir, opts.Jar)
+	backup := *current
+	backup.File = filepath.ToSlash(filepath.Join(BackupDir, current.BackupName()))
+
+	linked, err := isSymlink(jarPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to inspect installed jar")
+	}
+
+	if linked {
+		err = copyFile(jarPath, filepath.Join(dir, backup.File))
+	} else {
+		err = os.Rename(jarPath, filepath.Join(dir, backup.File))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to back up installed jar")
+	}
+
+	if err := installJar(staging, jarPath, next.BackupName()); err != nil {
+		if !linked {
+			_ = os.Rename(filepath.Join(dir, backup.File), jarPath)
+		}
+		return nil, errors.Wrap(err, "failed to install new jar")
+	}
+
+	lock := &Lock{Install: *next, Backups: []Install{backup}}
+	if previous, err := ReadLock(dir); err == nil {
+		for _, b := range previous.Backups {
+			if b.File != backup.File {
+				lock.Backups = append(lock.Backups, b)
+			}
+		}
+	}
+
+	if err := pruneBackups(dir, lock, opts.Keep); err != nil {
+		return nil, err
+	}
+
+	if err := WriteLock(dir, lock); err != nil {
+		return nil, err
+	}
+
+	result.Current = next
+	result.Updated = true
+
+	return result, nil
+}
+
+// setDefaults fills in unset options.
+func (o *UpdateOptions) setDefaults() {
+	if o.Jar == "" {
+		o.Jar = DefaultJar
+	}
+	if o.Artifact == "" {
+		o.Artifact = api.DefaultDownloadKey
+	}
+	if o.Keep <= 0 {
+		o.Keep = DefaultKeep
+	}
+}
+
+// pruneBackups removes backups beyond the newest keep entries.
+func pruneBackups(dir string, lock *Lock, keep int) error {
+	if len(lock.Backups) <= keep {
+		return nil
+	}
+
+	for _, b := range lock.Backups[keep:] {
+		if err := os.Remove(filepath.Join(dir, b.File)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.Wrapf(err, "failed to remove old backup %s", b.File)
+		}
+	}
+	lock.Backups = lock.Backups[:keep]
+
+	return nil
+}
+
+// RollbackResult describes the outcome of Rollback.
+type RollbackResult struct {
+	From *Install // Build that was replaced
+	To   *Install // Build restored from backup
+}
+
+// Rollback restores the most recent backup of a server directory recorded
+// in its lock file. The jar being rolled back from is removed, unless the
+// jar is a symlink; it can always be downloaded again.
+func Rollback(dir, jar string) (*RollbackResult, error) {
+	if jar == "" {
+		jar = DefaultJar
+	}
+
+	lock, err := ReadLock(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lock.Backups) == 0 {
+		return nil, errors.New("no backups available to roll back to")
+	}
+
+	backup := lock.Backups[0]
+	backupPath := filepath.Join(dir, backup.File)
+
+	sum, err := api.FileSHA256(backupPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to checksum backup %s", backup.File)
+	}
+	if sum != backup.SHA256 {
+		return nil, errors.Newf("backup %s is corrupt: expected SHA256 %s, got %s", backup.File, backup.SHA256, sum)
+	}
+
+	if err := installJar(backupPath, filepath.Join(dir, jar), backup.BackupName()); err != nil {
+		return nil, errors.Wrap(err, "failed to restore backup")
+	}
+	_ = os.Remove(backupPath)
+
+	from := lock.Install
+	restored := backup
+	restored.File = jar
+	restored.InstalledAt = time.Now().UTC()
+
+	if err := WriteLock(dir, &Lock{Install: restored, Backups: lock.Backups[1:]}); err != nil {
+		return nil, err
+	}
+
+	return &RollbackResult{From: &from, To: &restored}, nil
+}
+
+// isSymlink reports whether path is a symlink.
+func isSymlink(path string) (bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+
+	return info.Mode()&os.ModeSymlink != 0, nil
+}
+
+// installJar moves file into place as the jar at jarPath. If jarPath is a
+// symlink, file is copied next to the file the link points to as name and
+// the link is atomically repointed to the copy instead.
+func installJar(file, jarPath, name string) error {
+	linked, err := isSymlink(jarPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if !linked {
+		return os.Rename(file, jarPath)
+	}
+
+	target, err := filepath.EvalSymlinks(jarPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve jar symlink")
+	}
+	path := filepath.Join(filepath.Dir(target), name)
+
+	if err := copyFile(file, path); err != nil {
+		return err
+	}
+
+	// Keep the link relative if it was, so the directory can still be moved.
+	dest := path
+	if link, err := os.Readlink(jarPath); err == nil && !filepath.IsAbs(link) {
+		if linkDir, err := filepath.EvalSymlinks(filepath.Dir(jarPath)); err == nil {
+			if rel, err := filepath.Rel(linkDir, path); err == nil {
+				dest = rel
+			}
+		}
+	}
+
+	tmp := filepath.Join(filepath.Dir(jarPath), "."+filepath.Base(jarPath)+".link")
+	_ = os.Remove(tmp)
+	if err := os.Symlink(dest, tmp); err != nil {
+		return errors.Wrap(err, "failed to create jar symlink")
+	}
+	if err := os.Rename(tmp, jarPath); err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrap(err, "failed to repoint jar symlink")
+	}
+
+	return nil
+}
+
+// copyFile copies src to dst through a temporary file, so dst is either
+// left untouched or replaced by a complete copy.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		_ = tmp.Close()
+		return errors.Wrapf(err, "failed to copy %s", src)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, dst)
+}
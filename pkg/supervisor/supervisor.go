@@ -0,0 +1,273 @@
+// Package supervisor runs a server process in the foreground: it forwards
+// console input and output, stops the server with a console command when
+// its context is cancelled and restarts it with backoff when it exits.
+package supervisor
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// DefaultStopCommand is the console command stopping a server.
+	DefaultStopCommand = "stop"
+	// DefaultStopTimeout is how long a server may take to stop before it is killed.
+	DefaultStopTimeout = 2 * time.Minute
+	// DefaultMinBackoff is the delay before the first restart.
+	DefaultMinBackoff = time.Second
+	// DefaultMaxBackoff caps the delay between restarts.
+	DefaultMaxBackoff = time.Minute
+	// DefaultHealthyAfter is how long a server must run for the backoff to reset.
+	DefaultHealthyAfter = 5 * time.Minute
+)
+
+// Policy decides when the server is restarted.
+type Policy string
+
+const (
+	// RestartOnFailure restarts the server when it exits with an error.
+	RestartOnFailure Policy = "on-failure"
+	// RestartAlways restarts the server whenever it exits, e.g. after the
+	// stop command was typed into the console.
+	RestartAlways Policy = "always"
+	// RestartNever runs the server once.
+	RestartNever Policy = "never"
+)
+
+// ParsePolicy parses a restart policy.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case RestartOnFailure, RestartAlways, RestartNever:
+		return p, nil
+	default:
+		return "", errors.Newf("invalid restart policy %q (expected on-failure, always or never)", s)
+	}
+}
+
+// Command is a process to start.
+type Command struct {
+	Path string
+	Args []string
+	Env  []string // Added to the supervisor's environment
+}
+
+// Supervisor runs a server process.
+type Supervisor struct {
+	Dir     string                                      // Working directory of the server
+	Command func(ctx context.Context) (*Command, error) // Builds the command before every start
+
+	Stdin  io.Reader // Console input forwarded to the server, line by line (nil forwards nothing)
+	Stdout io.Writer
+	Stderr io.Writer
+
+	StopCommand  string        // Console command sent on cancellation (defaults to DefaultStopCommand)
+	StopTimeout  time.Duration // Time to wait for the server to stop before killing it
+	Policy       Policy        // Defaults to RestartOnFailure
+	MaxRestarts  int           // Consecutive restarts before giving up (0 means no limit)
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	HealthyAfter time.Duration // Run time after which the backoff and restart count reset
+
+	// BeforeRestart is called before every restart, e.g. to update the jar.
+	// Errors are logged and do not prevent the restart.
+	BeforeRestart func(ctx context.Context) error
+
+	Logger *slog.Logger
+}
+
+// New creates a supervisor running the commands built by command in dir,
+// forwarding the console to the standard streams.
+func New(dir string, command func(ctx context.Context) (*Command, error)) *Supervisor {
+	return &Supervisor{
+		Dir:          dir,
+		Command:      command,
+		Stdin:        os.Stdin,
+		Stdout:       os.Stdout,
+		Stderr:       os.Stderr,
+		StopCommand:  DefaultStopCommand,
+		StopTimeout:  DefaultStopTimeout,
+		Policy:       RestartOnFailure,
+		MinBackoff:   DefaultMinBackoff,
+		MaxBackoff:   DefaultMaxBackoff,
+		HealthyAfter: DefaultHealthyAfter,
+		Logger:       slog.New(slog.DiscardHandler),
+	}
+}
+
+// Run starts the server and supervises it until ctx is cancelled, the
+// server exits in a way the policy does not restart, or MaxRestarts is
+// exceeded. On cancellation the stop command is written to the console and
+// the server is killed if it has not exited within StopTimeout; Run returns
+// nil once a cancelled server has stopped on its own. Servers that cannot be
+// started are not restarted.
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.setDefaults()
+
+	input := s.forwardInput()
+	backoff := s.MinBackoff
+	restarts := 0
+
+	for {
+		if restarts > 0 && s.BeforeRestart != nil {
+			if err := s.BeforeRestart(ctx); err != nil {
+				s.Logger.Warn("preparing restart failed", "error", err.Error())
+			}
+		}
+
+		command, err := s.Command(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to build server command")
+		}
+
+		started := time.Now()
+		s.Logger.Info("starting server", "command", command.Path, "args", command.Args, "restarts", restarts)
+
+		exitErr := s.runOnce(ctx, command, input)
+		if ctx.Err() != nil {
+			return exitErr
+		}
+		if exitErr != nil && !errors.HasType(exitErr, (*exec.ExitError)(nil)) {
+			return exitErr
+		}
+
+		ran := time.Since(started)
+		s.Logger.Info("server exited", "error", exitErr, "uptime", ran.Round(time.Second))
+
+		switch {
+		case s.Policy == RestartNever, exitErr == nil && s.Policy != RestartAlways:
+			return errors.Wrap(exitErr, "server exited")
+		case ran >= s.HealthyAfter:
+			backoff, restarts = s.MinBackoff, 0
+		case s.MaxRestarts > 0 && restarts >= s.MaxRestarts:
+			return errors.Wrapf(exitErr, "server exited %d times in a row", restarts+1)
+		}
+
+		s.Logger.Warn("restarting server", "in", backoff)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, s.MaxBackoff)
+		restarts++
+	}
+}
+
+// runOnce runs the server until it exits. When ctx is cancelled, the server
+// is asked to stop and killed after StopTimeout; a server stopping in time
+// is not an error.
+func (s *Supervisor) runOnce(ctx context.Context, command *Command, input <-chan string) error {
+	cmd := exec.Command(command.Path, command.Args...)
+	cmd.Dir = s.Dir
+	cmd.Env = append(os.Environ(), command.Env...)
+	cmd.Stdout = s.Stdout
+	cmd.Stderr = s.Stderr
+	detach(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return errors.Wrap(err, "failed to open server console")
+	}
+
+	if err := cmd.Start(); err != nil {
+		return errors.Wrapf(err, "failed to start %s", command.Path)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case line, ok := <-input:
+			if !ok {
+				input = nil
+				continue
+			}
+			if _, err := io.WriteString(stdin, line+"\n"); err != nil {
+				s.Logger.Debug("writing to server console failed", "error", err.Error())
+			}
+		case <-ctx.Done():
+			s.Logger.Info("stopping server", "command", s.StopCommand, "timeout", s.StopTimeout)
+			if _, err := io.WriteString(stdin, s.StopCommand+"\n"); err != nil {
+				s.Logger.Debug("writing to server console failed", "error", err.Error())
+			}
+
+			timer := time.NewTimer(s.StopTimeout)
+			defer timer.Stop()
+
+			select {
+			case <-done:
+				return nil
+			case <-timer.C:
+				_ = cmd.Process.Kill()
+				<-done
+				return errors.Newf("server did not stop within %s and was killed", s.StopTimeout)
+			}
+		}
+	}
+}
+
+// forwardInput reads console input line by line. The channel is closed at
+// the end of the input.
+func (s *Supervisor) forwardInput() <-chan string {
+	lines := make(chan string)
+	if s.Stdin == nil {
+		close(lines)
+		return lines
+	}
+
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(s.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	return lines
+}
+
+// setDefaults fills in unset fields.
+func (s *Supervisor) setDefaults() {
+	if s.StopCommand == "" {
+		s.StopCommand = DefaultStopCommand
+	}
+	if s.StopTimeout <= 0 {
+		s.StopTimeout = DefaultStopTimeout
+	}
+	if s.Policy == "" {
+		s.Policy = RestartOnFailure
+	}
+	if s.MinBackoff <= 0 {
+		s.MinBackoff = DefaultMinBackoff
+	}
+	if s.MaxBackoff < s.MinBackoff {
+		s.MaxBackoff = max(DefaultMaxBackoff, s.MinBackoff)
+	}
+	if s.HealthyAfter <= 0 {
+		s.HealthyAfter = DefaultHealthyAfter
+	}
+	if s.Stdout == nil {
+		s.Stdout = io.Discard
+	}
+	if s.Stderr == nil {
+		s.Stderr = io.Discard
+	}
+	if s.Logger == nil {
+		s.Logger = slog.New(slog.DiscardHandler)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package supervisor
+
+import "os/exec"
+
+// detach does nothing on systems without process groups.
+func detach(*exec.Cmd) {}
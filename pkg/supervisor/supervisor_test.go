@@ -0,0 +1,192 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeJava is a server console: it echoes input, exits on "stop", crashes
+// on "crash" and ignores "stop" when IGNORE_STOP is set.
+const fakeJava = `#!/bin/sh
+echo "started $*"
+while read -r line; do
+	case "$line" in
+	stop)
+		if [ -n "$IGNORE_STOP" ]; then
+			echo "ignoring stop"
+			continue
+		fi
+		echo "stopping"
+		exit 0
+		;;
+	crash)
+		echo "crashing"
+		exit 3
+		;;
+	*)
+		echo "> $line"
+		;;
+	esac
+done
+`
+
+// syncBuffer is a buffer safe for concurrent writes and reads.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor waits until the output contains text n times.
+func waitFor(t *testing.T, out *syncBuffer, text string, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for strings.Count(out.String(), text) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %q, got\n%s", text, out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newTestSupervisor supervises the fake java in a temporary directory.
+func newTestSupervisor(t *testing.T, env ...string) (*Supervisor, *io.PipeWriter, *syncBuffer) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake server requires a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	java := filepath.Join(dir, "java")
+	if err := os.WriteFile(java, []byte(fakeJava), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	stdin, console := io.Pipe()
+	t.Cleanup(func() { _ = console.Close() })
+
+	out := &syncBuffer{}
+	s := New(dir, func(context.Context) (*Command, error) {
+		return &Command{Path: java, Args: []string{"-jar", "server.jar"}, Env: env}, nil
+	})
+	s.Stdin = stdin
+	s.Stdout = out
+	s.Stderr = out
+	s.MinBackoff = 10 * time.Millisecond
+	s.MaxBackoff = 20 * time.Millisecond
+
+	return s, console, out
+}
+
+func TestSupervisor_StopsOnCancel(t *testing.T) {
+	s, console, out := newTestSupervisor(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	waitFor(t, out, "started -jar server.jar", 1)
+	if _, err := io.WriteString(console, "say hello\n"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, out, "> say hello", 1)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Expected a clean stop, got %v", err)
+	}
+	waitFor(t, out, "stopping", 1)
+}
+
+func TestSupervisor_RestartsOnCrash(t *testing.T) {
+	s, console, out := newTestSupervisor(t)
+	s.MaxRestarts = 2
+
+	restarts := 0
+	s.BeforeRestart = func(context.Context) error {
+		restarts++
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+
+	for i := 1; i <= 3; i++ {
+		waitFor(t, out, "started", i)
+		if _, err := io.WriteString(console, "crash\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := <-done
+	if err == nil || !strings.Contains(err.Error(), "exited 3 times in a row") {
+		t.Errorf("Expected to give up after 2 restarts, got %v", err)
+	}
+	if restarts != 2 {
+		t.Errorf("Expected BeforeRestart to run twice, got %d", restarts)
+	}
+}
+
+func TestSupervisor_CleanExit(t *testing.T) {
+	s, console, out := newTestSupervisor(t)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+
+	waitFor(t, out, "started", 1)
+	if _, err := io.WriteString(console, "stop\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("Expected a server stopped from the console not to be restarted, got %v", err)
+	}
+}
+
+func TestSupervisor_KillsAfterTimeout(t *testing.T) {
+	s, _, out := newTestSupervisor(t, "IGNORE_STOP=1")
+	s.StopTimeout = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	waitFor(t, out, "started", 1)
+	cancel()
+
+	err := <-done
+	if err == nil || !strings.Contains(err.Error(), "was killed") {
+		t.Errorf("Expected the server to be killed, got %v", err)
+	}
+	waitFor(t, out, "ignoring stop", 1)
+}
+
+func TestParsePolicy(t *testing.T) {
+	if p, err := ParsePolicy("always"); err != nil || p != RestartAlways {
+		t.Errorf("Expected RestartAlways, got %q (%v)", p, err)
+	}
+	if _, err := ParsePolicy("sometimes"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
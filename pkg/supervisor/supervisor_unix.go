@@ -0,0 +1,15 @@
+//go:build unix
+
+package supervisor
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts the server in its own process group, so a Ctrl-C in the
+// terminal or a signal sent to the supervisor's group reaches only the
+// supervisor, which stops the server with the stop command.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
@@ -0,0 +1,68 @@
+//go:build unix
+
+package supervisor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// helperEnv makes the test binary run TestHelperSupervisor as a supervisor
+// of the fake java at its value.
+const helperEnv = "SUPERVISOR_TEST_JAVA"
+
+// TestHelperSupervisor is the supervisor process of TestSupervisor_SIGTERM.
+func TestHelperSupervisor(t *testing.T) {
+	java := os.Getenv(helperEnv)
+	if java == "" {
+		t.Skip("only run by TestSupervisor_SIGTERM")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	s := New(filepath.Dir(java), func(context.Context) (*Command, error) {
+		return &Command{Path: java}, nil
+	})
+	s.Stdin = nil
+	if err := s.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSupervisor_SIGTERM sends SIGTERM to the process group of a
+// supervisor, as a terminal or service manager does, and expects only the
+// supervisor to receive it and stop the server from the console.
+func TestSupervisor_SIGTERM(t *testing.T) {
+	dir := t.TempDir()
+	java := filepath.Join(dir, "java")
+	if err := os.WriteFile(java, []byte(fakeJava), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &syncBuffer{}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperSupervisor$")
+	cmd.Env = append(os.Environ(), helperEnv+"="+java)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) })
+
+	waitFor(t, out, "started", 1)
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("Expected the supervisor to exit cleanly, got %v\n%s", err, out.String())
+	}
+	waitFor(t, out, "stopping", 1)
+}